@@ -0,0 +1,298 @@
+// alert evaluates weather rules such as "temp < 2 over 12h" against fetched
+// metservice data and produces de-duplicated alerts.
+package alert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Data is the fetched data for a single location that rules are evaluated
+// against. Any of the pointers may be nil, in which case rules needing that
+// data are skipped.
+type Data struct {
+	Location string
+	// Time is the start of every rule's window, usually the time the data
+	// was fetched.
+	Time time.Time
+
+	Hours    *metservice.ObservationForecastHours
+	Forecast *metservice.Forecast
+	Pollen   *metservice.Pollen
+}
+
+// Alert is produced when a Rule matches.
+type Alert struct {
+	Rule     string
+	Location string
+	// Time is when the alert was raised.
+	Time time.Time
+	// At is the time of the first value that matched, or the start of the
+	// window for aggregated rules.
+	At time.Time
+	// Value is the matching value, or the aggregate for aggregated rules.
+	Value     float64
+	Threshold float64
+	Message   string
+}
+
+// DefaultCooldown is the Engine cooldown used by NewEngine.
+const DefaultCooldown = 6 * time.Hour
+
+// Engine evaluates a set of rules and remembers which alerts it has raised
+// so the same condition is not reported over and over. It is safe for
+// concurrent use.
+type Engine struct {
+	Rules []Rule
+	// Cooldown is the minimum time between two alerts from the same rule
+	// and location, for rules without their own Cooldown.
+	Cooldown time.Duration
+
+	mu   sync.Mutex
+	last map[key]time.Time
+	// seen holds the time each raised alert can be forgotten.
+	seen map[seenKey]time.Time
+}
+
+type key struct {
+	rule     string
+	location string
+}
+
+// seenKey identifies a raised alert by the time of the value that matched,
+// or the start of the period for aggregated rules.
+type seenKey struct {
+	key
+	at int64
+}
+
+// NewEngine returns an Engine for rules using DefaultCooldown.
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{
+		Rules:    rules,
+		Cooldown: DefaultCooldown,
+	}
+}
+
+// Evaluate checks every rule against d and returns the new alerts. An alert
+// is dropped if the same rule already alerted for the same location and
+// matching time, or if the rule alerted for the location within its
+// cooldown. Aggregated rules alert at most once per period: each night for
+// overnight rules, and otherwise each span of Window, counted from the
+// zero time. Rules without a Name are told apart by their String.
+func (e *Engine) Evaluate(d Data) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.last == nil {
+		e.last = make(map[key]time.Time)
+		e.seen = make(map[seenKey]time.Time)
+	}
+	for sk, expires := range e.seen {
+		if d.Time.After(expires) {
+			delete(e.seen, sk)
+		}
+	}
+
+	var alerts []Alert
+	for _, r := range e.Rules {
+		if r.Location != "" && r.Location != d.Location {
+			continue
+		}
+		a, ok := Match(r, d)
+		if !ok {
+			continue
+		}
+		cooldown := r.Cooldown
+		if cooldown == 0 {
+			cooldown = e.Cooldown
+		}
+		k := key{a.Rule, d.Location}
+		at, forget := a.At, expiry(d.Time, a.At, cooldown)
+		if r.Agg != AggAny {
+			var end time.Time
+			at, end = r.period(d.Time)
+			if end.After(forget) {
+				forget = end
+			}
+		}
+		sk := seenKey{k, at.Unix()}
+		if _, ok := e.seen[sk]; ok {
+			continue
+		}
+		if last, ok := e.last[k]; ok && d.Time.Sub(last) < cooldown {
+			continue
+		}
+		e.seen[sk] = forget
+		e.last[k] = d.Time
+		alerts = append(alerts, a)
+	}
+	return alerts
+}
+
+// expiry returns when an alert raised at raised for a value at at can be
+// forgotten: once the cooldown has passed and the value can no longer be in
+// a window. Values for days can start up to a day before the window does.
+func expiry(raised, at time.Time, cooldown time.Duration) time.Time {
+	expires := raised.Add(cooldown)
+	if t := at.Add(24 * time.Hour); t.After(expires) {
+		expires = t
+	}
+	return expires
+}
+
+// point is a single value with the time it applies from.
+type point struct {
+	at    time.Time
+	value float64
+}
+
+// Match evaluates a single rule against d without any de-duplication and
+// returns the resulting Alert, if any. The rule's Location is not checked.
+func Match(r Rule, d Data) (Alert, bool) {
+	points := collect(r, d)
+	if len(points) == 0 {
+		return Alert{}, false
+	}
+
+	var (
+		value float64
+		at    time.Time
+		ok    bool
+	)
+	if r.Agg == AggAny {
+		for _, p := range points {
+			if r.Op.compare(p.value, r.Threshold) {
+				value, at, ok = p.value, p.at, true
+				break
+			}
+		}
+	} else {
+		value = aggregate(r.Agg, points)
+		at = d.Time
+		ok = r.Op.compare(value, r.Threshold)
+	}
+	if !ok {
+		return Alert{}, false
+	}
+
+	return Alert{
+		Rule:      r.name(),
+		Location:  d.Location,
+		Time:      d.Time,
+		At:        at,
+		Value:     value,
+		Threshold: r.Threshold,
+		Message: fmt.Sprintf("%s: %s is %g, %s %g",
+			d.Location, describe(r), value, r.Op, r.Threshold),
+	}, true
+}
+
+func describe(r Rule) string {
+	if r.Agg == AggAny {
+		return string(r.Field)
+	}
+	if r.Overnight {
+		return fmt.Sprintf("%s %s overnight", r.Agg, r.Field)
+	}
+	return fmt.Sprintf("%s %s over %s", r.Agg, r.Field, r.Window)
+}
+
+// collect returns the values of r.Field falling within r's window in time
+// order.
+func collect(r Rule, d Data) []point {
+	start, end := r.window(d.Time)
+	in := func(t time.Time) bool {
+		return !t.Before(start) && t.Before(end)
+	}
+	var points []point
+
+	switch r.Field {
+	case FieldTemp, FieldRain, FieldWind, FieldHumidity:
+		if d.Hours == nil {
+			return nil
+		}
+		for _, h := range d.Hours.Forecasts {
+			if h.Date == nil || !in(h.Date.Time) {
+				continue
+			}
+			if v, ok := hourValue(r.Field, h); ok {
+				points = append(points, point{h.Date.Time, v})
+			}
+		}
+	case FieldMax, FieldMin:
+		if d.Forecast == nil {
+			return nil
+		}
+		for _, day := range d.Forecast.Days {
+			if day.Date == nil {
+				continue
+			}
+			// A day counts if any part of it is within the window.
+			dayEnd := day.Date.AddDate(0, 0, 1)
+			if !dayEnd.After(start) || !day.Date.Before(end) {
+				continue
+			}
+			v := day.Max
+			if r.Field == FieldMin {
+				v = day.Min
+			}
+			if v != nil {
+				points = append(points, point{day.Date.Time, float64(*v)})
+			}
+		}
+	case FieldPollen:
+		if d.Pollen == nil {
+			return nil
+		}
+		for _, p := range d.Pollen.PollenDays {
+			if p.ValidFrom == nil || p.Level == nil {
+				continue
+			}
+			if (p.ValidTo != nil && !p.ValidTo.After(start)) || !p.ValidFrom.Before(end) {
+				continue
+			}
+			if v, ok := PollenLevel(*p.Level); ok {
+				points = append(points, point{p.ValidFrom.Time, v})
+			}
+		}
+	}
+	return points
+}
+
+func hourValue(f Field, h metservice.ForecastHour) (float64, bool) {
+	switch {
+	case f == FieldTemp && h.Temp != nil:
+		return float64(*h.Temp), true
+	case f == FieldRain && h.Rainfall != nil:
+		return *h.Rainfall, true
+	case f == FieldWind && h.WindSpeed != nil:
+		return float64(*h.WindSpeed), true
+	case f == FieldHumidity && h.Humidity != nil:
+		return float64(*h.Humidity), true
+	}
+	return 0, false
+}
+
+func aggregate(agg Agg, points []point) float64 {
+	v := points[0].value
+	sum := 0.0
+	for _, p := range points {
+		sum += p.value
+		switch {
+		case agg == AggMin && p.value < v:
+			v = p.value
+		case agg == AggMax && p.value > v:
+			v = p.value
+		}
+	}
+	switch agg {
+	case AggSum:
+		return sum
+	case AggMean:
+		return sum / float64(len(points))
+	}
+	return v
+}
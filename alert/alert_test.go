@@ -0,0 +1,168 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+var referenceTime = time.Date(2006, time.January, 02, 15, 0, 0, 0, time.UTC)
+
+func hours(temps []int, rain []float64) *metservice.ObservationForecastHours {
+	ofh := new(metservice.ObservationForecastHours)
+	for i := range temps {
+		ofh.Forecasts = append(ofh.Forecasts, metservice.ForecastHour{
+			Date:     &metservice.Timestamp{Time: referenceTime.Add(time.Duration(i) * time.Hour)},
+			Temp:     metservice.Int(temps[i]),
+			Rainfall: metservice.Float64(rain[i]),
+		})
+	}
+	return ofh
+}
+
+func TestMatch(t *testing.T) {
+	d := Data{
+		Location: "Dunedin",
+		Time:     referenceTime,
+		Hours:    hours([]int{8, 5, 1, 0, 3}, []float64{5, 5, 5, 5, 5}),
+		Forecast: &metservice.Forecast{Days: []metservice.ForecastDay{
+			{Date: &metservice.Timestamp{Time: referenceTime.Truncate(24 * time.Hour)}, Max: metservice.Int(9)},
+		}},
+		Pollen: &metservice.Pollen{PollenDays: []metservice.PollenDay{
+			{
+				Level:     metservice.String("High"),
+				ValidFrom: &metservice.Timestamp{Time: referenceTime},
+				ValidTo:   &metservice.Timestamp{Time: referenceTime.Add(24 * time.Hour)},
+			},
+		}},
+	}
+
+	testCases := []struct {
+		expr   string
+		want   bool
+		value  float64
+		offset time.Duration
+	}{
+		{"temp < 2 over 12h", true, 1, 2 * time.Hour},
+		{"temp < 2 over 2h", false, 0, 0},
+		{"sum(rain) > 20 over 24h", true, 25, 0},
+		{"sum(rain) > 20 over 4h", false, 0, 0},
+		{"mean(temp) >= 3.4", true, 3.4, 0},
+		{"max < 10", true, 9, -15 * time.Hour},
+		{"pollen >= moderate", true, 3, 0},
+		{"humidity > 0", false, 0, 0},
+		{"temp < 1 overnight", true, 0, 3 * time.Hour},
+		{"max(temp) > 5 overnight", false, 0, 0},
+	}
+	for _, tc := range testCases {
+		a, ok := Match(MustParse(tc.expr), d)
+		if ok != tc.want {
+			t.Errorf("Match(%q) = %v, want %v", tc.expr, ok, tc.want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if a.Value != tc.value {
+			t.Errorf("Match(%q) value = %v, want %v", tc.expr, a.Value, tc.value)
+		}
+		if want := referenceTime.Add(tc.offset); !a.At.Equal(want) {
+			t.Errorf("Match(%q) at = %v, want %v", tc.expr, a.At, want)
+		}
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	e := NewEngine(
+		MustParse("temp < 2 over 12h in Dunedin"),
+		MustParse("sum(rain) > 20"),
+	)
+	e.Rules[1].Cooldown = time.Hour
+
+	d := Data{
+		Location: "Dunedin",
+		Time:     referenceTime,
+		Hours:    hours([]int{8, 1, 1}, []float64{10, 10, 10}),
+	}
+	if got := len(e.Evaluate(d)); got != 2 {
+		t.Fatalf("first Evaluate returned %d alerts, want 2", got)
+	}
+
+	// Nothing new half an hour later.
+	d.Time = referenceTime.Add(30 * time.Minute)
+	if got := e.Evaluate(d); len(got) != 0 {
+		t.Errorf("second Evaluate returned %v, want none", got)
+	}
+
+	// The rain rule's cooldown has passed, but it has already alerted for
+	// this day, and the frost alert for the same hour is a duplicate.
+	d.Time = referenceTime.Add(time.Hour)
+	if got := e.Evaluate(d); len(got) != 0 {
+		t.Errorf("third Evaluate returned %v, want none", got)
+	}
+
+	// The next day the rain rule alerts again.
+	d.Time = referenceTime.Add(9 * time.Hour)
+	d.Hours = hours([]int{8, 8, 8}, []float64{10, 10, 10})
+	for _, h := range d.Hours.Forecasts {
+		h.Date.Time = h.Date.Add(9 * time.Hour)
+	}
+	got := e.Evaluate(d)
+	if len(got) != 1 || got[0].Rule != "sum(rain) > 20" {
+		t.Errorf("next day Evaluate returned %v, want only the rain alert", got)
+	}
+
+	// Other locations don't match the Dunedin rule.
+	d.Location = "Mosgiel"
+	got = e.Evaluate(d)
+	if len(got) != 1 || got[0].Location != "Mosgiel" {
+		t.Errorf("Mosgiel Evaluate returned %v, want only the rain alert", got)
+	}
+}
+
+func TestEngine_Unnamed(t *testing.T) {
+	// Rules built in code have no Name, but still don't suppress each
+	// other.
+	e := NewEngine(
+		Rule{Field: FieldTemp, Op: OpLess, Threshold: 2, Window: 12 * time.Hour},
+		Rule{Field: FieldRain, Agg: AggSum, Op: OpGreater, Threshold: 20, Window: 12 * time.Hour},
+	)
+	d := Data{
+		Location: "Dunedin",
+		Time:     referenceTime,
+		Hours:    hours([]int{8, 1, 1}, []float64{10, 10, 10}),
+	}
+	got := e.Evaluate(d)
+	if len(got) != 2 {
+		t.Fatalf("Evaluate returned %v, want 2 alerts", got)
+	}
+	if got[0].Rule != "temp < 2 over 12h0m0s" {
+		t.Errorf("first alert rule = %q", got[0].Rule)
+	}
+}
+
+func TestEngine_forget(t *testing.T) {
+	e := NewEngine(MustParse("sum(rain) > 20"))
+	e.Cooldown = time.Hour
+	d := Data{
+		Location: "Dunedin",
+		Time:     referenceTime,
+		Hours:    hours([]int{8, 1, 1}, []float64{10, 10, 10}),
+	}
+	var alerts int
+	for i := 0; i < 100; i++ {
+		d.Time = referenceTime.Add(time.Duration(i) * time.Hour)
+		for j := range d.Hours.Forecasts {
+			d.Hours.Forecasts[j].Date.Time = d.Time.Add(time.Duration(j) * time.Hour)
+		}
+		alerts += len(e.Evaluate(d))
+	}
+	// From 15:00 on the 2nd to 18:00 on the 6th is five days.
+	if alerts != 5 {
+		t.Errorf("Evaluate returned %d alerts, want one a day, 5", alerts)
+	}
+	if n := len(e.seen); n > 2 {
+		t.Errorf("engine remembers %d alerts, want no more than 2", n)
+	}
+}
@@ -0,0 +1,273 @@
+package alert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field is a quantity a Rule is evaluated against.
+type Field string
+
+// Fields that can be used in a Rule. The hourly fields come from the
+// forecast part of an ObservationForecastHours, max and min from the days of
+// a Forecast and pollen from a Pollen.
+const (
+	FieldTemp     Field = "temp"     // hourly temperature in °C
+	FieldRain     Field = "rain"     // hourly rainfall in mm
+	FieldWind     Field = "wind"     // hourly wind speed in km/h
+	FieldHumidity Field = "humidity" // hourly relative humidity in %
+	FieldMax      Field = "max"      // daily maximum temperature in °C
+	FieldMin      Field = "min"      // daily minimum temperature in °C
+	FieldPollen   Field = "pollen"   // pollen level, see PollenLevel
+)
+
+// Agg is an aggregation applied to the values in a Rule's window before
+// comparing.
+type Agg string
+
+// Aggregations that can be used in a Rule. AggAny, the default, fires if any
+// single value in the window matches.
+const (
+	AggAny  Agg = ""
+	AggMin  Agg = "min"
+	AggMax  Agg = "max"
+	AggSum  Agg = "sum"
+	AggMean Agg = "mean"
+)
+
+// Op is a comparison operator.
+type Op string
+
+// Comparison operators that can be used in a Rule.
+const (
+	OpLess         Op = "<"
+	OpLessEqual    Op = "<="
+	OpGreater      Op = ">"
+	OpGreaterEqual Op = ">="
+	OpEqual        Op = "=="
+	OpNotEqual     Op = "!="
+)
+
+func (op Op) compare(a, b float64) bool {
+	switch op {
+	case OpLess:
+		return a < b
+	case OpLessEqual:
+		return a <= b
+	case OpGreater:
+		return a > b
+	case OpGreaterEqual:
+		return a >= b
+	case OpEqual:
+		return a == b
+	case OpNotEqual:
+		return a != b
+	}
+	return false
+}
+
+// DefaultWindow is the window used by a rule that doesn't give one.
+const DefaultWindow = 24 * time.Hour
+
+// The hours of the day an overnight window runs between.
+const (
+	OvernightStart = 18
+	OvernightEnd   = 9
+)
+
+// Rule is a single alert condition. Rules are usually written in the small
+// expression language understood by Parse.
+type Rule struct {
+	// Name identifies the rule in the Alerts it produces. Parse sets it to
+	// the expression, and rules without one use their String.
+	Name      string
+	Field     Field
+	Agg       Agg
+	Op        Op
+	Threshold float64
+	// Window is how far ahead of the evaluation time to look.
+	Window time.Duration
+	// Overnight replaces Window with the coming night, from OvernightStart
+	// to OvernightEnd o'clock in the time zone of the evaluation time. If
+	// it's already night the window runs from the evaluation time.
+	Overnight bool
+	// Location limits the rule to a single location. An empty Location
+	// matches every location.
+	Location string
+	// Cooldown is the minimum time between two alerts from this rule for
+	// the same location. Zero uses the Engine's Cooldown.
+	Cooldown time.Duration
+}
+
+func (r Rule) String() string {
+	s := string(r.Field)
+	if r.Agg != AggAny {
+		s = fmt.Sprintf("%s(%s)", r.Agg, r.Field)
+	}
+	s = fmt.Sprintf("%s %s %s", s, r.Op, strconv.FormatFloat(r.Threshold, 'f', -1, 64))
+	if r.Overnight {
+		s += " overnight"
+	} else {
+		s += " over " + r.Window.String()
+	}
+	if r.Location != "" {
+		s += " in " + r.Location
+	}
+	return s
+}
+
+// name returns the Name of r, or its String if it has none.
+func (r Rule) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.String()
+}
+
+// window returns the start and end of r's window when evaluated at t.
+func (r Rule) window(t time.Time) (start, end time.Time) {
+	if !r.Overnight {
+		return t, t.Add(r.Window)
+	}
+	y, m, d := t.Date()
+	morning := time.Date(y, m, d, OvernightEnd, 0, 0, 0, t.Location())
+	if t.Before(morning) {
+		return t, morning
+	}
+	start = time.Date(y, m, d, OvernightStart, 0, 0, 0, t.Location())
+	if t.After(start) {
+		start = t
+	}
+	return start, morning.AddDate(0, 0, 1)
+}
+
+// period returns the start of the fixed span an aggregated r evaluated at t
+// falls in, and when it ends. It's the night for overnight rules, and
+// otherwise t truncated to a multiple of Window, so windows that overlap
+// each other share a period.
+func (r Rule) period(t time.Time) (start, end time.Time) {
+	if r.Overnight {
+		_, end = r.window(t)
+		y, m, d := end.Date()
+		return time.Date(y, m, d-1, OvernightStart, 0, 0, 0, end.Location()), end
+	}
+	if r.Window <= 0 {
+		return t, t
+	}
+	start = t.Truncate(r.Window)
+	return start, start.Add(r.Window)
+}
+
+var ruleRE = regexp.MustCompile(`^\s*(?:(\w+)\(\s*(\w+)\s*\)|(\w+))` +
+	`\s*(<=|>=|==|!=|<|>)\s*((?i:very high)|[\w.+-]+)` +
+	`(?:\s+over\s+(\S+)|\s+(overnight))?` +
+	`(?:\s+in\s+(.+?))?\s*$`)
+
+// Parse parses a rule expression of the form
+//
+//	[agg(]field[)] op threshold [over window | overnight] [in location]
+//
+// For example "temp < 2 over 12h in Dunedin" fires if any forecast hour in
+// the next 12 hours is below 2°C, "sum(rain) > 20 over 1d" fires if more
+// than 20mm of rain is forecast in the next day anywhere, and "temp < 2
+// overnight" fires if frost is forecast for the coming night. The window
+// accepts anything time.ParseDuration does, plus a "d" suffix for days.
+// Pollen thresholds may be given as level names such as "high".
+func Parse(expr string) (Rule, error) {
+	m := ruleRE.FindStringSubmatch(expr)
+	if m == nil {
+		return Rule{}, SyntaxError{Expr: expr, Msg: "expected [agg(]field[)] op threshold [over window | overnight] [in location]"}
+	}
+	r := Rule{
+		Name:      expr,
+		Field:     Field(m[3]),
+		Op:        Op(m[4]),
+		Window:    DefaultWindow,
+		Overnight: m[7] != "",
+		Location:  m[8],
+	}
+	if m[1] != "" {
+		r.Agg = Agg(m[1])
+		r.Field = Field(m[2])
+		switch r.Agg {
+		case AggMin, AggMax, AggSum, AggMean:
+		default:
+			return Rule{}, SyntaxError{Expr: expr, Msg: "unknown aggregation " + strconv.Quote(m[1])}
+		}
+	}
+	switch r.Field {
+	case FieldTemp, FieldRain, FieldWind, FieldHumidity, FieldMax, FieldMin, FieldPollen:
+	default:
+		return Rule{}, SyntaxError{Expr: expr, Msg: "unknown field " + strconv.Quote(string(r.Field))}
+	}
+
+	t, err := strconv.ParseFloat(m[5], 64)
+	if err != nil {
+		level, ok := PollenLevel(m[5])
+		if r.Field != FieldPollen || !ok {
+			return Rule{}, SyntaxError{Expr: expr, Msg: "bad threshold " + strconv.Quote(m[5])}
+		}
+		t = level
+	}
+	r.Threshold = t
+
+	if m[6] != "" {
+		w, err := parseWindow(m[6])
+		if err != nil || w <= 0 {
+			return Rule{}, SyntaxError{Expr: expr, Msg: "bad window " + strconv.Quote(m[6])}
+		}
+		r.Window = w
+	}
+	return r, nil
+}
+
+// MustParse is like Parse but panics if the expression cannot be parsed.
+func MustParse(expr string) Rule {
+	r, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func parseWindow(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// PollenLevel converts a metservice pollen level such as "Moderate" into a
+// number from 1 (low) to 4 (very high) for comparison in rules.
+func PollenLevel(level string) (float64, bool) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "low":
+		return 1, true
+	case "moderate":
+		return 2, true
+	case "high":
+		return 3, true
+	case "very high":
+		return 4, true
+	}
+	return 0, false
+}
+
+// SyntaxError is returned by Parse for an expression it cannot understand.
+type SyntaxError struct {
+	Expr string
+	Msg  string
+}
+
+var _ error = SyntaxError{}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("bad rule %q: %s", e.Expr, e.Msg)
+}
@@ -0,0 +1,96 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		expr string
+		want Rule
+	}{
+		{"temp < 2 over 12h in Dunedin", Rule{Field: FieldTemp, Op: OpLess, Threshold: 2, Window: 12 * time.Hour, Location: "Dunedin"}},
+		{"sum(rain) > 20 over 1d", Rule{Field: FieldRain, Agg: AggSum, Op: OpGreater, Threshold: 20, Window: 24 * time.Hour}},
+		{"max(wind)>=60", Rule{Field: FieldWind, Agg: AggMax, Op: OpGreaterEqual, Threshold: 60, Window: DefaultWindow}},
+		{"pollen >= very high in Palmerston North", Rule{Field: FieldPollen, Op: OpGreaterEqual, Threshold: 4, Window: DefaultWindow, Location: "Palmerston North"}},
+		{"min != -1.5 over 90m", Rule{Field: FieldMin, Op: OpNotEqual, Threshold: -1.5, Window: 90 * time.Minute}},
+		{"pollen >= Very High", Rule{Field: FieldPollen, Op: OpGreaterEqual, Threshold: 4, Window: DefaultWindow}},
+		{"temp < 2 overnight in Dunedin", Rule{Field: FieldTemp, Op: OpLess, Threshold: 2, Window: DefaultWindow, Overnight: true, Location: "Dunedin"}},
+	}
+	for _, tc := range testCases {
+		got, err := Parse(tc.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tc.expr, err)
+			continue
+		}
+		tc.want.Name = tc.expr
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("Parse(%q) mismatch (-want +got):\n%s", tc.expr, diff)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"temp",
+		"snow < 2",
+		"median(temp) < 2",
+		"temp < cold",
+		"temp < 2 over soon",
+		"temp < 2 over -1h",
+		"temp < 2 overnight over 1h",
+	} {
+		_, err := Parse(expr)
+		if _, ok := err.(SyntaxError); !ok {
+			t.Errorf("Parse(%q) error = %v, want SyntaxError", expr, err)
+		}
+	}
+}
+
+func TestRule_window(t *testing.T) {
+	r := Rule{Overnight: true}
+	day := func(h int) time.Time {
+		return time.Date(2006, time.January, 2, h, 0, 0, 0, time.UTC)
+	}
+	testCases := []struct {
+		at         time.Time
+		start, end time.Time
+	}{
+		{day(3), day(3), day(9)},
+		{day(12), day(18), day(24 + 9)},
+		{day(21), day(21), day(24 + 9)},
+	}
+	for _, tc := range testCases {
+		start, end := r.window(tc.at)
+		if !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("window at %v = %v to %v, want %v to %v", tc.at, start, end, tc.start, tc.end)
+		}
+	}
+}
+
+func TestRule_period(t *testing.T) {
+	day := func(h int) time.Time {
+		return time.Date(2006, time.January, 2, h, 0, 0, 0, time.UTC)
+	}
+	testCases := []struct {
+		r          Rule
+		at         time.Time
+		start, end time.Time
+	}{
+		{Rule{Overnight: true}, day(3), day(-6), day(9)},
+		{Rule{Overnight: true}, day(12), day(18), day(24 + 9)},
+		{Rule{Overnight: true}, day(21), day(18), day(24 + 9)},
+		{Rule{Window: 12 * time.Hour}, day(3), day(0), day(12)},
+		{Rule{Window: 12 * time.Hour}, day(15), day(12), day(24)},
+	}
+	for _, tc := range testCases {
+		start, end := tc.r.period(tc.at)
+		if !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("%v period at %v = %v to %v, want %v to %v", tc.r, tc.at, start, end, tc.start, tc.end)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// archive keeps a local history of metservice data in SQLite. The API only
+// returns a couple of days of hourly data and a few days of forecasts, so
+// storing each fetch lets the history be queried long after it's gone.
+//
+// The package uses database/sql and doesn't import a driver. Open the
+// database with one, such as github.com/mattn/go-sqlite3, and pass it to
+// New.
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Kinds of record, stored in the kind column.
+const (
+	kindObservation     = "observation"
+	kindObservationHour = "observation_hour"
+	kindForecastHour    = "forecast_hour"
+	kindForecastDay     = "forecast_day"
+	kindPollenDay       = "pollen_day"
+	kindRiseSet         = "riseset"
+)
+
+const schema = `CREATE TABLE IF NOT EXISTS records (
+	kind      TEXT    NOT NULL,
+	location  TEXT    NOT NULL,
+	time      INTEGER NOT NULL,
+	source    TEXT    NOT NULL,
+	issued_at INTEGER NOT NULL,
+	stored_at INTEGER NOT NULL,
+	data      TEXT    NOT NULL,
+	PRIMARY KEY (kind, location, time, source, issued_at)
+)`
+
+// Archive stores metservice data in a SQLite database. Each record is kept
+// once per location, time, source endpoint and issue time, so storing the
+// same fetch again replaces it rather than adding a copy. It is safe for
+// concurrent use.
+type Archive struct {
+	db *sql.DB
+}
+
+// New returns an Archive using db, creating its table if needed.
+func New(ctx context.Context, db *sql.DB) (*Archive, error) {
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return nil, err
+	}
+	return &Archive{db: db}, nil
+}
+
+// record is a row of the records table.
+type record struct {
+	kind   string
+	time   *metservice.Timestamp
+	issued *metservice.Timestamp
+	value  interface{}
+}
+
+// store writes records for location in a single transaction. Records
+// without a time can't be queried, so they're skipped.
+func (a *Archive) store(ctx context.Context, location string, source metservice.Endpoint, records []record) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT OR REPLACE INTO records
+		(kind, location, time, source, issued_at, stored_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UnixNano()
+	for _, r := range records {
+		if r.time == nil {
+			continue
+		}
+		var issued int64
+		if r.issued != nil {
+			issued = r.issued.Unix()
+		}
+		data, err := json.Marshal(r.value)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.ExecContext(ctx, r.kind, location, r.time.Unix(),
+			string(source), issued, now, string(data))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// StoreForecast stores the days of a forecast, along with their rise and
+// set times. Each issue of a day is kept.
+func (a *Archive) StoreForecast(ctx context.Context, location string, f *metservice.Forecast) error {
+	var records []record
+	for _, d := range f.Days {
+		records = append(records, record{kindForecastDay, d.Date, d.IssuedAt, d})
+		if d.RiseSet != nil {
+			records = append(records, record{kindRiseSet, d.RiseSet.Date, nil, d.RiseSet})
+		}
+	}
+	return a.store(ctx, location, metservice.EndpointForecast, records)
+}
+
+// StoreObservation stores an observation by the time of its three hourly
+// reading.
+func (a *Archive) StoreObservation(ctx context.Context, location string, o *metservice.Observation) error {
+	var records []record
+	if o.ThreeHour != nil {
+		records = append(records, record{kindObservation, o.ThreeHour.Date, nil, o})
+	}
+	return a.store(ctx, location, metservice.EndpointObservation, records)
+}
+
+// StoreObservationForecastHours stores the observed and forecast hours.
+// The forecast hours don't say when they were issued, so the time of the
+// latest observed hour is used instead, and each issue is kept.
+func (a *Archive) StoreObservationForecastHours(ctx context.Context, location string, o *metservice.ObservationForecastHours) error {
+	var (
+		records []record
+		issued  *metservice.Timestamp
+	)
+	for _, h := range o.Observations {
+		records = append(records, record{kindObservationHour, h.Date, nil, h})
+		if h.Date != nil && (issued == nil || h.Date.After(issued.Time)) {
+			issued = h.Date
+		}
+	}
+	for _, h := range o.Forecasts {
+		records = append(records, record{kindForecastHour, h.Date, issued, h})
+	}
+	return a.store(ctx, location, metservice.EndpointObservationForecastHours, records)
+}
+
+// StorePollen stores each pollen day by the start of its validity.
+func (a *Archive) StorePollen(ctx context.Context, location string, p *metservice.Pollen) error {
+	var records []record
+	for _, d := range p.PollenDays {
+		records = append(records, record{kindPollenDay, d.ValidFrom, nil, d})
+	}
+	return a.store(ctx, location, metservice.EndpointPollen, records)
+}
+
+// StoreRiseSet stores rise and set times by their day.
+func (a *Archive) StoreRiseSet(ctx context.Context, location string, r *metservice.RiseSet) error {
+	return a.store(ctx, location, metservice.EndpointRiseSet, []record{
+		{kindRiseSet, r.Date, nil, r},
+	})
+}
+
+// FetchError is a failure to fetch or store an endpoint for a location.
+type FetchError struct {
+	Location string
+	Endpoint metservice.Endpoint
+	Err      error
+}
+
+var _ error = &FetchError{}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Location, e.Endpoint, e.Err)
+}
+
+// Fetch gets every archived endpoint for location with client and stores
+// the results. Every endpoint is tried; the failures are returned as
+// *FetchErrors.
+func (a *Archive) Fetch(ctx context.Context, client *metservice.Client, location string) []error {
+	var errs []error
+	fail := func(endpoint metservice.Endpoint, err error) {
+		if err != nil {
+			errs = append(errs, &FetchError{Location: location, Endpoint: endpoint, Err: err})
+		}
+	}
+
+	if f, _, err := client.GetForecast(ctx, location); err != nil {
+		fail(metservice.EndpointForecast, err)
+	} else {
+		fail(metservice.EndpointForecast, a.StoreForecast(ctx, location, f))
+	}
+	if o, _, err := client.GetObservation(ctx, location); err != nil {
+		fail(metservice.EndpointObservation, err)
+	} else {
+		fail(metservice.EndpointObservation, a.StoreObservation(ctx, location, o))
+	}
+	if o, _, err := client.GetObservationForecastHours(ctx, location); err != nil {
+		fail(metservice.EndpointObservationForecastHours, err)
+	} else {
+		fail(metservice.EndpointObservationForecastHours, a.StoreObservationForecastHours(ctx, location, o))
+	}
+	if p, _, err := client.GetPollen(ctx, location); err != nil {
+		fail(metservice.EndpointPollen, err)
+	} else {
+		fail(metservice.EndpointPollen, a.StorePollen(ctx, location, p))
+	}
+	if r, _, err := client.GetRiseSet(ctx, location); err != nil {
+		fail(metservice.EndpointRiseSet, err)
+	} else {
+		fail(metservice.EndpointRiseSet, a.StoreRiseSet(ctx, location, r))
+	}
+	return errs
+}
@@ -0,0 +1,134 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// query calls add with the data of each record of kind for location with a
+// time in [from, to), in time order. If latest is set only the most recently
+// issued record for each time is used, otherwise each issue is used, oldest
+// first.
+func (a *Archive) query(ctx context.Context, kind, location string, from, to time.Time, latest bool, add func([]byte) error) error {
+	rows, err := a.db.QueryContext(ctx, `SELECT time, data FROM records
+		WHERE kind = ? AND location = ? AND time >= ? AND time < ?
+		ORDER BY time, issued_at, stored_at`,
+		kind, location, from.Unix(), to.Unix())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		data    []byte
+		prev    int64
+		pending []byte
+	)
+	for rows.Next() {
+		var t int64
+		if err := rows.Scan(&t, &data); err != nil {
+			return err
+		}
+		if !latest {
+			if err := add(data); err != nil {
+				return err
+			}
+			continue
+		}
+		if pending != nil && t != prev {
+			if err := add(pending); err != nil {
+				return err
+			}
+		}
+		prev, pending = t, append([]byte(nil), data...)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if pending != nil {
+		return add(pending)
+	}
+	return nil
+}
+
+// Observations returns the observations for location taken in [from, to).
+func (a *Archive) Observations(ctx context.Context, location string, from, to time.Time) ([]metservice.Observation, error) {
+	var out []metservice.Observation
+	err := a.query(ctx, kindObservation, location, from, to, true, func(b []byte) error {
+		var v metservice.Observation
+		err := json.Unmarshal(b, &v)
+		out = append(out, v)
+		return err
+	})
+	return out, err
+}
+
+// ObservationHours returns the hourly observations for location in
+// [from, to).
+func (a *Archive) ObservationHours(ctx context.Context, location string, from, to time.Time) ([]metservice.ObservationHour, error) {
+	var out []metservice.ObservationHour
+	err := a.query(ctx, kindObservationHour, location, from, to, true, func(b []byte) error {
+		var v metservice.ObservationHour
+		err := json.Unmarshal(b, &v)
+		out = append(out, v)
+		return err
+	})
+	return out, err
+}
+
+// ForecastHours returns the hourly forecasts for location in [from, to).
+// Only the latest forecast for each hour is returned.
+func (a *Archive) ForecastHours(ctx context.Context, location string, from, to time.Time) ([]metservice.ForecastHour, error) {
+	var out []metservice.ForecastHour
+	err := a.query(ctx, kindForecastHour, location, from, to, true, func(b []byte) error {
+		var v metservice.ForecastHour
+		err := json.Unmarshal(b, &v)
+		out = append(out, v)
+		return err
+	})
+	return out, err
+}
+
+// ForecastDays returns the daily forecasts for location with dates in
+// [from, to). Every issue of each day is returned, ordered by date and then
+// IssuedAt, so the last for a date is the latest.
+func (a *Archive) ForecastDays(ctx context.Context, location string, from, to time.Time) ([]metservice.ForecastDay, error) {
+	var out []metservice.ForecastDay
+	err := a.query(ctx, kindForecastDay, location, from, to, false, func(b []byte) error {
+		var v metservice.ForecastDay
+		err := json.Unmarshal(b, &v)
+		out = append(out, v)
+		return err
+	})
+	return out, err
+}
+
+// PollenDays returns the pollen forecasts for location valid from a time in
+// [from, to).
+func (a *Archive) PollenDays(ctx context.Context, location string, from, to time.Time) ([]metservice.PollenDay, error) {
+	var out []metservice.PollenDay
+	err := a.query(ctx, kindPollenDay, location, from, to, true, func(b []byte) error {
+		var v metservice.PollenDay
+		err := json.Unmarshal(b, &v)
+		out = append(out, v)
+		return err
+	})
+	return out, err
+}
+
+// RiseSets returns the rise and set times for location for days in
+// [from, to). Times stored from both the forecast and riseSet endpoints are
+// merged, with the most recently stored used for each day.
+func (a *Archive) RiseSets(ctx context.Context, location string, from, to time.Time) ([]metservice.RiseSet, error) {
+	var out []metservice.RiseSet
+	err := a.query(ctx, kindRiseSet, location, from, to, true, func(b []byte) error {
+		var v metservice.RiseSet
+		err := json.Unmarshal(b, &v)
+		out = append(out, v)
+		return err
+	})
+	return out, err
+}
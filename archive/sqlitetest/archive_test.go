@@ -0,0 +1,162 @@
+//go:build cgo
+// +build cgo
+
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/archive"
+	"github.com/google/go-cmp/cmp"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var referenceTime = time.Date(2006, time.January, 02, 0, 0, 0, 0, time.UTC)
+
+func ts(d time.Duration) *metservice.Timestamp {
+	return &metservice.Timestamp{Time: referenceTime.Add(d)}
+}
+
+// open returns an Archive backed by a fresh in-memory database, along with
+// the database.
+func open(t *testing.T) (a *archive.Archive, db *sql.DB, teardown func()) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each connection to :memory: is a separate database.
+	db.SetMaxOpenConns(1)
+	a, err = archive.New(context.Background(), db)
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	return a, db, func() { db.Close() }
+}
+
+// count returns the number of rows in the records table.
+func count(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM records`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestArchive_StoreForecast(t *testing.T) {
+	a, db, teardown := open(t)
+	defer teardown()
+	ctx := context.Background()
+
+	day := func(issued time.Duration, max int) metservice.ForecastDay {
+		return metservice.ForecastDay{
+			Date:     ts(0),
+			IssuedAt: ts(issued),
+			Max:      metservice.Int(max),
+			RiseSet:  &metservice.RiseSet{Date: ts(0), SunRise: ts(6 * time.Hour)},
+		}
+	}
+	first := &metservice.Forecast{Days: []metservice.ForecastDay{day(-24*time.Hour, 20)}}
+	second := &metservice.Forecast{Days: []metservice.ForecastDay{day(-12*time.Hour, 18)}}
+	for _, f := range []*metservice.Forecast{first, first, second} {
+		if err := a.StoreForecast(ctx, "Dunedin", f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two issues of the day and a single rise and set.
+	if n := count(t, db); n != 3 {
+		t.Errorf("stored %d records, want 3", n)
+	}
+
+	days, err := a.ForecastDays(ctx, "Dunedin", referenceTime, referenceTime.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []metservice.ForecastDay{day(-24*time.Hour, 20), day(-12*time.Hour, 18)}
+	if diff := cmp.Diff(want, days); diff != "" {
+		t.Errorf("ForecastDays mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestArchive_StoreObservationForecastHours(t *testing.T) {
+	a, db, teardown := open(t)
+	defer teardown()
+	ctx := context.Background()
+
+	// An hour later the forecast for 02:00 has changed.
+	for i, temp := range []int{12, 14} {
+		hour := time.Duration(i) * time.Hour
+		err := a.StoreObservationForecastHours(ctx, "Dunedin", &metservice.ObservationForecastHours{
+			Observations: []metservice.ObservationHour{
+				{Date: ts(hour), Temp: metservice.Float64(10)},
+			},
+			Forecasts: []metservice.ForecastHour{
+				{Date: ts(2 * time.Hour), Temp: metservice.Int(temp)},
+				{Date: nil, Temp: metservice.Int(0)},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := count(t, db); n != 4 {
+		t.Errorf("stored %d records, want 4", n)
+	}
+
+	hours, err := a.ForecastHours(ctx, "Dunedin", referenceTime, referenceTime.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []metservice.ForecastHour{{Date: ts(2 * time.Hour), Temp: metservice.Int(14)}}
+	if diff := cmp.Diff(want, hours); diff != "" {
+		t.Errorf("ForecastHours mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestArchive_Fetch(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := metservice.NewClient()
+	client.BaseURL = server.URL + "/"
+
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"days": [{"dateISO": "2006-01-02T00:00:00Z", "issuedAtISO": "2006-01-01T12:00:00Z", "max": "20"}]}`)
+	})
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"threeHour": {"dateTimeISO": "2006-01-02T03:00:00Z", "temp": "11"}}`)
+	})
+	mux.HandleFunc("/pollen_town_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"pollen": [{"validFromISO": "2006-01-02T00:00:00Z", "level": "High"}]}`)
+	})
+	mux.HandleFunc("/riseSet_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"dayISO": "2006-01-02T00:00:00Z", "sunRiseISO": "2006-01-02T06:00:00Z"}`)
+	})
+
+	a, db, teardown := open(t)
+	defer teardown()
+	ctx := context.Background()
+	errs := a.Fetch(ctx, client, "Dunedin")
+	want := []error{&archive.FetchError{
+		Location: "Dunedin",
+		Endpoint: metservice.EndpointObservationForecastHours,
+		Err:      metservice.StatusError{Code: 404},
+	}}
+	if diff := cmp.Diff(want, errs, cmp.Comparer(func(a, b error) bool {
+		return a.Error() == b.Error()
+	})); diff != "" {
+		t.Errorf("Fetch errors mismatch (-want +got):\n%s", diff)
+	}
+	if n := count(t, db); n != 4 {
+		t.Errorf("stored %d records, want 4", n)
+	}
+}
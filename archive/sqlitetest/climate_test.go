@@ -0,0 +1,84 @@
+//go:build cgo
+// +build cgo
+
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/archive"
+	"git.sr.ht/~kota/metservice-go/climate"
+)
+
+var nzst = time.FixedZone("NZST", 12*60*60)
+
+func at(hour int) *metservice.Timestamp {
+	return &metservice.Timestamp{Time: time.Date(2006, time.January, 2, hour, 0, 0, 0, nzst)}
+}
+
+func date(day int) time.Time {
+	return time.Date(2006, time.January, day, 0, 0, 0, 0, nzst)
+}
+
+func sameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func TestFromArchive(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+	a, err := archive.New(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Three hourly observations for the 1st and 2nd, but hourly ones for
+	// all of the 2nd.
+	for _, ts := range []time.Time{date(1).Add(9 * time.Hour), date(2).Add(9 * time.Hour)} {
+		err := a.StoreObservation(ctx, "Dunedin", &metservice.Observation{
+			ThreeHour: &metservice.ObservationThreeHour{
+				Date: &metservice.Timestamp{Time: ts},
+				Temp: metservice.Int(5),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var hours []metservice.ObservationHour
+	for h := 1; h <= 23; h++ {
+		hours = append(hours, metservice.ObservationHour{
+			Date: at(h),
+			Temp: metservice.Float64(10),
+		})
+	}
+	err = a.StoreObservationForecastHours(ctx, "Dunedin", &metservice.ObservationForecastHours{Observations: hours})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := climate.FromArchive(ctx, a, "Dunedin", date(1), date(3), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("got %d days, want 2", len(days))
+	}
+	if !sameDate(days[0].Date, date(1)) || *days[0].Mean != 5 {
+		t.Errorf("first day is %v with mean %v, want the three hourly 1st", days[0].Date, *days[0].Mean)
+	}
+	if !sameDate(days[1].Date, date(2)) || days[1].Count != 23 {
+		t.Errorf("second day is %v from %d observations, want the hourly 2nd", days[1].Date, days[1].Count)
+	}
+}
@@ -0,0 +1,8 @@
+// sqlitetest holds the tests of the archive and climate packages that need
+// a real SQLite database. It's a module of its own so that the library
+// doesn't depend on the cgo driver, and the tests only build with cgo.
+//
+// Run them from this directory with
+//
+//	go test
+package sqlitetest
@@ -0,0 +1,9 @@
+module git.sr.ht/~kota/metservice-go/archive/sqlitetest
+
+go 1.13
+
+require (
+	git.sr.ht/~kota/metservice-go v0.1.0
+	github.com/google/go-cmp v0.5.6
+	github.com/mattn/go-sqlite3 v1.14.33
+)
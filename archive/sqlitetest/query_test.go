@@ -0,0 +1,91 @@
+//go:build cgo
+// +build cgo
+
+package sqlitetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestArchive_queries(t *testing.T) {
+	a, _, teardown := open(t)
+	defer teardown()
+	ctx := context.Background()
+
+	var obs []*metservice.Observation
+	for i := 0; i < 3; i++ {
+		obs = append(obs, &metservice.Observation{
+			ThreeHour: &metservice.ObservationThreeHour{
+				Date: ts(time.Duration(i) * 24 * time.Hour),
+				Temp: metservice.Int(10 + i),
+			},
+		})
+	}
+	for _, o := range obs {
+		if err := a.StoreObservation(ctx, "Dunedin", o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.StoreObservation(ctx, "Nelson", obs[1]); err != nil {
+		t.Fatal(err)
+	}
+	err := a.StorePollen(ctx, "Dunedin", &metservice.Pollen{PollenDays: []metservice.PollenDay{
+		{ValidFrom: ts(0), Level: metservice.String("Low")},
+		{ValidFrom: ts(24 * time.Hour), Level: metservice.String("High")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The riseSet endpoint was stored last, so it replaces the forecast's.
+	err = a.StoreForecast(ctx, "Dunedin", &metservice.Forecast{Days: []metservice.ForecastDay{{
+		Date:    ts(0),
+		RiseSet: &metservice.RiseSet{Date: ts(0), SunRise: ts(6 * time.Hour)},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.StoreRiseSet(ctx, "Dunedin", &metservice.RiseSet{Date: ts(0), SunRise: ts(6*time.Hour + time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotObs, err := a.Observations(ctx, "Dunedin", referenceTime.Add(time.Hour), referenceTime.Add(72*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]metservice.Observation{*obs[1], *obs[2]}, gotObs); diff != "" {
+		t.Errorf("Observations mismatch (-want +got):\n%s", diff)
+	}
+
+	// The end of the range is excluded.
+	gotPollen, err := a.PollenDays(ctx, "Dunedin", referenceTime, referenceTime.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPollen := []metservice.PollenDay{{ValidFrom: ts(0), Level: metservice.String("Low")}}
+	if diff := cmp.Diff(wantPollen, gotPollen); diff != "" {
+		t.Errorf("PollenDays mismatch (-want +got):\n%s", diff)
+	}
+
+	gotRiseSets, err := a.RiseSets(ctx, "Dunedin", referenceTime, referenceTime.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRiseSets := []metservice.RiseSet{{Date: ts(0), SunRise: ts(6*time.Hour + time.Minute)}}
+	if diff := cmp.Diff(wantRiseSets, gotRiseSets); diff != "" {
+		t.Errorf("RiseSets mismatch (-want +got):\n%s", diff)
+	}
+
+	gotHours, err := a.ObservationHours(ctx, "Dunedin", referenceTime, referenceTime.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotHours) != 0 {
+		t.Errorf("ObservationHours returned %d hours, want 0", len(gotHours))
+	}
+}
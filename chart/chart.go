@@ -0,0 +1,355 @@
+// chart draws plain text charts of hourly metservice data for terminals and
+// chat messages.
+package chart
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Defaults used for unset Options fields.
+const (
+	DefaultWidth  = 80
+	DefaultHeight = 10
+)
+
+// Options controls the size and style of a chart.
+type Options struct {
+	// Width is the total width of the chart in columns, including the axis
+	// labels.
+	Width int
+	// Height is the number of rows in the plot area, not counting the axis
+	// and labels below it.
+	Height int
+	// ASCII limits the chart to ASCII characters.
+	ASCII bool
+	// Now is the time marked on the chart. The zero value uses the time of
+	// the last observation.
+	Now time.Time
+}
+
+func (o Options) withDefaults() Options {
+	if o.Width <= 0 {
+		o.Width = DefaultWidth
+	}
+	if o.Height <= 0 {
+		o.Height = DefaultHeight
+	}
+	return o
+}
+
+// glyphs are the characters used to draw a chart.
+type glyphs struct {
+	observedLine, forecastLine rune
+	observedBar, forecastBar   rune
+	yAxis, xAxis, corner, tick rune
+	now                        rune
+	arrows                     [8]rune // N, NE, E ... NW, pointing downwind
+}
+
+var unicodeGlyphs = glyphs{
+	observedLine: '●', forecastLine: '○',
+	observedBar: '█', forecastBar: '▒',
+	yAxis: '│', xAxis: '─', corner: '└', tick: '┤',
+	now:    '┊',
+	arrows: [8]rune{'↓', '↙', '←', '↖', '↑', '↗', '→', '↘'},
+}
+
+var asciiGlyphs = glyphs{
+	observedLine: '*', forecastLine: '+',
+	observedBar: '#', forecastBar: ':',
+	yAxis: '|', xAxis: '-', corner: '+', tick: '+',
+	now:    '!',
+	arrows: [8]rune{'v', '/', '<', '\\', '^', '/', '>', '\\'},
+}
+
+// point is a single value on the time axis.
+type point struct {
+	t        time.Time
+	v        float64
+	observed bool
+	dir      string
+}
+
+// Temperature returns a line chart of the observed and forecast temperature
+// in ofh.
+func Temperature(ofh *metservice.ObservationForecastHours, opts Options) string {
+	var points []point
+	for _, h := range ofh.Observations {
+		if h.Date != nil && h.Temp != nil {
+			points = append(points, point{t: h.Date.Time, v: *h.Temp, observed: true})
+		}
+	}
+	for _, h := range ofh.Forecasts {
+		if h.Date != nil && h.Temp != nil {
+			points = append(points, point{t: h.Date.Time, v: float64(*h.Temp)})
+		}
+	}
+	return render("Temperature (°C)", points, false, false, nowOf(ofh, opts), opts)
+}
+
+// Rainfall returns a bar chart of the observed and forecast hourly rainfall
+// in ofh.
+func Rainfall(ofh *metservice.ObservationForecastHours, opts Options) string {
+	var points []point
+	for _, h := range ofh.Observations {
+		if h.Date != nil && h.Rainfall != nil {
+			points = append(points, point{t: h.Date.Time, v: *h.Rainfall, observed: true})
+		}
+	}
+	for _, h := range ofh.Forecasts {
+		if h.Date != nil && h.Rainfall != nil {
+			points = append(points, point{t: h.Date.Time, v: *h.Rainfall})
+		}
+	}
+	return render("Rainfall (mm)", points, true, false, nowOf(ofh, opts), opts)
+}
+
+// Wind returns a line chart of the observed and forecast wind speed in ofh
+// with a row of arrows showing which way the wind is blowing.
+func Wind(ofh *metservice.ObservationForecastHours, opts Options) string {
+	var points []point
+	for _, h := range ofh.Observations {
+		if h.Date != nil && h.WindSpeed != nil {
+			points = append(points, point{t: h.Date.Time, v: float64(*h.WindSpeed), observed: true, dir: str(h.WindDirection)})
+		}
+	}
+	for _, h := range ofh.Forecasts {
+		if h.Date != nil && h.WindSpeed != nil {
+			points = append(points, point{t: h.Date.Time, v: float64(*h.WindSpeed), dir: str(h.WindDirection)})
+		}
+	}
+	return render("Wind (km/h)", points, false, true, nowOf(ofh, opts), opts)
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// nowOf returns opts.Now or the time of the last observation in ofh.
+func nowOf(ofh *metservice.ObservationForecastHours, opts Options) time.Time {
+	if !opts.Now.IsZero() {
+		return opts.Now
+	}
+	for i := len(ofh.Observations) - 1; i >= 0; i-- {
+		if d := ofh.Observations[i].Date; d != nil {
+			return d.Time
+		}
+	}
+	return time.Time{}
+}
+
+// render draws points as a bar or line chart.
+func render(title string, points []point, bars, arrows bool, now time.Time, opts Options) string {
+	opts = opts.withDefaults()
+	g := unicodeGlyphs
+	if opts.ASCII {
+		g = asciiGlyphs
+	}
+	var b strings.Builder
+	b.WriteString(title)
+	b.WriteByte('\n')
+	if len(points) == 0 {
+		b.WriteString("no data\n")
+		return b.String()
+	}
+
+	lo, hi := points[0].v, points[0].v
+	for _, p := range points {
+		lo, hi = math.Min(lo, p.v), math.Max(hi, p.v)
+	}
+	if bars {
+		lo = math.Min(lo, 0)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+
+	height := opts.Height
+	mid := (height - 1) / 2
+
+	// The axis labels take the width of the widest label plus the tick.
+	midValue := lo
+	if height > 1 {
+		midValue = lo + (hi-lo)*float64(mid)/float64(height-1)
+	}
+	labels := []string{format(hi), format(midValue), format(lo)}
+	labelWidth := 0
+	for _, l := range labels {
+		if len(l) > labelWidth {
+			labelWidth = len(l)
+		}
+	}
+	width := opts.Width - labelWidth - 1
+	if width < 1 {
+		width = 1
+	}
+
+	// Pick the point nearest to each column.
+	start, end := points[0].t, points[len(points)-1].t
+	span := end.Sub(start)
+	cols := make([]*point, width)
+	timeAt := func(c int) time.Time {
+		if width == 1 {
+			return start
+		}
+		return start.Add(time.Duration(float64(span) * float64(c) / float64(width-1)))
+	}
+	j := 0
+	for c := range cols {
+		t := timeAt(c)
+		for j+1 < len(points) && absDur(points[j+1].t.Sub(t)) <= absDur(points[j].t.Sub(t)) {
+			j++
+		}
+		cols[c] = &points[j]
+	}
+	nowCol := -1
+	if !now.IsZero() && !now.Before(start) && !now.After(end) && span > 0 {
+		nowCol = int(math.Round(float64(now.Sub(start)) / float64(span) * float64(width-1)))
+	}
+
+	row := func(v float64) int {
+		return int(math.Round((v - lo) / (hi - lo) * float64(height-1)))
+	}
+	grid := make([][]rune, height)
+	for r := range grid {
+		grid[r] = []rune(strings.Repeat(" ", width))
+		if nowCol >= 0 {
+			grid[r][nowCol] = g.now
+		}
+	}
+	prev := -1
+	for c, p := range cols {
+		r := row(p.v)
+		glyph := g.forecastLine
+		if bars {
+			glyph = g.forecastBar
+		}
+		if p.observed {
+			glyph = g.observedLine
+			if bars {
+				glyph = g.observedBar
+			}
+		}
+		switch {
+		case bars:
+			if p.v > 0 {
+				for y := row(0); y <= r; y++ {
+					grid[y][c] = glyph
+				}
+			}
+		default:
+			// Join to the previous column so steep changes stay connected.
+			from, to := r, r
+			if prev >= 0 {
+				if prev < r {
+					from = prev + 1
+				} else if prev > r {
+					to = prev - 1
+				}
+			}
+			for y := from; y <= to; y++ {
+				grid[y][c] = glyph
+			}
+			prev = r
+		}
+	}
+
+	for r := height - 1; r >= 0; r-- {
+		label := ""
+		switch r {
+		case height - 1:
+			label = labels[0]
+		case 0:
+			label = labels[2]
+		case mid:
+			label = labels[1]
+		}
+		axis := g.yAxis
+		if label != "" {
+			axis = g.tick
+		}
+		fmt.Fprintf(&b, "%*s%c%s\n", labelWidth, label, axis, strings.TrimRight(string(grid[r]), " "))
+	}
+	fmt.Fprintf(&b, "%s%c%s\n", strings.Repeat(" ", labelWidth), g.corner, strings.Repeat(string(g.xAxis), width))
+
+	if arrows {
+		line := []rune(strings.Repeat(" ", width))
+		for c, p := range cols {
+			if a, ok := arrow(g, p.dir); ok && (c == 0 || cols[c-1] != p) {
+				line[c] = a
+			}
+		}
+		fmt.Fprintf(&b, "%s %s\n", strings.Repeat(" ", labelWidth), strings.TrimRight(string(line), " "))
+	}
+
+	// Time labels every six hours, plus the now marker.
+	axis := []rune(strings.Repeat(" ", width))
+	put := func(c int, s string) {
+		r := []rune(s)
+		if c+len(r) > width {
+			return
+		}
+		// Keep a gap of at least one column between labels.
+		for i := c - 1; i <= c+len(r); i++ {
+			if i >= 0 && i < width && axis[i] != ' ' {
+				return
+			}
+		}
+		copy(axis[c:], r)
+	}
+	if nowCol >= 0 {
+		put(nowCol, "now")
+	}
+	for c, p := range cols {
+		if p.t.Minute() == 0 && p.t.Hour()%6 == 0 && (c == 0 || cols[c-1] != p) {
+			label := p.t.Format("15h")
+			if p.t.Hour() == 0 {
+				label = p.t.Format("Mon")
+			}
+			put(c, label)
+		}
+	}
+	fmt.Fprintf(&b, "%s %s\n", strings.Repeat(" ", labelWidth), strings.TrimRight(string(axis), " "))
+
+	key := fmt.Sprintf("%c observed  %c forecast", g.observedLine, g.forecastLine)
+	if bars {
+		key = fmt.Sprintf("%c observed  %c forecast", g.observedBar, g.forecastBar)
+	}
+	fmt.Fprintf(&b, "%s %s\n", strings.Repeat(" ", labelWidth), key)
+	return b.String()
+}
+
+func format(v float64) string {
+	return fmt.Sprintf("%.4g", v)
+}
+
+func absDur(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// compass lists the 16 wind directions in clockwise order from north.
+var compass = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// arrow returns the arrow pointing the way a wind from dir blows.
+func arrow(g glyphs, dir string) (rune, bool) {
+	dir = strings.ToUpper(strings.TrimSpace(dir))
+	for i, c := range compass {
+		if c == dir {
+			// Round the 16 point direction to one of 8 arrows.
+			return g.arrows[((i+1)/2)%8], true
+		}
+	}
+	return 0, false
+}
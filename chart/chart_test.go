@@ -0,0 +1,79 @@
+package chart
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+var referenceTime = time.Date(2006, time.January, 02, 12, 0, 0, 0, time.UTC)
+
+func testHours() *metservice.ObservationForecastHours {
+	ofh := new(metservice.ObservationForecastHours)
+	obs := []float64{10, 12, 14}
+	for i, v := range obs {
+		ofh.Observations = append(ofh.Observations, metservice.ObservationHour{
+			Date:          &metservice.Timestamp{Time: referenceTime.Add(time.Duration(i) * time.Hour)},
+			Temp:          metservice.Float64(v),
+			Rainfall:      metservice.Float64(float64(i)),
+			WindSpeed:     metservice.Int(10),
+			WindDirection: metservice.String("SW"),
+		})
+	}
+	fc := []int{13, 12, 10}
+	for i, v := range fc {
+		ofh.Forecasts = append(ofh.Forecasts, metservice.ForecastHour{
+			Date:          &metservice.Timestamp{Time: referenceTime.Add(time.Duration(i+3) * time.Hour)},
+			Temp:          metservice.Int(v),
+			Rainfall:      metservice.Float64(0),
+			WindSpeed:     metservice.Int(20),
+			WindDirection: metservice.String("N"),
+		})
+	}
+	return ofh
+}
+
+func TestTemperature(t *testing.T) {
+	got := Temperature(testHours(), Options{Width: 9, Height: 3, ASCII: true})
+	want := `Temperature (°C)
+14+  *+
+12+ *! +
+10+* !  +
+  +------
+     now
+   * observed  + forecast
+`
+	if got != want {
+		t.Errorf("Temperature got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRainfall(t *testing.T) {
+	got := Rainfall(testHours(), Options{Width: 8, Height: 2})
+	want := `Rainfall (mm)
+2┤ ██
+0┤ ██
+ └──────
+    now
+  █ observed  ▒ forecast
+`
+	if got != want {
+		t.Errorf("Rainfall got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWind(t *testing.T) {
+	got := Wind(testHours(), Options{Width: 9, Height: 2, Now: referenceTime.Add(4 * time.Hour)})
+	if !strings.Contains(got, "\n   ↗↗↗↓↓↓\n") {
+		t.Errorf("Wind arrows missing from:\n%s", got)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	got := Temperature(&metservice.ObservationForecastHours{}, Options{})
+	if got != "Temperature (°C)\nno data\n" {
+		t.Errorf("Temperature of no data = %q", got)
+	}
+}
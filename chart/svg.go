@@ -0,0 +1,318 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Theme holds the colours used by Meteogram. Colours are any value SVG
+// accepts, such as "#ff0000" or "red".
+type Theme struct {
+	Background string
+	Text       string
+	Grid       string
+	Night      string
+	Temp       string
+	Rain       string
+	Wind       string
+}
+
+// Themes for Meteogram.
+var (
+	LightTheme = Theme{
+		Background: "#ffffff",
+		Text:       "#222222",
+		Grid:       "#dddddd",
+		Night:      "#e8ecf4",
+		Temp:       "#d62728",
+		Rain:       "#1f77b4",
+		Wind:       "#555555",
+	}
+	DarkTheme = Theme{
+		Background: "#1e1e1e",
+		Text:       "#dddddd",
+		Grid:       "#3a3a3a",
+		Night:      "#262a36",
+		Temp:       "#ff6b6b",
+		Rain:       "#4fa3e0",
+		Wind:       "#aaaaaa",
+	}
+)
+
+// SVGOptions controls the size and colours of a meteogram.
+type SVGOptions struct {
+	// Width and Height are the size of the image in pixels, defaulting to
+	// 800 by 400. Sizes below 160 by 120 are raised to that, leaving room
+	// for the labels around the plot.
+	Width  int
+	Height int
+	// Theme defaults to LightTheme.
+	Theme *Theme
+	// Now is the time marked on the chart. The zero value uses the time of
+	// the last observation.
+	Now time.Time
+}
+
+// The smallest image Meteogram draws.
+const (
+	minWidth  = 160
+	minHeight = 120
+)
+
+// Meteogram writes a self-contained SVG meteogram of ofh to w: a temperature
+// line, rainfall bars and wind arrows along the bottom. Observed values are
+// drawn solid and forecasts faded or dashed. If rs is not nil its sunrise and
+// sunset, repeated for each day shown, are used to shade the nights.
+func Meteogram(w io.Writer, ofh *metservice.ObservationForecastHours, rs *metservice.RiseSet, opts SVGOptions) error {
+	if opts.Width <= 0 {
+		opts.Width = 800
+	} else if opts.Width < minWidth {
+		opts.Width = minWidth
+	}
+	if opts.Height <= 0 {
+		opts.Height = 400
+	} else if opts.Height < minHeight {
+		opts.Height = minHeight
+	}
+	theme := LightTheme
+	if opts.Theme != nil {
+		theme = *opts.Theme
+	}
+
+	var points []meteoPoint
+	for _, h := range ofh.Observations {
+		if h.Date == nil {
+			continue
+		}
+		points = append(points, meteoPoint{
+			t: h.Date.Time, temp: h.Temp, rain: h.Rainfall,
+			wind: h.WindSpeed, dir: str(h.WindDirection), observed: true,
+		})
+	}
+	for _, h := range ofh.Forecasts {
+		if h.Date == nil {
+			continue
+		}
+		p := meteoPoint{t: h.Date.Time, rain: h.Rainfall, wind: h.WindSpeed, dir: str(h.WindDirection)}
+		if h.Temp != nil {
+			p.temp = metservice.Float64(float64(*h.Temp))
+		}
+		points = append(points, p)
+	}
+
+	var b bytes.Buffer
+	W, H := float64(opts.Width), float64(opts.Height)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		opts.Width, opts.Height, opts.Width, opts.Height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", attr(theme.Background))
+	if len(points) < 2 || !points[len(points)-1].t.After(points[0].t) {
+		fmt.Fprintf(&b, `<text x="%g" y="%g" fill="%s" text-anchor="middle">no data</text>`+"\n", W/2, H/2, attr(theme.Text))
+		b.WriteString("</svg>\n")
+		_, err := b.WriteTo(w)
+		return err
+	}
+
+	// Plot area, leaving room for axis labels and the wind row.
+	const left, right, top = 40.0, 40.0, 15.0
+	windRow := 30.0
+	bottom := windRow + 20
+	pw, ph := W-left-right, H-top-bottom
+	start, end := points[0].t, points[len(points)-1].t
+	span := float64(end.Sub(start))
+	x := func(t time.Time) float64 {
+		return left + float64(t.Sub(start))/span*pw
+	}
+
+	// Night shading.
+	if rs != nil && rs.SunRise != nil && rs.SunSet != nil {
+		for _, n := range nights(rs.SunRise.Time, rs.SunSet.Time, start, end) {
+			x0, x1 := math.Max(x(n[0]), left), math.Min(x(n[1]), left+pw)
+			if x1 > x0 {
+				fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n",
+					x0, top, x1-x0, ph, attr(theme.Night))
+			}
+		}
+	}
+
+	// Temperature scale on the left, rain on the right.
+	tlo, thi := math.Inf(1), math.Inf(-1)
+	rhi := 1.0
+	for _, p := range points {
+		if p.temp != nil {
+			tlo, thi = math.Min(tlo, *p.temp), math.Max(thi, *p.temp)
+		}
+		if p.rain != nil {
+			rhi = math.Max(rhi, *p.rain)
+		}
+	}
+	if math.IsInf(tlo, 0) {
+		tlo, thi = 0, 1
+	}
+	tlo, thi = math.Floor(tlo-1), math.Ceil(thi+1)
+	ty := func(v float64) float64 { return top + ph - (v-tlo)/(thi-tlo)*ph }
+	ry := func(v float64) float64 { return top + ph - v/rhi*ph }
+
+	// Grid and axis labels.
+	for i := 0; i <= 4; i++ {
+		y := top + ph*float64(i)/4
+		fmt.Fprintf(&b, `<line x1="%g" y1="%.1f" x2="%g" y2="%.1f" stroke="%s"/>`+"\n", left, y, left+pw, y, attr(theme.Grid))
+		fmt.Fprintf(&b, `<text x="%g" y="%.1f" fill="%s" text-anchor="end">%.3g°</text>`+"\n",
+			left-4, y+4, attr(theme.Temp), thi-(thi-tlo)*float64(i)/4)
+		fmt.Fprintf(&b, `<text x="%g" y="%.1f" fill="%s">%.2gmm</text>`+"\n",
+			left+pw+4, y+4, attr(theme.Rain), rhi-rhi*float64(i)/4)
+	}
+	for t := start.Truncate(time.Hour); !t.After(end); t = t.Add(time.Hour) {
+		if t.Before(start) || t.Hour()%6 != 0 {
+			continue
+		}
+		label := t.Format("15:04")
+		if t.Hour() == 0 {
+			label = t.Format("Mon 2")
+		}
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%g" x2="%.1f" y2="%g" stroke="%s"/>`+"\n", x(t), top, x(t), top+ph, attr(theme.Grid))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%g" fill="%s" text-anchor="middle">%s</text>`+"\n",
+			x(t), H-5, attr(theme.Text), html.EscapeString(label))
+	}
+
+	// Rain bars.
+	barWidth := pw / float64(len(points)) * 0.8
+	for _, p := range points {
+		if p.rain == nil || *p.rain <= 0 {
+			continue
+		}
+		opacity := 0.5
+		if p.observed {
+			opacity = 0.9
+		}
+		y := ry(*p.rain)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" fill-opacity="%g"/>`+"\n",
+			x(p.t)-barWidth/2, y, barWidth, top+ph-y, attr(theme.Rain), opacity)
+	}
+
+	// Temperature line, solid while observed and dashed once forecast. The
+	// forecast line starts at the last observation so the two join up.
+	var observed, forecast []string
+	for i, p := range points {
+		if p.temp == nil {
+			continue
+		}
+		xy := fmt.Sprintf("%.1f,%.1f", x(p.t), ty(*p.temp))
+		if p.observed {
+			observed = append(observed, xy)
+			if i+1 < len(points) && !points[i+1].observed {
+				forecast = append(forecast, xy)
+			}
+		} else {
+			forecast = append(forecast, xy)
+		}
+	}
+	polyline(&b, observed, theme.Temp, "")
+	polyline(&b, forecast, theme.Temp, ` stroke-dasharray="6 4"`)
+
+	// Wind arrows, pointing downwind and labelled with the speed.
+	wy := top + ph + windRow/2
+	step := int(math.Ceil(float64(len(points)) * 28 / pw))
+	if step < 1 {
+		step = 1
+	}
+	for i := 0; i < len(points); i += step {
+		p := points[i]
+		deg, ok := bearing(p.dir)
+		if !ok {
+			continue
+		}
+		opacity := 0.6
+		if p.observed {
+			opacity = 1
+		}
+		fmt.Fprintf(&b, `<g transform="translate(%.1f,%.1f) rotate(%g)" fill="%s" fill-opacity="%g"><path d="M0,-7 L-4,5 L0,2 L4,5 Z"/></g>`+"\n",
+			x(p.t), wy, deg, attr(theme.Wind), opacity)
+		if p.wind != nil {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="%s" font-size="9" text-anchor="middle">%d</text>`+"\n",
+				x(p.t), wy+17, attr(theme.Wind), *p.wind)
+		}
+	}
+
+	// Now marker.
+	now := opts.Now
+	if now.IsZero() {
+		now = nowOf(ofh, Options{})
+	}
+	if !now.IsZero() && !now.Before(start) && !now.After(end) {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%g" x2="%.1f" y2="%g" stroke="%s" stroke-dasharray="2 2"/>`+"\n",
+			x(now), top, x(now), top+ph, attr(theme.Text))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%g" fill="%s" text-anchor="middle">now</text>`+"\n",
+			x(now), top-3, attr(theme.Text))
+	}
+
+	b.WriteString("</svg>\n")
+	_, err := b.WriteTo(w)
+	return err
+}
+
+type meteoPoint struct {
+	t        time.Time
+	temp     *float64
+	rain     *float64
+	wind     *int
+	dir      string
+	observed bool
+}
+
+func polyline(b *bytes.Buffer, points []string, colour, extra string) {
+	if len(points) < 2 {
+		return
+	}
+	fmt.Fprintf(b, `<polyline points="`)
+	for i, p := range points {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p)
+	}
+	fmt.Fprintf(b, `" fill="none" stroke="%s" stroke-width="2"%s/>`+"\n", attr(colour), extra)
+}
+
+func attr(s string) string {
+	return html.EscapeString(s)
+}
+
+// nights returns the night intervals overlapping [start, end], assuming the
+// sun rises and sets at the same time of day as sunrise and sunset.
+func nights(sunrise, sunset, start, end time.Time) [][2]time.Time {
+	var out [][2]time.Time
+	// Shift the sunset to the day before start and walk forward.
+	days := int(math.Floor(start.Sub(sunset).Hours()/24)) - 1
+	set := sunset.AddDate(0, 0, days)
+	rise := sunrise.AddDate(0, 0, days)
+	for !rise.After(set) {
+		rise = rise.AddDate(0, 0, 1)
+	}
+	for set.Before(end) {
+		if rise.After(start) {
+			out = append(out, [2]time.Time{set, rise})
+		}
+		set = set.AddDate(0, 0, 1)
+		rise = rise.AddDate(0, 0, 1)
+	}
+	return out
+}
+
+// bearing returns the direction in degrees clockwise from north that a wind
+// from dir blows towards.
+func bearing(dir string) (float64, bool) {
+	dir = strings.ToUpper(strings.TrimSpace(dir))
+	for i, c := range compass {
+		if c == dir {
+			return math.Mod(float64(i)*22.5+180, 360), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,112 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+func TestMeteogram(t *testing.T) {
+	ofh := new(metservice.ObservationForecastHours)
+	for i := 0; i < 6; i++ {
+		ofh.Observations = append(ofh.Observations, metservice.ObservationHour{
+			Date:          &metservice.Timestamp{Time: referenceTime.Add(time.Duration(i) * time.Hour)},
+			Temp:          metservice.Float64(10 + float64(i)),
+			Rainfall:      metservice.Float64(1),
+			WindSpeed:     metservice.Int(10),
+			WindDirection: metservice.String("S"),
+		})
+	}
+	for i := 6; i < 48; i++ {
+		ofh.Forecasts = append(ofh.Forecasts, metservice.ForecastHour{
+			Date:          &metservice.Timestamp{Time: referenceTime.Add(time.Duration(i) * time.Hour)},
+			Temp:          metservice.Int(12),
+			Rainfall:      metservice.Float64(0),
+			WindSpeed:     metservice.Int(20),
+			WindDirection: metservice.String("NW"),
+		})
+	}
+	day := referenceTime.Truncate(24 * time.Hour)
+	rs := &metservice.RiseSet{
+		SunRise: &metservice.Timestamp{Time: day.Add(6 * time.Hour)},
+		SunSet:  &metservice.Timestamp{Time: day.Add(21 * time.Hour)},
+	}
+
+	var b bytes.Buffer
+	if err := Meteogram(&b, ofh, rs, SVGOptions{Width: 600, Height: 300, Theme: &DarkTheme}); err != nil {
+		t.Fatalf("Meteogram returned error: %v", err)
+	}
+
+	// The output must be well formed XML.
+	counts := make(map[string]int)
+	fills := make(map[string]int)
+	dec := xml.NewDecoder(bytes.NewReader(b.Bytes()))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, b.String())
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			counts[se.Name.Local]++
+			for _, a := range se.Attr {
+				if a.Name.Local == "fill" {
+					fills[a.Value]++
+				}
+			}
+		}
+	}
+
+	if counts["polyline"] != 2 {
+		t.Errorf("got %d polylines, want observed and forecast", counts["polyline"])
+	}
+	// The chart runs from 12:00 on the 2nd to 11:00 on the 4th, so it
+	// covers two nights.
+	if fills[DarkTheme.Night] != 2 {
+		t.Errorf("got %d night rects, want 2", fills[DarkTheme.Night])
+	}
+	if !strings.Contains(b.String(), `stroke-dasharray="6 4"`) {
+		t.Error("forecast temperature line is not dashed")
+	}
+	if !strings.Contains(b.String(), `rotate(0)`) || !strings.Contains(b.String(), `rotate(135)`) {
+		t.Error("wind arrows not rotated for S and NW winds")
+	}
+	if !strings.Contains(b.String(), ">now</text>") {
+		t.Error("missing now marker")
+	}
+}
+
+func TestMeteogram_Small(t *testing.T) {
+	ofh := &metservice.ObservationForecastHours{
+		Observations: []metservice.ObservationHour{
+			{Date: &metservice.Timestamp{Time: referenceTime}, Temp: metservice.Float64(10)},
+			{Date: &metservice.Timestamp{Time: referenceTime.Add(time.Hour)}, Temp: metservice.Float64(12)},
+		},
+	}
+	var b bytes.Buffer
+	if err := Meteogram(&b, ofh, nil, SVGOptions{Width: 50, Height: 20}); err != nil {
+		t.Fatalf("Meteogram returned error: %v", err)
+	}
+	got := b.String()
+	if !strings.Contains(got, `width="160" height="120"`) {
+		t.Errorf("small meteogram wasn't raised to the minimum size:\n%s", got)
+	}
+	if strings.Contains(got, `width="-`) || strings.Contains(got, `height="-`) {
+		t.Errorf("small meteogram has negative widths:\n%s", got)
+	}
+}
+
+func TestNights(t *testing.T) {
+	day := referenceTime.Truncate(24 * time.Hour)
+	got := nights(day.Add(6*time.Hour), day.Add(21*time.Hour), day.Add(12*time.Hour), day.Add(36*time.Hour))
+	if len(got) != 1 || !got[0][0].Equal(day.Add(21*time.Hour)) || !got[0][1].Equal(day.Add(30*time.Hour)) {
+		t.Errorf("nights = %v", got)
+	}
+}
@@ -0,0 +1,62 @@
+package climate
+
+import (
+	"context"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/archive"
+)
+
+// FromArchive summarises the archived observations for location taken in
+// [from, to) by day. Each day uses either the hourly or the three hourly
+// observations, whichever covers more of it, as the hourly ones are only
+// archived for days the archiver was running for.
+func FromArchive(ctx context.Context, a *archive.Archive, location string, from, to time.Time, opts *Options) ([]Day, error) {
+	hours, err := a.ObservationHours(ctx, location, from, to)
+	if err != nil {
+		return nil, err
+	}
+	obs, err := a.Observations(ctx, location, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var threeHours []metservice.ObservationThreeHour
+	for _, o := range obs {
+		if o.ThreeHour != nil {
+			threeHours = append(threeHours, *o.ThreeHour)
+		}
+	}
+
+	hourly := Days(hours, opts)
+	threeHourly := DaysThreeHour(threeHours, opts)
+
+	// Merge the two lists, which are both in date order.
+	var out []Day
+	for len(hourly) > 0 || len(threeHourly) > 0 {
+		switch {
+		case len(threeHourly) == 0:
+			out, hourly = append(out, hourly[0]), hourly[1:]
+		case len(hourly) == 0:
+			out, threeHourly = append(out, threeHourly[0]), threeHourly[1:]
+		case sameDate(hourly[0].Date, threeHourly[0].Date):
+			if hourly[0].Count >= 3*threeHourly[0].Count {
+				out = append(out, hourly[0])
+			} else {
+				out = append(out, threeHourly[0])
+			}
+			hourly, threeHourly = hourly[1:], threeHourly[1:]
+		case hourly[0].Date.Before(threeHourly[0].Date):
+			out, hourly = append(out, hourly[0]), hourly[1:]
+		default:
+			out, threeHourly = append(out, threeHourly[0]), threeHourly[1:]
+		}
+	}
+	return out, nil
+}
+
+func sameDate(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
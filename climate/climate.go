@@ -0,0 +1,213 @@
+// climate summarises observations into daily and monthly climate
+// statistics, such as the temperature range, rainfall, rain days and
+// degree-days of each day.
+package climate
+
+import (
+	"sort"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Defaults used by a nil *Options.
+const (
+	DefaultHeatingBase = 18.0
+	DefaultCoolingBase = 18.0
+	DefaultGrowingBase = 10.0
+	DefaultRainDay     = 0.2
+)
+
+// Options controls how days are summarised. A nil *Options uses the
+// defaults.
+type Options struct {
+	// Location decides which day each observation falls on. Nil uses the
+	// zone of each observation's time, which is New Zealand time for data
+	// from the API.
+	Location *time.Location
+	// HeatingBase, CoolingBase and GrowingBase are the temperatures, in
+	// °C, that degree-days are counted from. Zero uses the defaults.
+	HeatingBase float64
+	CoolingBase float64
+	GrowingBase float64
+	// RainDay is the rainfall, in mm, needed for a day to count as a rain
+	// day. Zero uses DefaultRainDay.
+	RainDay float64
+}
+
+func (o *Options) location() *time.Location {
+	if o == nil {
+		return nil
+	}
+	return o.Location
+}
+
+func (o *Options) heatingBase() float64 {
+	if o == nil || o.HeatingBase == 0 {
+		return DefaultHeatingBase
+	}
+	return o.HeatingBase
+}
+
+func (o *Options) coolingBase() float64 {
+	if o == nil || o.CoolingBase == 0 {
+		return DefaultCoolingBase
+	}
+	return o.CoolingBase
+}
+
+func (o *Options) growingBase() float64 {
+	if o == nil || o.GrowingBase == 0 {
+		return DefaultGrowingBase
+	}
+	return o.GrowingBase
+}
+
+func (o *Options) rainDay() float64 {
+	if o == nil || o.RainDay == 0 {
+		return DefaultRainDay
+	}
+	return o.RainDay
+}
+
+// Day is the climate of a single day. Fields are nil where no observation
+// had the value.
+type Day struct {
+	// Date is midnight at the start of the day.
+	Date time.Time
+	// Count is the number of observations taken during the day. It may be
+	// zero for a day that only has the rain measured at its end.
+	Count int
+
+	// Min, Max and Mean temperatures in °C. Mean is the average of the
+	// observations.
+	Min  *float64
+	Max  *float64
+	Mean *float64
+	// Rainfall is the total in mm.
+	Rainfall *float64
+	RainDay  bool
+	// MaxWind is the highest wind speed in km/h. Gusts aren't reported by
+	// the API, so this is the highest sustained speed.
+	MaxWind *int
+
+	// Degree-days, counted from the midpoint of Min and Max.
+	HeatingDegrees *float64
+	CoolingDegrees *float64
+	GrowingDegrees *float64
+}
+
+// reading is a single observation of any kind.
+type reading struct {
+	time time.Time
+	temp *float64
+	// rain fell in the period before time.
+	rain *float64
+	wind *int
+}
+
+// Days summarises hourly observations by day, in date order. Each hour's
+// rainfall is counted on the day the hour ended.
+func Days(hours []metservice.ObservationHour, opts *Options) []Day {
+	rs := make([]reading, 0, len(hours))
+	for _, h := range hours {
+		if h.Date == nil {
+			continue
+		}
+		rs = append(rs, reading{time: h.Date.Time, temp: h.Temp, rain: h.Rainfall, wind: h.WindSpeed})
+	}
+	return summarise(rs, opts)
+}
+
+// DaysThreeHour summarises three hourly observations by day, in date order.
+// The rainfall of each is taken as falling in the three hours before it.
+func DaysThreeHour(obs []metservice.ObservationThreeHour, opts *Options) []Day {
+	rs := make([]reading, 0, len(obs))
+	for _, o := range obs {
+		if o.Date == nil {
+			continue
+		}
+		r := reading{time: o.Date.Time, rain: o.Rainfall, wind: o.WindSpeed}
+		if o.Temp != nil {
+			t := float64(*o.Temp)
+			r.temp = &t
+		}
+		rs = append(rs, r)
+	}
+	return summarise(rs, opts)
+}
+
+// day accumulates the readings of a Day.
+type day struct {
+	Day
+	tempSum float64
+	temps   int
+}
+
+func summarise(rs []reading, opts *Options) []Day {
+	// Days are keyed by their calendar date, as the offset changes part way
+	// through a day when daylight saving starts or ends.
+	days := make(map[[3]int]*day)
+	get := func(t time.Time) *day {
+		if loc := opts.location(); loc != nil {
+			t = t.In(loc)
+		}
+		y, m, d := t.Date()
+		key := [3]int{y, int(m), d}
+		if days[key] == nil {
+			days[key] = &day{Day: Day{Date: time.Date(y, m, d, 0, 0, 0, 0, t.Location())}}
+		}
+		return days[key]
+	}
+
+	for _, r := range rs {
+		d := get(r.time)
+		d.Count++
+		if r.temp != nil {
+			t := *r.temp
+			if d.Min == nil || t < *d.Min {
+				d.Min = metservice.Float64(t)
+			}
+			if d.Max == nil || t > *d.Max {
+				d.Max = metservice.Float64(t)
+			}
+			d.tempSum += t
+			d.temps++
+		}
+		if r.wind != nil && (d.MaxWind == nil || *r.wind > *d.MaxWind) {
+			d.MaxWind = metservice.Int(*r.wind)
+		}
+		if r.rain != nil {
+			// Rain at midnight fell the day before.
+			rd := get(r.time.Add(-time.Nanosecond))
+			if rd.Rainfall == nil {
+				rd.Rainfall = metservice.Float64(0)
+			}
+			*rd.Rainfall += *r.rain
+		}
+	}
+
+	out := make([]Day, 0, len(days))
+	for _, d := range days {
+		if d.temps > 0 {
+			d.Mean = metservice.Float64(d.tempSum / float64(d.temps))
+			mid := (*d.Min + *d.Max) / 2
+			d.HeatingDegrees = metservice.Float64(positive(opts.heatingBase() - mid))
+			d.CoolingDegrees = metservice.Float64(positive(mid - opts.coolingBase()))
+			d.GrowingDegrees = metservice.Float64(positive(mid - opts.growingBase()))
+		}
+		d.RainDay = d.Rainfall != nil && *d.Rainfall >= opts.rainDay()
+		out = append(out, d.Day)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Date.Before(out[j].Date)
+	})
+	return out
+}
+
+func positive(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,114 @@
+package climate
+
+import (
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+var nzst = time.FixedZone("NZST", 12*60*60)
+
+// at returns a time on 2 January 2006 in New Zealand time.
+func at(hour int) *metservice.Timestamp {
+	return &metservice.Timestamp{Time: time.Date(2006, time.January, 2, hour, 0, 0, 0, nzst)}
+}
+
+func date(day int) time.Time {
+	return time.Date(2006, time.January, day, 0, 0, 0, 0, nzst)
+}
+
+func TestDays(t *testing.T) {
+	hours := []metservice.ObservationHour{
+		{Date: at(0), Temp: metservice.Float64(8), Rainfall: metservice.Float64(1.5)},
+		{Date: at(6), Temp: metservice.Float64(6), Rainfall: metservice.Float64(0.1), WindSpeed: metservice.Int(20)},
+		{Date: at(12), Temp: metservice.Float64(15), WindSpeed: metservice.Int(35)},
+		{Date: at(18), Temp: metservice.Float64(11), Rainfall: metservice.Float64(0)},
+		{Date: nil, Temp: metservice.Float64(40)},
+		{Date: at(24), Temp: metservice.Float64(30), Rainfall: metservice.Float64(0.2)},
+	}
+	want := []Day{
+		{
+			Date:     date(1),
+			Rainfall: metservice.Float64(1.5),
+			RainDay:  true,
+		},
+		{
+			Date:           date(2),
+			Count:          4,
+			Min:            metservice.Float64(6),
+			Max:            metservice.Float64(15),
+			Mean:           metservice.Float64(10),
+			Rainfall:       metservice.Float64(0.3),
+			RainDay:        true,
+			MaxWind:        metservice.Int(35),
+			HeatingDegrees: metservice.Float64(7.5),
+			CoolingDegrees: metservice.Float64(0),
+			GrowingDegrees: metservice.Float64(0.5),
+		},
+		{
+			Date:           date(3),
+			Count:          1,
+			Min:            metservice.Float64(30),
+			Max:            metservice.Float64(30),
+			Mean:           metservice.Float64(30),
+			HeatingDegrees: metservice.Float64(0),
+			CoolingDegrees: metservice.Float64(12),
+			GrowingDegrees: metservice.Float64(20),
+		},
+	}
+	got := Days(hours, nil)
+	if diff := cmp.Diff(want, got, approx); diff != "" {
+		t.Errorf("Days mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// approx compares floats closely enough to ignore rounding of sums.
+var approx = cmp.Comparer(func(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+})
+
+func TestDaysThreeHour_options(t *testing.T) {
+	obs := []metservice.ObservationThreeHour{
+		{Date: at(9), Temp: metservice.Int(12), Rainfall: metservice.Float64(0.4)},
+		{Date: at(11), Temp: metservice.Int(20)},
+	}
+	opts := &Options{
+		Location:    time.UTC,
+		HeatingBase: 15,
+		GrowingBase: 5,
+		RainDay:     1,
+	}
+	// Both are the day before in UTC.
+	want := []Day{
+		{
+			Date:           time.Date(2006, time.January, 1, 0, 0, 0, 0, time.UTC),
+			Count:          2,
+			Min:            metservice.Float64(12),
+			Max:            metservice.Float64(20),
+			Mean:           metservice.Float64(16),
+			Rainfall:       metservice.Float64(0.4),
+			HeatingDegrees: metservice.Float64(0),
+			CoolingDegrees: metservice.Float64(0),
+			GrowingDegrees: metservice.Float64(11),
+		},
+	}
+	got := DaysThreeHour(obs, opts)
+	if diff := cmp.Diff(want, got, approx); diff != "" {
+		t.Errorf("DaysThreeHour mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDays_daylightSaving(t *testing.T) {
+	nzdt := time.FixedZone("NZDT", 13*60*60)
+	hours := []metservice.ObservationHour{
+		{Date: &metservice.Timestamp{Time: time.Date(2006, time.October, 1, 1, 0, 0, 0, nzst)}},
+		{Date: &metservice.Timestamp{Time: time.Date(2006, time.October, 1, 4, 0, 0, 0, nzdt)}},
+	}
+	got := Days(hours, nil)
+	if len(got) != 1 || got[0].Count != 2 {
+		t.Errorf("got %d days, want a single day with both hours", len(got))
+	}
+}
@@ -0,0 +1,115 @@
+package climate
+
+import (
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Month is the climate of a calendar month, made from its days. Fields are
+// nil where none of the days had the value.
+type Month struct {
+	// Month is midnight at the start of the first day of the month.
+	Month time.Time
+	// Days is the number of days summarised.
+	Days int
+
+	// Min and Max are the extremes of the month. MeanMin and MeanMax are
+	// the averages of each day's Min and Max, and Mean the average of each
+	// day's Mean, all in °C.
+	Min     *float64
+	Max     *float64
+	MeanMin *float64
+	MeanMax *float64
+	Mean    *float64
+	// Rainfall is the total in mm.
+	Rainfall *float64
+	RainDays int
+	// MaxWind is the highest of the days' MaxWind in km/h.
+	MaxWind *int
+
+	// Degree-days are the totals of the days.
+	HeatingDegrees *float64
+	CoolingDegrees *float64
+	GrowingDegrees *float64
+}
+
+// Months summarises days by calendar month, in the order of the first day
+// of each month. The days are usually from Days or DaysThreeHour.
+func Months(days []Day) []Month {
+	var (
+		out   []Month
+		index = make(map[[2]int]int)
+		means = make(map[[2]int]*[3]mean)
+	)
+	for _, d := range days {
+		y, m, _ := d.Date.Date()
+		key := [2]int{y, int(m)}
+		i, ok := index[key]
+		if !ok {
+			i = len(out)
+			index[key] = i
+			means[key] = new([3]mean)
+			out = append(out, Month{Month: time.Date(y, m, 1, 0, 0, 0, 0, d.Date.Location())})
+		}
+		mo := &out[i]
+		mo.Days++
+		if d.Min != nil && (mo.Min == nil || *d.Min < *mo.Min) {
+			mo.Min = metservice.Float64(*d.Min)
+		}
+		if d.Max != nil && (mo.Max == nil || *d.Max > *mo.Max) {
+			mo.Max = metservice.Float64(*d.Max)
+		}
+		means[key][0].add(d.Min)
+		means[key][1].add(d.Max)
+		means[key][2].add(d.Mean)
+		mo.Rainfall = sum(mo.Rainfall, d.Rainfall)
+		if d.RainDay {
+			mo.RainDays++
+		}
+		if d.MaxWind != nil && (mo.MaxWind == nil || *d.MaxWind > *mo.MaxWind) {
+			mo.MaxWind = metservice.Int(*d.MaxWind)
+		}
+		mo.HeatingDegrees = sum(mo.HeatingDegrees, d.HeatingDegrees)
+		mo.CoolingDegrees = sum(mo.CoolingDegrees, d.CoolingDegrees)
+		mo.GrowingDegrees = sum(mo.GrowingDegrees, d.GrowingDegrees)
+	}
+	for key, i := range index {
+		m := means[key]
+		out[i].MeanMin = m[0].value()
+		out[i].MeanMax = m[1].value()
+		out[i].Mean = m[2].value()
+	}
+	return out
+}
+
+// mean averages the values added to it.
+type mean struct {
+	sum float64
+	n   int
+}
+
+func (m *mean) add(v *float64) {
+	if v != nil {
+		m.sum += *v
+		m.n++
+	}
+}
+
+func (m *mean) value() *float64 {
+	if m.n == 0 {
+		return nil
+	}
+	return metservice.Float64(m.sum / float64(m.n))
+}
+
+// sum returns a+b, treating nil as zero unless both are nil.
+func sum(a, b *float64) *float64 {
+	switch {
+	case b == nil:
+		return a
+	case a == nil:
+		return metservice.Float64(*b)
+	}
+	return metservice.Float64(*a + *b)
+}
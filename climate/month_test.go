@@ -0,0 +1,63 @@
+package climate
+
+import (
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMonths(t *testing.T) {
+	days := []Day{
+		{
+			Date:           date(30),
+			Min:            metservice.Float64(8),
+			Max:            metservice.Float64(16),
+			Mean:           metservice.Float64(12),
+			Rainfall:       metservice.Float64(4),
+			RainDay:        true,
+			MaxWind:        metservice.Int(20),
+			HeatingDegrees: metservice.Float64(6),
+			GrowingDegrees: metservice.Float64(2),
+		},
+		{
+			Date:           date(31),
+			Min:            metservice.Float64(10),
+			Max:            metservice.Float64(24),
+			Mean:           metservice.Float64(16),
+			MaxWind:        metservice.Int(45),
+			HeatingDegrees: metservice.Float64(1),
+			GrowingDegrees: metservice.Float64(7),
+		},
+		{
+			Date:     date(32),
+			Rainfall: metservice.Float64(0.1),
+		},
+	}
+	want := []Month{
+		{
+			Month:          date(1),
+			Days:           2,
+			Min:            metservice.Float64(8),
+			Max:            metservice.Float64(24),
+			MeanMin:        metservice.Float64(9),
+			MeanMax:        metservice.Float64(20),
+			Mean:           metservice.Float64(14),
+			Rainfall:       metservice.Float64(4),
+			RainDays:       1,
+			MaxWind:        metservice.Int(45),
+			HeatingDegrees: metservice.Float64(7),
+			GrowingDegrees: metservice.Float64(9),
+		},
+		{
+			Month:    time.Date(2006, time.February, 1, 0, 0, 0, 0, nzst),
+			Days:     1,
+			Rainfall: metservice.Float64(0.1),
+		},
+	}
+	got := Months(days)
+	if diff := cmp.Diff(want, got, approx); diff != "" {
+		t.Errorf("Months mismatch (-want +got):\n%s", diff)
+	}
+}
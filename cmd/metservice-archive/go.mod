@@ -0,0 +1,8 @@
+module git.sr.ht/~kota/metservice-go/cmd/metservice-archive
+
+go 1.13
+
+require (
+	git.sr.ht/~kota/metservice-go v0.1.0
+	github.com/mattn/go-sqlite3 v1.14.33
+)
@@ -0,0 +1,98 @@
+// metservice-archive polls metservice and stores everything fetched in a
+// SQLite database, keeping a history beyond what the API returns.
+//
+// Usage:
+//
+//	metservice-archive [flags]
+//
+// The flags are:
+//
+//	-db         path of the SQLite database (default metservice.db)
+//	-interval   how often to fetch data (default 1h)
+//	-locations  comma separated list of locations (default Dunedin)
+//	-once       fetch once and exit
+//	-url        base URL of the API
+//
+// Use the archive package to query the database.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/archive"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func main() {
+	path := flag.String("db", "metservice.db", "path of the SQLite database")
+	interval := flag.Duration("interval", time.Hour, "how often to fetch data")
+	locations := flag.String("locations", "Dunedin", "comma separated list of locations")
+	once := flag.Bool("once", false, "fetch once and exit")
+	baseURL := flag.String("url", metservice.BaseURL, "base URL of the API")
+	flag.Parse()
+
+	var locs []string
+	for _, l := range strings.Split(*locations, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			locs = append(locs, l)
+		}
+	}
+	if len(locs) == 0 || *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "usage: metservice-archive [-db path] [-interval d] [-locations a,b] [-once]")
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	db, err := sql.Open("sqlite3", *path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	a, err := archive.New(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client := metservice.NewClient()
+	client.BaseURL = *baseURL
+	fetch := func() {
+		for _, loc := range locs {
+			for _, err := range a.Fetch(ctx, client, loc) {
+				log.Print(err)
+			}
+		}
+	}
+
+	fetch()
+	if *once {
+		return
+	}
+	log.Printf("archiving %s to %s every %s", strings.Join(locs, ", "), *path, *interval)
+	t := time.NewTicker(*interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			fetch()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
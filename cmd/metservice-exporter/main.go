@@ -0,0 +1,65 @@
+// metservice-exporter serves metservice observations and pollen levels as
+// Prometheus metrics.
+//
+// Usage:
+//
+//	metservice-exporter [flags]
+//
+// The flags are:
+//
+//	-listen     address to serve metrics on (default :9523)
+//	-interval   how often to fetch data (default 5m)
+//	-locations  comma separated list of locations (default Dunedin)
+//	-url        base URL of the API
+//
+// Metrics are served at /metrics.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/exporter"
+)
+
+func main() {
+	listen := flag.String("listen", ":9523", "address to serve metrics on")
+	interval := flag.Duration("interval", exporter.DefaultInterval, "how often to fetch data")
+	locations := flag.String("locations", "Dunedin", "comma separated list of locations")
+	baseURL := flag.String("url", metservice.BaseURL, "base URL of the API")
+	flag.Parse()
+
+	var locs []string
+	for _, l := range strings.Split(*locations, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			locs = append(locs, l)
+		}
+	}
+	if len(locs) == 0 || *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "usage: metservice-exporter [-listen addr] [-interval d] [-locations a,b]")
+		os.Exit(2)
+	}
+
+	client := metservice.NewClient()
+	client.BaseURL = *baseURL
+	e := exporter.New(client, locs...)
+	e.Interval = *interval
+	go e.Run(context.Background())
+
+	http.Handle("/metrics", e)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, `<html><body><a href="/metrics">metrics</a></body></html>`)
+	})
+	log.Printf("serving metrics for %s on %s", strings.Join(locs, ", "), *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
@@ -0,0 +1,99 @@
+// metservice-mqtt polls metservice and publishes the latest values to an
+// MQTT broker, with Home Assistant discovery.
+//
+// Usage:
+//
+//	metservice-mqtt [flags]
+//
+// The flags are:
+//
+//	-broker     address of the broker (default localhost:1883)
+//	-client-id  MQTT client id (default metservice-mqtt)
+//	-username   MQTT user name
+//	-interval   how often to fetch data (default 5m)
+//	-locations  comma separated list of locations (default Dunedin)
+//	-prefix     start of each topic (default metservice)
+//	-discovery  Home Assistant discovery prefix, empty to disable (default homeassistant)
+//	-url        base URL of the API
+//
+// The MQTT password is read from the MQTT_PASSWORD environment variable.
+// Values are published to topics such as metservice/Dunedin/temp.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/mqtt"
+)
+
+func main() {
+	broker := flag.String("broker", "localhost:1883", "address of the broker")
+	clientID := flag.String("client-id", "metservice-mqtt", "MQTT client id")
+	username := flag.String("username", "", "MQTT user name")
+	interval := flag.Duration("interval", mqtt.DefaultInterval, "how often to fetch data")
+	locations := flag.String("locations", "Dunedin", "comma separated list of locations")
+	prefix := flag.String("prefix", mqtt.DefaultPrefix, "start of each topic")
+	discovery := flag.String("discovery", mqtt.DefaultDiscoveryPrefix, "Home Assistant discovery prefix, empty to disable")
+	baseURL := flag.String("url", metservice.BaseURL, "base URL of the API")
+	flag.Parse()
+
+	var locs []string
+	for _, l := range strings.Split(*locations, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			locs = append(locs, l)
+		}
+	}
+	if len(locs) == 0 || *interval <= 0 || *prefix == "" {
+		fmt.Fprintln(os.Stderr, "usage: metservice-mqtt [-broker addr] [-interval d] [-locations a,b]")
+		os.Exit(2)
+	}
+	for _, l := range locs {
+		if err := mqtt.CheckLocation(l); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	client := metservice.NewClient()
+	client.BaseURL = *baseURL
+	p := mqtt.New(client, nil, locs...)
+	p.Interval = *interval
+	p.Prefix = *prefix
+	p.DiscoveryPrefix = *discovery
+	p.OnError = func(err error) { log.Print(err) }
+
+	conn, err := mqtt.Dial(ctx, *broker, mqtt.Options{
+		ClientID: *clientID,
+		Username: *username,
+		Password: os.Getenv("MQTT_PASSWORD"),
+		Will: &mqtt.Message{
+			Topic:   p.StatusTopic(),
+			Payload: []byte("offline"),
+			Retain:  true,
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.Conn = conn
+	log.Printf("publishing %s to %s", strings.Join(locs, ", "), *broker)
+	if err := p.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,51 @@
+// metservice-server serves a cleaned up, cached JSON API in front of
+// metservice, along with OpenWeatherMap compatible routes. See the server
+// package for the routes. GraphQL queries are answered at /graphql, see the
+// graphql package.
+//
+// Usage:
+//
+//	metservice-server [flags]
+//
+// The flags are:
+//
+//	-listen  address to listen on (default :8080)
+//	-ttl     how long to cache responses (default 5m)
+//	-origin  Access-Control-Allow-Origin value, empty to disable CORS (default *)
+//	-url     base URL of the API
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/graphql"
+	"git.sr.ht/~kota/metservice-go/server"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to listen on")
+	ttl := flag.Duration("ttl", server.DefaultTTL, "how long to cache responses")
+	origin := flag.String("origin", "*", "Access-Control-Allow-Origin value, empty to disable CORS")
+	baseURL := flag.String("url", metservice.BaseURL, "base URL of the API")
+	flag.Parse()
+
+	client := metservice.NewClient()
+	client.BaseURL = *baseURL
+	s := server.New(client)
+	s.TTL = *ttl
+	s.AllowOrigin = *origin
+	s.GraphQL = &graphql.Handler{Fetcher: s}
+
+	srv := &http.Server{
+		Addr:         *listen,
+		Handler:      s,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	log.Printf("listening on %s", *listen)
+	log.Fatal(srv.ListenAndServe())
+}
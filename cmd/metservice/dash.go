@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// ANSI escape sequences used by the dashboard.
+const (
+	altScreen   = "\x1b[?1049h"
+	mainScreen  = "\x1b[?1049l"
+	clearScreen = "\x1b[H\x1b[2J"
+	bold        = "\x1b[1m"
+	reset       = "\x1b[0m"
+)
+
+// dashData is everything shown on the dashboard for one location.
+type dashData struct {
+	location string
+	fetched  time.Time
+	forecast *metservice.Forecast
+	oneMin   *metservice.ObservationOneMin
+	hours    *metservice.ObservationForecastHours
+	riseSet  *metservice.RiseSet
+	errs     []error
+}
+
+// fetchDash fetches the dashboard endpoints for location concurrently.
+func fetchDash(ctx context.Context, client *metservice.Client, location string) dashData {
+	d := dashData{location: location, fetched: time.Now()}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	fail := func(err error) {
+		if err != nil {
+			mu.Lock()
+			d.errs = append(d.errs, err)
+			mu.Unlock()
+		}
+	}
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		var err error
+		d.forecast, _, err = client.GetForecast(ctx, location)
+		fail(err)
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		d.oneMin, _, err = client.GetObservationOneMin(ctx, location)
+		fail(err)
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		d.hours, _, err = client.GetObservationForecastHours(ctx, location)
+		fail(err)
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		d.riseSet, _, err = client.GetRiseSet(ctx, location)
+		fail(err)
+	}()
+	wg.Wait()
+	return d
+}
+
+// dashConfig holds the settings for a dashboard.
+type dashConfig struct {
+	refresh time.Duration
+	width   int
+	units   units
+	// fullscreen draws on the terminal's alternate screen.
+	fullscreen bool
+	// raw is set when the terminal sends each key as it's pressed, rather
+	// than a line at a time.
+	raw bool
+}
+
+// dash runs the interactive dashboard until the user quits or ctx is done.
+// Commands are single keys read from in, with newlines ignored: n and p
+// move between locations, a number jumps to that location, r refreshes and
+// q quits.
+func dash(ctx context.Context, client *metservice.Client, locations []string, cfg dashConfig, in io.Reader, out io.Writer) int {
+	if cfg.fullscreen {
+		fmt.Fprint(out, altScreen)
+		defer fmt.Fprint(out, mainScreen)
+	}
+
+	// The read from in can't be interrupted, but done stops the reader
+	// once it returns.
+	keys := make(chan rune)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(keys)
+		r := bufio.NewReader(in)
+		for {
+			key, _, err := r.ReadRune()
+			if err != nil {
+				return
+			}
+			if unicode.IsSpace(key) {
+				continue
+			}
+			select {
+			case keys <- key:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	current := 0
+	cache := make(map[string]dashData)
+	ticker := time.NewTicker(cfg.refresh)
+	defer ticker.Stop()
+	for {
+		loc := locations[current]
+		d, ok := cache[loc]
+		if !ok || time.Since(d.fetched) >= cfg.refresh {
+			d = fetchDash(ctx, client, loc)
+			cache[loc] = d
+		}
+		fmt.Fprint(out, clearScreen)
+		renderDash(out, d, current, len(locations), cfg)
+
+		select {
+		case <-ctx.Done():
+			return exitOK
+		case <-ticker.C:
+			delete(cache, loc)
+		case key, ok := <-keys:
+			if !ok {
+				return exitOK
+			}
+			switch key {
+			case 'q':
+				return exitOK
+			case 'n':
+				current = (current + 1) % len(locations)
+			case 'p':
+				current = (current + len(locations) - 1) % len(locations)
+			case 'r':
+				delete(cache, loc)
+			default:
+				if i := int(key - '0'); i >= 1 && i <= 9 && i <= len(locations) {
+					current = i - 1
+				}
+			}
+		}
+	}
+}
+
+// savedLocations reads the locations file, one location per line, from the
+// user's config directory.
+func savedLocations() ([]string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "metservice", "locations"))
+	if err != nil {
+		return nil, err
+	}
+	var locations []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			locations = append(locations, line)
+		}
+	}
+	return locations, nil
+}
+
+// rawMode switches the terminal on f to sending each key as it's pressed,
+// without echoing it, and returns a function that switches it back. It uses
+// stty, so ok is false if f isn't a terminal or stty isn't available.
+func rawMode(f *os.File) (restore func(), ok bool) {
+	state, err := stty(f, "-g")
+	if err != nil {
+		return nil, false
+	}
+	if _, err := stty(f, "-icanon", "-echo", "min", "1"); err != nil {
+		return nil, false
+	}
+	return func() { stty(f, strings.TrimSpace(state)) }, true
+}
+
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// renderDash draws a single screen of the dashboard.
+func renderDash(w io.Writer, d dashData, index, total int, cfg dashConfig) {
+	u, width := cfg.units, cfg.width
+	fmt.Fprintf(w, "%s%s%s  [%d/%d]  updated %s\n\n",
+		bold, d.location, reset, index+1, total, d.fetched.Format("15:04"))
+	for _, err := range d.errs {
+		fmt.Fprintf(w, "error: %v\n", err)
+	}
+
+	var temps, rain []float64
+	if d.hours != nil {
+		for _, h := range d.hours.Observations {
+			temps = append(temps, u.convertTemp(value(h.Temp)))
+			rain = append(rain, u.convertRain(value(h.Rainfall)))
+		}
+		for _, h := range d.hours.Forecasts {
+			if h.Temp != nil {
+				temps = append(temps, u.convertTemp(float64(*h.Temp)))
+			} else {
+				temps = append(temps, math.NaN())
+			}
+			rain = append(rain, u.convertRain(value(h.Rainfall)))
+		}
+	}
+
+	// Current conditions.
+	var now []string
+	if d.hours != nil && len(d.hours.Observations) > 0 {
+		h := d.hours.Observations[len(d.hours.Observations)-1]
+		if h.Temp != nil {
+			now = append(now, u.tempPtr(h.Temp)+u.tempSymbol())
+		}
+		if h.WindSpeed != nil {
+			now = append(now, fmt.Sprintf("wind %s %s %s", u.windSpeed(h.WindSpeed), u.windSymbol(), str(h.WindDirection)))
+		}
+	}
+	if o := d.oneMin; o != nil {
+		if o.RelativeHumidity != nil {
+			now = append(now, fmt.Sprintf("humidity %d%%", *o.RelativeHumidity))
+		}
+		if o.Rainfall != nil {
+			now = append(now, "rain "+u.rainfall(o.Rainfall)+u.rainSymbol())
+		}
+		if o.Status != nil && *o.Status != "" {
+			now = append(now, *o.Status)
+		}
+	}
+	fmt.Fprintf(w, "Now  %s\n\n", strings.Join(now, "  "))
+
+	if len(temps) > 0 {
+		lo, hi := bounds(temps)
+		fmt.Fprintf(w, "Temperature %s..%s%s\n%s\n", number(lo), number(hi), u.tempSymbol(),
+			sparkline(temps, width, false))
+		_, hi = bounds(rain)
+		fmt.Fprintf(w, "Rain up to %s%s/h\n%s\n\n", number(hi), u.rainSymbol(), sparkline(rain, width, true))
+	}
+
+	if d.forecast != nil {
+		for _, day := range d.forecast.Days {
+			if day.Date == nil {
+				continue
+			}
+			line := fmt.Sprintf("%s  %s  %-16s %3s/%-3s %s",
+				day.Date.Format("Mon 02"), partIcons(day.Part), str(day.ForecastWord),
+				u.tempInt(day.Min), u.tempInt(day.Max), str(day.Forecast))
+			fmt.Fprintln(w, truncate(line, width))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if r := d.riseSet; r != nil {
+		fmt.Fprintf(w, "First light %s  Sunrise %s  Sunset %s  Last light %s\n\n",
+			clock(r.FirstLight), clock(r.SunRise), clock(r.SunSet), clock(r.LastLight))
+	}
+	help := "n next, p previous, 1-9 jump, r refresh, q quit"
+	if !cfg.raw {
+		help += " (then Enter)"
+	}
+	fmt.Fprintln(w, help)
+}
+
+// number formats v with at most two decimal places.
+func number(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func value(v *float64) float64 {
+	if v == nil {
+		return math.NaN()
+	}
+	return *v
+}
+
+func clock(t *metservice.Timestamp) string {
+	if t == nil {
+		return "--:--"
+	}
+	return t.Format("15:04")
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width > 0 && len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return s
+}
+
+// bounds returns the smallest and largest non-NaN values in vs.
+func bounds(vs []float64) (lo, hi float64) {
+	first := true
+	for _, v := range vs {
+		if math.IsNaN(v) {
+			continue
+		}
+		if first || v < lo {
+			lo = v
+		}
+		if first || v > hi {
+			hi = v
+		}
+		first = false
+	}
+	return lo, hi
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws vs as a single line of block characters, dropping any
+// values past width. When zeroBlank is set, zero values are drawn as
+// spaces so dry hours stand out from light rain.
+func sparkline(vs []float64, width int, zeroBlank bool) string {
+	if width > 0 && len(vs) > width {
+		vs = vs[:width]
+	}
+	lo, hi := bounds(vs)
+	if zeroBlank {
+		lo = 0
+	}
+	var b strings.Builder
+	for _, v := range vs {
+		switch {
+		case math.IsNaN(v):
+			b.WriteRune(' ')
+		case zeroBlank && v == 0:
+			b.WriteRune(' ')
+		case hi == lo:
+			b.WriteRune(sparks[0])
+		default:
+			i := int((v - lo) / (hi - lo) * float64(len(sparks)-1))
+			b.WriteRune(sparks[i])
+		}
+	}
+	return b.String()
+}
+
+// partIcons returns a symbol for the morning, afternoon, evening and
+// overnight parts of a day.
+func partIcons(p *metservice.DayPart) string {
+	if p == nil {
+		return "    "
+	}
+	var b strings.Builder
+	for _, t := range []*metservice.DayPartTime{p.Morning, p.Afternoon, p.Evening, p.Overnight} {
+		if t == nil || t.IconType == nil {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(icon(*t.IconType))
+	}
+	return b.String()
+}
+
+// icon maps a metservice icon type to a single symbol.
+func icon(iconType string) rune {
+	t := strings.ToLower(iconType)
+	switch {
+	case strings.Contains(t, "thunder"):
+		return '⚡'
+	case strings.Contains(t, "snow"), strings.Contains(t, "hail"):
+		return '❄'
+	case strings.Contains(t, "rain"), strings.Contains(t, "shower"), strings.Contains(t, "drizzle"):
+		return '☂'
+	case strings.Contains(t, "fog"):
+		return '≡'
+	case strings.Contains(t, "wind"):
+		return '~'
+	case strings.Contains(t, "partly"), strings.Contains(t, "few"):
+		return '◑'
+	case strings.Contains(t, "cloud"):
+		return '☁'
+	case strings.Contains(t, "fine"), strings.Contains(t, "sun"), strings.Contains(t, "clear"):
+		return '☀'
+	}
+	return '?'
+}
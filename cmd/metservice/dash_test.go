@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+var metric = units{temp: "c", wind: "kmh", rain: "mm"}
+
+func TestSparkline(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		vs        []float64
+		width     int
+		zeroBlank bool
+		want      string
+	}{
+		{"Range", []float64{0, 1, 2, 3, 4, 5, 6, 7}, 0, false, "▁▂▃▄▅▆▇█"},
+		{"Width", []float64{0, 7, 0, 7}, 2, false, "▁█"},
+		{"Flat", []float64{3, 3}, 0, false, "▁▁"},
+		{"Missing", []float64{1, math.NaN(), 2}, 0, false, "▁ █"},
+		{"Rain", []float64{0, 0.5, 1}, 0, true, " ▄█"},
+	}
+	for _, tc := range testCases {
+		if got := sparkline(tc.vs, tc.width, tc.zeroBlank); got != tc.want {
+			t.Errorf("%s: sparkline = %q, want %q", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestRenderDash(t *testing.T) {
+	date := &metservice.Timestamp{Time: time.Date(2006, time.January, 2, 0, 0, 0, 0, time.UTC)}
+	d := dashData{
+		location: "Dunedin",
+		fetched:  time.Date(2006, time.January, 2, 15, 4, 0, 0, time.UTC),
+		forecast: &metservice.Forecast{Days: []metservice.ForecastDay{{
+			Date:         date,
+			ForecastWord: metservice.String("Showers"),
+			Max:          metservice.Int(14),
+			Min:          metservice.Int(8),
+			Part: &metservice.DayPart{
+				Morning:   &metservice.DayPartTime{IconType: metservice.String("Fine")},
+				Afternoon: &metservice.DayPartTime{IconType: metservice.String("Showers")},
+			},
+		}}},
+		hours: &metservice.ObservationForecastHours{
+			Observations: []metservice.ObservationHour{
+				{Temp: metservice.Float64(10.5), WindSpeed: metservice.Int(20), WindDirection: metservice.String("SW")},
+			},
+			Forecasts: []metservice.ForecastHour{
+				{Temp: metservice.Int(12), Rainfall: metservice.Float64(1)},
+			},
+		},
+		riseSet: &metservice.RiseSet{SunRise: &metservice.Timestamp{Time: date.Add(6 * time.Hour)}},
+	}
+
+	var b bytes.Buffer
+	renderDash(&b, d, 0, 2, dashConfig{width: 80, units: metric})
+	got := b.String()
+	for _, want := range []string{
+		"Dunedin" + reset + "  [1/2]  updated 15:04",
+		"Now  10.5°C  wind 20 km/h SW",
+		"Temperature 10.5..12°C\n▁█\n",
+		"Mon 02  ☀☂    Showers            8/14",
+		"Sunrise 06:00",
+		"q quit (then Enter)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderDash output missing %q:\n%s", want, got)
+		}
+	}
+
+	b.Reset()
+	renderDash(&b, d, 0, 2, dashConfig{width: 80, units: units{temp: "f", wind: "mph", rain: "in"}, raw: true})
+	got = b.String()
+	for _, want := range []string{
+		"Now  50.9°F  wind 12.4 mph SW",
+		"Temperature 50.9..53.6°F\n",
+		"Rain up to 0.04in/h\n",
+		"Mon 02  ☀☂    Showers          46.4/57.2",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderDash output in imperial units missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Enter") {
+		t.Errorf("renderDash output in raw mode asks for Enter:\n%s", got)
+	}
+}
+
+func TestDash(t *testing.T) {
+	url, mux, teardown := setup()
+	defer teardown()
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path]++
+		mu.Unlock()
+		fmt.Fprint(w, `{}`)
+	})
+
+	client := metservice.NewClient()
+	client.BaseURL = url
+	var out bytes.Buffer
+	in := strings.NewReader("n\n2\nq\n")
+	cfg := dashConfig{refresh: time.Hour, width: 80, units: metric}
+	code := dash(context.Background(), client, []string{"Dunedin", "Mosgiel"}, cfg, in, &out)
+	if code != exitOK {
+		t.Errorf("dash returned %d", code)
+	}
+
+	// Both locations are fetched once each and then served from the cache.
+	for _, path := range []string{"/localForecastDunedin", "/riseSet_Mosgiel"} {
+		if seen[path] != 1 {
+			t.Errorf("%s fetched %d times, want 1", path, seen[path])
+		}
+	}
+	if n := strings.Count(out.String(), clearScreen); n != 3 {
+		t.Errorf("dash drew %d screens, want 3", n)
+	}
+}
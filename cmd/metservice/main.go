@@ -0,0 +1,232 @@
+// metservice is a command line client for the metservice API.
+//
+// Usage:
+//
+//	metservice [flags] <command> <location>
+//
+// The commands are:
+//
+//	forecast  multi-day forecast
+//	obs       latest observation
+//	obs-1min  minute by minute observation
+//	hourly    hourly observations and forecasts for about 48 hours
+//	pollen    pollen levels for the next few days
+//	riseset   sun and moon rise and set times for today
+//	dash      auto-refreshing dashboard for one or more locations
+//
+// The dash command takes a comma separated list of locations, or reads them
+// one per line from $XDG_CONFIG_HOME/metservice/locations if none are given.
+//
+// The flags are:
+//
+//	-o        output mode: table, json or csv (default table)
+//	-temp     temperature unit: c or f (default c)
+//	-wind     wind speed unit: kmh, mph, ms or kt (default kmh)
+//	-rain     rainfall unit: mm or in (default mm)
+//	-url      base URL of the API
+//	-refresh  how often dash refetches data (default 5m)
+//	-width    width of the dash display (default $COLUMNS or 80)
+//
+// Units apply to table and csv output and the dashboard; json output is the
+// API response as decoded by the library.
+//
+// The exit status is 0 on success, 1 if the request failed, 2 for bad usage,
+// 3 if the location was not found and 4 for any other bad response status.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Exit codes.
+const (
+	exitOK       = 0
+	exitFailure  = 1
+	exitUsage    = 2
+	exitNotFound = 3
+	exitStatus   = 4
+)
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "usage: metservice [flags] <command> <location>")
+	fmt.Fprintln(w, "commands: forecast, obs, obs-1min, hourly, pollen, riseset, dash")
+	fmt.Fprintln(w, "flags:")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("metservice", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	output := fs.String("o", "table", "output mode: table, json or csv")
+	var u units
+	fs.StringVar(&u.temp, "temp", "c", "temperature unit: c or f")
+	fs.StringVar(&u.wind, "wind", "kmh", "wind speed unit: kmh, mph, ms or kt")
+	fs.StringVar(&u.rain, "rain", "mm", "rainfall unit: mm or in")
+	baseURL := fs.String("url", metservice.BaseURL, "base URL of the API")
+	refresh := fs.Duration("refresh", 5*time.Minute, "how often dash refetches data")
+	width := fs.Int("width", terminalWidth(), "width of the dash display")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			usage(fs, stdout)
+			return exitOK
+		}
+		fmt.Fprintln(stderr, err)
+		usage(fs, stderr)
+		return exitUsage
+	}
+	if fs.NArg() < 1 || (fs.NArg() < 2 && fs.Arg(0) != "dash") {
+		usage(fs, stderr)
+		return exitUsage
+	}
+	if err := u.validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	switch *output {
+	case "table", "json", "csv":
+	default:
+		fmt.Fprintf(stderr, "unknown output mode %q (want table, json or csv)\n", *output)
+		return exitUsage
+	}
+
+	cmd := fs.Arg(0)
+	location := strings.Join(fs.Args()[1:], " ")
+	client := metservice.NewClient()
+	client.BaseURL = *baseURL
+
+	var (
+		v   interface{}
+		t   table
+		err error
+	)
+	if cmd == "dash" {
+		var locations []string
+		for _, l := range strings.Split(location, ",") {
+			if l = strings.TrimSpace(l); l != "" {
+				locations = append(locations, l)
+			}
+		}
+		if len(locations) == 0 {
+			saved, err := savedLocations()
+			if err != nil || len(saved) == 0 {
+				fmt.Fprintln(stderr, "metservice: dash: no locations given or saved")
+				return exitUsage
+			}
+			locations = saved
+		}
+		if *refresh <= 0 {
+			fmt.Fprintln(stderr, "metservice: dash: refresh must be positive")
+			return exitUsage
+		}
+		cfg := dashConfig{refresh: *refresh, width: *width, units: u}
+		fi, err := os.Stdout.Stat()
+		cfg.fullscreen = err == nil && stdout == io.Writer(os.Stdout) && fi.Mode()&os.ModeCharDevice != 0
+		if restore, ok := rawMode(os.Stdin); ok {
+			defer restore()
+			cfg.raw = true
+		}
+		// Being killed would leave the terminal in raw mode or on the
+		// alternate screen, so stop cleanly instead.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+		go func() {
+			select {
+			case <-sigs:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		return dash(ctx, client, locations, cfg, os.Stdin, stdout)
+	}
+
+	switch cmd {
+	case "forecast":
+		var f *metservice.Forecast
+		f, _, err = client.GetForecast(ctx, location)
+		v, t = f, forecastTable(f, u)
+	case "obs":
+		var o *metservice.Observation
+		o, _, err = client.GetObservation(ctx, location)
+		v, t = o, observationTable(o, u)
+	case "obs-1min":
+		var o *metservice.ObservationOneMin
+		o, _, err = client.GetObservationOneMin(ctx, location)
+		v, t = o, observationOneMinTable(o, u)
+	case "hourly":
+		var o *metservice.ObservationForecastHours
+		o, _, err = client.GetObservationForecastHours(ctx, location)
+		v, t = o, hourlyTable(o, u)
+	case "pollen":
+		var p *metservice.Pollen
+		p, _, err = client.GetPollen(ctx, location)
+		v, t = p, pollenTable(p)
+	case "riseset":
+		var r *metservice.RiseSet
+		r, _, err = client.GetRiseSet(ctx, location)
+		v, t = r, riseSetTable(r)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", cmd)
+		usage(fs, stderr)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "metservice: %s %s: %v\n", cmd, location, err)
+		return exitCode(err)
+	}
+
+	switch *output {
+	case "json":
+		err = writeJSON(stdout, v)
+	case "csv":
+		err = writeCSV(stdout, t)
+	default:
+		err = writeTable(stdout, t)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "metservice: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// terminalWidth guesses the terminal width from $COLUMNS.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// exitCode maps an error from the library to an exit status.
+func exitCode(err error) int {
+	if e, ok := err.(metservice.StatusError); ok {
+		if e.Code == http.StatusNotFound {
+			return exitNotFound
+		}
+		return exitStatus
+	}
+	return exitFailure
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func setup() (url string, mux *http.ServeMux, teardown func()) {
+	mux = http.NewServeMux()
+	server := httptest.NewServer(mux)
+	return server.URL + "/", mux, server.Close
+}
+
+func TestRun_Output(t *testing.T) {
+	url, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/hourlyObsAndForecast_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"actualData": [{"dateISO": "2006-01-02T15:00:00+13:00", "temperature": "10.5", "rainfall": "0", "windSpeed": "36"}],
+			"forecastData": [{"dateISO": "2006-01-02T16:00:00+13:00", "temperature": "12", "rainfall": "25.4", "humidity": "80"}]
+		}`)
+	})
+
+	testCases := []struct {
+		desc string
+		args []string
+		want string
+	}{
+		{
+			"Table",
+			[]string{"hourly", "Dunedin"},
+			"time              source    temp (°C)  rainfall (mm)  wind dir  wind speed (km/h)  humidity (%)\n" +
+				"2006-01-02 15:00  observed  10.5       0                        36                 \n" +
+				"2006-01-02 16:00  forecast  12         25.4                                        80\n",
+		},
+		{
+			"CSV",
+			[]string{"-o", "csv", "-temp", "f", "-rain", "in", "-wind", "ms", "hourly", "Dunedin"},
+			"time,source,temp (°F),rainfall (in),wind dir,wind speed (m/s),humidity (%)\n" +
+				"2006-01-02 15:00,observed,50.9,0.00,,10.0,\n" +
+				"2006-01-02 16:00,forecast,53.6,1.00,,,80\n",
+		},
+	}
+	for _, tc := range testCases {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{"-url", url}, tc.args...)
+		code := run(context.Background(), args, &stdout, &stderr)
+		if code != exitOK {
+			t.Errorf("%s: exit code %d, stderr: %s", tc.desc, code, stderr.String())
+			continue
+		}
+		if got := stdout.String(); got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	url, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/riseSet_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"location": "Dunedin"}`)
+	})
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-url", url, "-o", "json", "riseset", "Dunedin"}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"location": "Dunedin"`) {
+		t.Errorf("unexpected output: %s", stdout.String())
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	url, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/localForecastBroken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	})
+
+	testCases := []struct {
+		desc string
+		args []string
+		want int
+	}{
+		{"NotFound", []string{"-url", url, "forecast", "Atlantis"}, exitNotFound},
+		{"Status", []string{"-url", url, "forecast", "Broken"}, exitStatus},
+		{"NoLocation", []string{"forecast"}, exitUsage},
+		{"BadCommand", []string{"weather", "Dunedin"}, exitUsage},
+		{"BadUnit", []string{"-temp", "k", "forecast", "Dunedin"}, exitUsage},
+		{"BadOutput", []string{"-o", "xml", "forecast", "Dunedin"}, exitUsage},
+	}
+	for _, tc := range testCases {
+		var stdout, stderr bytes.Buffer
+		if got := run(context.Background(), tc.args, &stdout, &stderr); got != tc.want {
+			t.Errorf("%s: exit code %d, want %d", tc.desc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// table is the tabular form of a response, used by the table and csv
+// output modes.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+func writeTable(w io.Writer, t table) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, row := range append([][]string{t.header}, t.rows...) {
+		for i, cell := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, t table) error {
+	cw := csv.NewWriter(w)
+	cw.Write(t.header)
+	cw.WriteAll(t.rows)
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+const timeFormat = "2006-01-02 15:04"
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func integer(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+func boolean(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+func timestamp(t *metservice.Timestamp) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(timeFormat)
+}
+
+func date(t *metservice.Timestamp) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// unit labels a column header with the unit of its values.
+func unit(name, symbol string) string {
+	return name + " (" + symbol + ")"
+}
+
+func forecastTable(f *metservice.Forecast, u units) table {
+	t := table{header: []string{"date", "forecast", unit("min", u.tempSymbol()), unit("max", u.tempSymbol()), "issued", "details"}}
+	for _, d := range f.Days {
+		t.add(date(d.Date), str(d.ForecastWord), u.tempInt(d.Min), u.tempInt(d.Max),
+			timestamp(d.IssuedAt), str(d.Forecast))
+	}
+	return t
+}
+
+func observationTable(o *metservice.Observation, u units) table {
+	t := table{header: []string{"time", unit("temp", u.tempSymbol()), unit("humidity", "%"), "wind dir",
+		unit("wind speed", u.windSymbol()), unit("wind chill", u.tempSymbol()), unit("rainfall", u.rainSymbol()), "pressure"}}
+	if h := o.ThreeHour; h != nil {
+		t.add(timestamp(h.Date), u.tempInt(h.Temp), integer(h.Humidity), str(h.WindDirection),
+			u.windSpeed(h.WindSpeed), u.tempInt(h.WindChill), u.rainfall(h.Rainfall), str(h.Pressure))
+	}
+	return t
+}
+
+func observationOneMinTable(o *metservice.ObservationOneMin, u units) table {
+	t := table{header: []string{"time", unit("rainfall", u.rainSymbol()), unit("humidity", "%"), "current", "status"}}
+	t.add(timestamp(o.Date), u.rainfall(o.Rainfall), integer(o.RelativeHumidity),
+		boolean(o.Current), str(o.Status))
+	return t
+}
+
+func hourlyTable(ofh *metservice.ObservationForecastHours, u units) table {
+	t := table{header: []string{"time", "source", unit("temp", u.tempSymbol()), unit("rainfall", u.rainSymbol()),
+		"wind dir", unit("wind speed", u.windSymbol()), unit("humidity", "%")}}
+	for _, h := range ofh.Observations {
+		t.add(timestamp(h.Date), "observed", u.tempPtr(h.Temp), u.rainfall(h.Rainfall),
+			str(h.WindDirection), u.windSpeed(h.WindSpeed), "")
+	}
+	for _, h := range ofh.Forecasts {
+		t.add(timestamp(h.Date), "forecast", u.tempInt(h.Temp), u.rainfall(h.Rainfall),
+			str(h.WindDirection), u.windSpeed(h.WindSpeed), integer(h.Humidity))
+	}
+	return t
+}
+
+func pollenTable(p *metservice.Pollen) table {
+	t := table{header: []string{"day", "from", "to", "type", "level"}}
+	for _, d := range p.PollenDays {
+		t.add(str(d.DayDescriptor), timestamp(d.ValidFrom), timestamp(d.ValidTo),
+			str(d.Type), str(d.Level))
+	}
+	return t
+}
+
+func riseSetTable(r *metservice.RiseSet) table {
+	t := table{header: []string{"date", "first light", "sunrise", "sunset", "last light", "moonrise", "moonset"}}
+	t.add(date(r.Date), timestamp(r.FirstLight), timestamp(r.SunRise), timestamp(r.SunSet),
+		timestamp(r.LastLight), timestamp(r.MoonRise), timestamp(r.MoonSet))
+	return t
+}
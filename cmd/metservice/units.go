@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// units holds the display units chosen on the command line.
+type units struct {
+	temp string
+	wind string
+	rain string
+}
+
+func (u units) validate() error {
+	switch u.temp {
+	case "c", "f":
+	default:
+		return fmt.Errorf("unknown temperature unit %q (want c or f)", u.temp)
+	}
+	switch u.wind {
+	case "kmh", "mph", "ms", "kt":
+	default:
+		return fmt.Errorf("unknown wind unit %q (want kmh, mph, ms or kt)", u.wind)
+	}
+	switch u.rain {
+	case "mm", "in":
+	default:
+		return fmt.Errorf("unknown rain unit %q (want mm or in)", u.rain)
+	}
+	return nil
+}
+
+// tempInt formats a temperature given in °C.
+func (u units) tempInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return u.tempFloat(float64(*v))
+}
+
+func (u units) tempFloat(c float64) string {
+	if u.temp == "f" {
+		return strconv.FormatFloat(u.convertTemp(c), 'f', 1, 64)
+	}
+	return strconv.FormatFloat(c, 'f', -1, 64)
+}
+
+// convertTemp converts a temperature given in °C.
+func (u units) convertTemp(c float64) float64 {
+	if u.temp == "f" {
+		return c*9/5 + 32
+	}
+	return c
+}
+
+// tempSymbol returns the symbol for the temperature unit.
+func (u units) tempSymbol() string {
+	if u.temp == "f" {
+		return "°F"
+	}
+	return "°C"
+}
+
+func (u units) tempPtr(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return u.tempFloat(*v)
+}
+
+// windSpeed formats a wind speed given in km/h. Only converted speeds have
+// a decimal place, as the API gives whole km/h.
+func (u units) windSpeed(v *int) string {
+	if v == nil {
+		return ""
+	}
+	if u.wind == "kmh" {
+		return strconv.Itoa(*v)
+	}
+	return strconv.FormatFloat(u.convertWind(float64(*v)), 'f', 1, 64)
+}
+
+// convertWind converts a wind speed given in km/h.
+func (u units) convertWind(s float64) float64 {
+	switch u.wind {
+	case "mph":
+		return s / 1.609344
+	case "ms":
+		return s / 3.6
+	case "kt":
+		return s / 1.852
+	}
+	return s
+}
+
+// windSymbol returns the symbol for the wind speed unit.
+func (u units) windSymbol() string {
+	switch u.wind {
+	case "mph":
+		return "mph"
+	case "ms":
+		return "m/s"
+	case "kt":
+		return "kt"
+	}
+	return "km/h"
+}
+
+// rainfall formats a rainfall given in mm.
+func (u units) rainfall(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	if u.rain == "in" {
+		return strconv.FormatFloat(u.convertRain(*v), 'f', 2, 64)
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// convertRain converts a rainfall given in mm.
+func (u units) convertRain(r float64) float64 {
+	if u.rain == "in" {
+		return r / 25.4
+	}
+	return r
+}
+
+// rainSymbol returns the symbol for the rainfall unit.
+func (u units) rainSymbol() string {
+	if u.rain == "in" {
+		return "in"
+	}
+	return "mm"
+}
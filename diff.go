@@ -0,0 +1,175 @@
+package metservice
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Change is a single difference between two values found by one of the Diff
+// functions. Path names the field using Go field names, such as
+// "Days[0].Part.Morning.IconType". Old and New hold the dereferenced values,
+// or nil where the field was a nil pointer or a missing slice element.
+type Change struct {
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// DiffOptions controls which differences are reported. A nil *DiffOptions
+// reports every difference.
+type DiffOptions struct {
+	// Tolerance is the largest absolute change in a numeric field that is
+	// ignored.
+	Tolerance float64
+	// FieldTolerance overrides Tolerance for fields with the given name,
+	// such as "Rainfall" or "Temp".
+	FieldTolerance map[string]float64
+}
+
+func (o *DiffOptions) tolerance(field string) float64 {
+	if o == nil {
+		return 0
+	}
+	if t, ok := o.FieldTolerance[field]; ok {
+		return t
+	}
+	return o.Tolerance
+}
+
+// DiffForecast returns the differences going from a to b.
+func DiffForecast(a, b *Forecast, opts *DiffOptions) []Change {
+	return diff(a, b, opts)
+}
+
+// DiffObservation returns the differences going from a to b.
+func DiffObservation(a, b *Observation, opts *DiffOptions) []Change {
+	return diff(a, b, opts)
+}
+
+// DiffObservationOneMin returns the differences going from a to b.
+func DiffObservationOneMin(a, b *ObservationOneMin, opts *DiffOptions) []Change {
+	return diff(a, b, opts)
+}
+
+// DiffObservationForecastHours returns the differences going from a to b.
+func DiffObservationForecastHours(a, b *ObservationForecastHours, opts *DiffOptions) []Change {
+	return diff(a, b, opts)
+}
+
+// DiffPollen returns the differences going from a to b.
+func DiffPollen(a, b *Pollen, opts *DiffOptions) []Change {
+	return diff(a, b, opts)
+}
+
+// DiffRiseSet returns the differences going from a to b.
+func DiffRiseSet(a, b *RiseSet, opts *DiffOptions) []Change {
+	return diff(a, b, opts)
+}
+
+// diff walks a and b, which must have the same type, and returns their
+// differences.
+func diff(a, b interface{}, opts *DiffOptions) []Change {
+	d := differ{opts: opts}
+	d.walk("", "", reflect.ValueOf(a), reflect.ValueOf(b))
+	return d.changes
+}
+
+type differ struct {
+	opts    *DiffOptions
+	changes []Change
+}
+
+var timestampType = reflect.TypeOf(Timestamp{})
+
+func (d *differ) add(path string, a, b reflect.Value) {
+	d.changes = append(d.changes, Change{Path: path, Old: value(a), New: value(b)})
+}
+
+// value returns the dereferenced contents of v, or nil if v is a nil pointer
+// or invalid.
+func value(v reflect.Value) interface{} {
+	for v.IsValid() && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+func (d *differ) walk(path, field string, a, b reflect.Value) {
+	if a.Kind() == reflect.Ptr {
+		switch {
+		case a.IsNil() && b.IsNil():
+			return
+		case a.IsNil() || b.IsNil():
+			d.add(path, a, b)
+			return
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+
+	switch {
+	case a.Type() == timestampType:
+		ta, tb := a.Interface().(Timestamp), b.Interface().(Timestamp)
+		if !ta.Equal(tb) {
+			d.add(path, a, b)
+		}
+	case a.Kind() == reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			f := a.Type().Field(i)
+			if f.PkgPath != "" {
+				continue // unexported
+			}
+			p := f.Name
+			if path != "" {
+				p = path + "." + f.Name
+			}
+			d.walk(p, f.Name, a.Field(i), b.Field(i))
+		}
+	case a.Kind() == reflect.Slice:
+		n := a.Len()
+		if b.Len() > n {
+			n = b.Len()
+		}
+		for i := 0; i < n; i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= a.Len():
+				d.add(p, reflect.Value{}, b.Index(i))
+			case i >= b.Len():
+				d.add(p, a.Index(i), reflect.Value{})
+			default:
+				d.walk(p, field, a.Index(i), b.Index(i))
+			}
+		}
+	case isInt(a.Kind()):
+		if math.Abs(float64(b.Int()-a.Int())) > d.opts.tolerance(field) {
+			d.add(path, a, b)
+		}
+	case a.Kind() == reflect.Float64 || a.Kind() == reflect.Float32:
+		if math.Abs(b.Float()-a.Float()) > d.opts.tolerance(field) {
+			d.add(path, a, b)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(path, a, b)
+		}
+	}
+}
+
+func isInt(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package metservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffObservation(t *testing.T) {
+	a := &Observation{
+		Location: String("Dunedin"),
+		ThreeHour: &ObservationThreeHour{
+			Date:     &Timestamp{referenceTime},
+			Rainfall: Float64(1.0),
+			Temp:     Int(10),
+		},
+	}
+	b := &Observation{
+		Location: String("Dunedin"),
+		ThreeHour: &ObservationThreeHour{
+			Date:     &Timestamp{referenceTime.Add(3 * time.Hour)},
+			Humidity: Int(80),
+			Rainfall: Float64(1.05),
+			Temp:     Int(12),
+		},
+		TwentyFourHour: &ObservationTwentyFourHour{Max: Int(15)},
+	}
+
+	got := DiffObservation(a, b, &DiffOptions{
+		Tolerance:      1,
+		FieldTolerance: map[string]float64{"Rainfall": 0.1},
+	})
+	want := []Change{
+		{Path: "ThreeHour.Date", Old: Timestamp{referenceTime}, New: Timestamp{referenceTime.Add(3 * time.Hour)}},
+		{Path: "ThreeHour.Humidity", Old: nil, New: 80},
+		{Path: "ThreeHour.Temp", Old: 10, New: 12},
+		{Path: "TwentyFourHour", Old: nil, New: ObservationTwentyFourHour{Max: Int(15)}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DiffObservation mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiffForecast_Slices(t *testing.T) {
+	a := &Forecast{
+		Days: []ForecastDay{
+			{Max: Int(20), Part: &DayPart{Morning: &DayPartTime{IconType: String("Fine")}}},
+		},
+	}
+	b := &Forecast{
+		Days: []ForecastDay{
+			{Max: Int(20), Part: &DayPart{Morning: &DayPartTime{IconType: String("Rain")}}},
+			{Max: Int(18)},
+		},
+	}
+
+	got := DiffForecast(a, b, nil)
+	want := []Change{
+		{Path: "Days[0].Part.Morning.IconType", Old: "Fine", New: "Rain"},
+		{Path: "Days[1]", Old: nil, New: ForecastDay{Max: Int(18)}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DiffForecast mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := DiffForecast(b, b, nil); len(got) != 0 {
+		t.Errorf("DiffForecast of equal values returned %v", got)
+	}
+}
+
+func TestDiffRiseSet_Nil(t *testing.T) {
+	b := &RiseSet{Location: String("Dunedin")}
+	got := DiffRiseSet(nil, b, nil)
+	want := []Change{{Path: "", Old: nil, New: *b}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DiffRiseSet mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,273 @@
+// exporter periodically fetches metservice observations and pollen levels
+// and serves them as Prometheus metrics.
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// DefaultInterval is how often an Exporter without an Interval fetches.
+const DefaultInterval = 5 * time.Minute
+
+// Exporter fetches data for a set of locations and serves the latest values
+// in the Prometheus text format. It implements http.Handler.
+type Exporter struct {
+	Client    *metservice.Client
+	Locations []string
+	// Interval is the time between fetches in Run.
+	Interval time.Duration
+
+	mu     sync.Mutex
+	values map[series]float64
+	errors map[series]float64
+	// set holds the series the last update of each location and endpoint
+	// set, keyed by their rendered health labels.
+	set map[string][]series
+}
+
+// series is a single metric and label set.
+type series struct {
+	name   string
+	labels string // rendered, e.g. `location="Dunedin"`
+}
+
+// New returns an Exporter for locations that fetches with client every
+// DefaultInterval.
+func New(client *metservice.Client, locations ...string) *Exporter {
+	return &Exporter{
+		Client:    client,
+		Locations: locations,
+		Interval:  DefaultInterval,
+	}
+}
+
+// metric describes a metric family for the HELP and TYPE lines.
+type metric struct {
+	name string
+	typ  string
+	help string
+}
+
+// Metrics served by an Exporter, in output order.
+var metrics = []metric{
+	{"metservice_temperature_celsius", "gauge", "Air temperature from the latest three hourly observation."},
+	{"metservice_wind_chill_celsius", "gauge", "Wind chill from the latest three hourly observation."},
+	{"metservice_humidity_percent", "gauge", "Relative humidity from the given observation."},
+	{"metservice_wind_speed_kmh", "gauge", "Wind speed from the latest three hourly observation."},
+	{"metservice_rainfall_mm", "gauge", "Rainfall over the given period, where running is the total reported with the one minute observation."},
+	{"metservice_pollen_level", "gauge", "Today's pollen level from 1 (low) to 4 (very high)."},
+	{"metservice_observation_timestamp_seconds", "gauge", "Time of the latest observation."},
+	{"metservice_up", "gauge", "Whether the last fetch of the endpoint succeeded."},
+	{"metservice_fetch_duration_seconds", "gauge", "How long the last fetch of the endpoint took."},
+	{"metservice_last_success_timestamp_seconds", "gauge", "Time of the last successful fetch of the endpoint."},
+	{"metservice_fetch_errors_total", "counter", "Failed fetches of the endpoint."},
+}
+
+// Run calls Collect straight away and then every Interval until ctx is
+// cancelled.
+func (e *Exporter) Run(ctx context.Context) {
+	interval := e.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		e.Collect(ctx)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Collect fetches every location once, concurrently, and updates the
+// served values.
+func (e *Exporter) Collect(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, loc := range e.Locations {
+		wg.Add(3)
+		go func(loc string) {
+			defer wg.Done()
+			start := time.Now()
+			o, _, err := e.Client.GetObservation(ctx, loc)
+			e.record(loc, "observation", start, err, func(set setter) { observation(set, o) })
+		}(loc)
+		go func(loc string) {
+			defer wg.Done()
+			start := time.Now()
+			o, _, err := e.Client.GetObservationOneMin(ctx, loc)
+			e.record(loc, "observation_one_min", start, err, func(set setter) { oneMin(set, o) })
+		}(loc)
+		go func(loc string) {
+			defer wg.Done()
+			start := time.Now()
+			p, _, err := e.Client.GetPollen(ctx, loc)
+			e.record(loc, "pollen", start, err, func(set setter) { pollen(set, p) })
+		}(loc)
+	}
+	wg.Wait()
+}
+
+// setter records a value for the current location.
+type setter func(name string, v float64, labels ...string)
+
+// record is called after a fetch that began at start. On success it calls
+// update to store the fetched values. The health metrics are updated either
+// way.
+func (e *Exporter) record(location, endpoint string, start time.Time, err error, update func(setter)) {
+	// Take the time before the lock so waiting on other fetches isn't
+	// counted in the duration.
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.values == nil {
+		e.values = make(map[series]float64)
+		e.errors = make(map[series]float64)
+		e.set = make(map[string][]series)
+	}
+
+	health := render([]string{"location", location, "endpoint", endpoint})
+	var updated []series
+	set := func(name string, v float64, labels ...string) {
+		labels = append([]string{"location", location}, labels...)
+		s := series{name, render(labels)}
+		e.values[s] = v
+		updated = append(updated, s)
+	}
+	e.values[series{"metservice_fetch_duration_seconds", health}] = now.Sub(start).Seconds()
+	if err != nil {
+		e.values[series{"metservice_up", health}] = 0
+		e.errors[series{"metservice_fetch_errors_total", health}]++
+		return
+	}
+	// Drop the values from the last update, so a field that's no longer
+	// returned isn't served stale.
+	for _, s := range e.set[health] {
+		delete(e.values, s)
+	}
+	update(set)
+	e.set[health] = updated
+	e.values[series{"metservice_up", health}] = 1
+	e.values[series{"metservice_last_success_timestamp_seconds", health}] = float64(now.Unix())
+	if _, ok := e.errors[series{"metservice_fetch_errors_total", health}]; !ok {
+		e.errors[series{"metservice_fetch_errors_total", health}] = 0
+	}
+}
+
+func observation(set setter, o *metservice.Observation) {
+	if h := o.ThreeHour; h != nil {
+		setInt(set, "metservice_temperature_celsius", h.Temp)
+		setInt(set, "metservice_wind_chill_celsius", h.WindChill)
+		if h.Humidity != nil {
+			set("metservice_humidity_percent", float64(*h.Humidity), "source", "three_hour")
+		}
+		setInt(set, "metservice_wind_speed_kmh", h.WindSpeed)
+		if h.Rainfall != nil {
+			set("metservice_rainfall_mm", *h.Rainfall, "period", "3h")
+		}
+		if h.Date != nil {
+			set("metservice_observation_timestamp_seconds", float64(h.Date.Unix()), "source", "three_hour")
+		}
+	}
+	if d := o.TwentyFourHour; d != nil && d.Rainfall != nil {
+		set("metservice_rainfall_mm", *d.Rainfall, "period", "24h")
+	}
+}
+
+func oneMin(set setter, o *metservice.ObservationOneMin) {
+	if o.RelativeHumidity != nil {
+		set("metservice_humidity_percent", float64(*o.RelativeHumidity), "source", "one_minute")
+	}
+	// This is a running total rather than the rain in the last minute.
+	if o.Rainfall != nil {
+		set("metservice_rainfall_mm", *o.Rainfall, "period", "running")
+	}
+	if o.Date != nil {
+		set("metservice_observation_timestamp_seconds", float64(o.Date.Unix()), "source", "one_minute")
+	}
+}
+
+func pollen(set setter, p *metservice.Pollen) {
+	if len(p.PollenDays) == 0 {
+		return
+	}
+	today := p.PollenDays[0]
+	if today.Level == nil {
+		return
+	}
+	if level, ok := metservice.PollenLevel(*today.Level); ok {
+		typ := ""
+		if today.Type != nil {
+			typ = *today.Type
+		}
+		set("metservice_pollen_level", float64(level), "type", typ)
+	}
+}
+
+func setInt(set setter, name string, v *int) {
+	if v != nil {
+		set(name, float64(*v))
+	}
+}
+
+// render formats label pairs as name="value",... escaping the values.
+func render(pairs []string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], v)
+	}
+	return b.String()
+}
+
+// ServeHTTP writes the latest values in the Prometheus text format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	e.write(&b)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	b.WriteTo(w)
+}
+
+// write writes the latest values in the Prometheus text format to b.
+func (e *Exporter) write(b *bytes.Buffer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	byName := make(map[string][]series)
+	for s := range e.values {
+		byName[s.name] = append(byName[s.name], s)
+	}
+	for s := range e.errors {
+		byName[s.name] = append(byName[s.name], s)
+	}
+	for _, m := range metrics {
+		list := byName[m.name]
+		if len(list) == 0 {
+			continue
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].labels < list[j].labels })
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for _, s := range list {
+			v, ok := e.values[s]
+			if !ok {
+				v = e.errors[s]
+			}
+			fmt.Fprintf(b, "%s{%s} %s\n", s.name, s.labels, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+}
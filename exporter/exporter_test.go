@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+func setup() (client *metservice.Client, mux *http.ServeMux, teardown func()) {
+	mux = http.NewServeMux()
+	server := httptest.NewServer(mux)
+	client = metservice.NewClient()
+	client.BaseURL = server.URL + "/"
+	return client, mux, server.Close
+}
+
+func TestExporter(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"threeHour": {"dateTimeISO": "2006-01-02T15:04:05Z", "humidity": "80",
+				"rainfall": "1.2", "temp": "11", "windChill": "8", "windSpeed": "20"},
+			"twentyFourHour": {"rainfall": "5.5"}
+		}`)
+	})
+	mux.HandleFunc("/oneMinObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"relativeHumidity": "85", "rainfall": "0", "timeISO": "2006-01-02T15:05:05Z"}`)
+	})
+	mux.HandleFunc("/pollen_town_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"pollen": [{"level": "High", "type": "Grass \"mixed\""}]}`)
+	})
+	// Nothing is registered for Nowhere so every fetch fails.
+
+	e := New(client, "Dunedin", "Nowhere")
+	e.Collect(context.Background())
+	e.Collect(context.Background())
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type is %q, want text/plain", ct)
+	}
+	body, _ := ioutil.ReadAll(rec.Body)
+	got := string(body)
+
+	want := []string{
+		"# TYPE metservice_temperature_celsius gauge\n",
+		`metservice_temperature_celsius{location="Dunedin"} 11` + "\n",
+		`metservice_wind_chill_celsius{location="Dunedin"} 8` + "\n",
+		`metservice_humidity_percent{location="Dunedin",source="one_minute"} 85` + "\n",
+		`metservice_humidity_percent{location="Dunedin",source="three_hour"} 80` + "\n",
+		`metservice_wind_speed_kmh{location="Dunedin"} 20` + "\n",
+		`metservice_rainfall_mm{location="Dunedin",period="running"} 0` + "\n",
+		`metservice_rainfall_mm{location="Dunedin",period="24h"} 5.5` + "\n",
+		`metservice_rainfall_mm{location="Dunedin",period="3h"} 1.2` + "\n",
+		`metservice_pollen_level{location="Dunedin",type="Grass \"mixed\""} 3` + "\n",
+		`metservice_observation_timestamp_seconds{location="Dunedin",source="three_hour"} 1.136214245e+09` + "\n",
+		`metservice_up{location="Dunedin",endpoint="pollen"} 1` + "\n",
+		`metservice_up{location="Nowhere",endpoint="observation"} 0` + "\n",
+		"# TYPE metservice_fetch_errors_total counter\n",
+		`metservice_fetch_errors_total{location="Dunedin",endpoint="observation"} 0` + "\n",
+		`metservice_fetch_errors_total{location="Nowhere",endpoint="observation"} 2` + "\n",
+		`metservice_fetch_duration_seconds{location="Nowhere",endpoint="pollen"} `,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("metrics missing %q, got:\n%s", w, got)
+		}
+	}
+	if strings.Contains(got, `metservice_temperature_celsius{location="Nowhere"}`) {
+		t.Errorf("metrics include a temperature for a failed location:\n%s", got)
+	}
+}
+
+func TestExporter_Missing(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	n := 0
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		if n == 0 {
+			fmt.Fprint(w, `{"threeHour": {"temp": "11", "windChill": "8"}}`)
+		} else {
+			fmt.Fprint(w, `{"threeHour": {"temp": "12"}}`)
+		}
+		n++
+	})
+
+	e := New(client, "Dunedin")
+	e.Collect(context.Background())
+	e.Collect(context.Background())
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	got := rec.Body.String()
+	if !strings.Contains(got, `metservice_temperature_celsius{location="Dunedin"} 12`) {
+		t.Errorf("metrics missing the new temperature, got:\n%s", got)
+	}
+	if strings.Contains(got, "metservice_wind_chill_celsius{") {
+		t.Errorf("metrics still include a wind chill that's no longer returned:\n%s", got)
+	}
+}
+
+func TestExporter_Empty(t *testing.T) {
+	e := New(metservice.NewClient())
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Body.Len() != 0 {
+		t.Errorf("got %q before any fetches, want no metrics", rec.Body.String())
+	}
+}
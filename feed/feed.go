@@ -0,0 +1,287 @@
+// feed publishes metservice forecasts and pollen levels as Atom and RSS 2.0
+// feeds.
+//
+// Each day is an entry with an ID that stays the same as the forecast for
+// that day is revised, and an updated time that moves when it changes, so
+// feed readers show a revised forecast as a changed entry rather than a new
+// one.
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// DefaultLink is the RSS channel link of a Feed without a Link, as RSS
+// requires one.
+const DefaultLink = "https://www.metservice.com/"
+
+// Feed is a list of items with some details about where they come from.
+type Feed struct {
+	// ID identifies the feed. It defaults to a tag URI built from the
+	// Title.
+	ID    string
+	Title string
+	// Link is an optional URL of a page with the same information. RSS
+	// feeds use DefaultLink without one.
+	Link string
+	// Updated is when the feed last changed. The zero value uses the latest
+	// Updated of the items, or the current time if none have one.
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is a single feed entry.
+type Item struct {
+	// ID identifies the item across updates.
+	ID      string
+	Title   string
+	Content string
+	// Updated is when the item last changed.
+	Updated time.Time
+}
+
+// ForecastItems returns an item for each day of f that has a date.
+func ForecastItems(location string, f *metservice.Forecast) []Item {
+	var items []Item
+	for _, d := range f.Days {
+		if d.Date == nil {
+			continue
+		}
+		title := fmt.Sprintf("%s %s: %s", location, d.Date.Format("Mon 2 Jan"), word(d.ForecastWord, "Forecast"))
+		switch {
+		case d.Min != nil && d.Max != nil:
+			title += fmt.Sprintf(", %d–%d°C", *d.Min, *d.Max)
+		case d.Max != nil:
+			title += fmt.Sprintf(", high %d°C", *d.Max)
+		}
+		item := Item{
+			ID:      id("forecast", location, d.Date.Time),
+			Title:   title,
+			Content: word(d.Forecast, ""),
+		}
+		if d.IssuedAt != nil {
+			item.Updated = d.IssuedAt.Time
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// PollenItems returns an item for each day of p that has a start time.
+// Pollen forecasts don't say when they were issued, so each item is updated
+// at fetched, when p was fetched. If prev, the items from the previous
+// fetch, has the same item unchanged, its updated time is kept instead, so
+// only a level revised during the day shows as a changed item.
+func PollenItems(location string, p *metservice.Pollen, fetched time.Time, prev []Item) []Item {
+	old := make(map[string]Item, len(prev))
+	for _, it := range prev {
+		old[it.ID] = it
+	}
+	var items []Item
+	for _, d := range p.PollenDays {
+		if d.ValidFrom == nil {
+			continue
+		}
+		title := fmt.Sprintf("%s pollen %s: %s", location, d.ValidFrom.Format("Mon 2 Jan"), word(d.Level, "unknown"))
+		if d.Type != nil && *d.Type != "" {
+			title += " (" + *d.Type + ")"
+		}
+		var content string
+		if d.ValidTo != nil {
+			content = fmt.Sprintf("Valid from %s to %s.",
+				d.ValidFrom.Format("3:04pm Mon 2 Jan"), d.ValidTo.Format("3:04pm Mon 2 Jan"))
+		}
+		item := Item{
+			ID:      id("pollen", location, d.ValidFrom.Time),
+			Title:   title,
+			Content: content,
+			Updated: fetched,
+		}
+		if o, ok := old[item.ID]; ok && o.Title == item.Title && o.Content == item.Content {
+			item.Updated = o.Updated
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func word(s *string, fallback string) string {
+	if s == nil || *s == "" {
+		return fallback
+	}
+	return *s
+}
+
+// id returns a tag URI (RFC 4151) for the kind of item for location on the
+// day of t.
+func id(kind, location string, t time.Time) string {
+	slug := strings.ToLower(strings.Join(strings.Fields(location), "-"))
+	return fmt.Sprintf("tag:metservice-go,2021:%s/%s/%s", kind, slug, t.Format("2006-01-02"))
+}
+
+// updated returns the feed's Updated time, the latest Updated time of the
+// items, or the current time, whichever is set first.
+func (f *Feed) updated() time.Time {
+	if !f.Updated.IsZero() {
+		return f.Updated
+	}
+	var t time.Time
+	for _, it := range f.Items {
+		if it.Updated.After(t) {
+			t = it.Updated
+		}
+	}
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
+
+func (f *Feed) id() string {
+	if f.ID != "" {
+		return f.ID
+	}
+	slug := strings.ToLower(strings.Join(strings.Fields(f.Title), "-"))
+	return "tag:metservice-go,2021:feed/" + slug
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    *atomLink   `xml:"link,omitempty"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated string    `xml:"updated"`
+	Link    *atomLink `xml:"link,omitempty"`
+	Content *atomText `xml:"content,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// WriteAtom writes f as an Atom feed. Items without an Updated time use the
+// feed's. As Atom needs each entry to have content or a link, items without
+// Content use their Title when the feed has no Link.
+func WriteAtom(w io.Writer, f *Feed) error {
+	updated := f.updated()
+	a := atomFeed{
+		ID:      f.id(),
+		Title:   f.Title,
+		Updated: updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: "MetService"},
+	}
+	var link *atomLink
+	if f.Link != "" {
+		link = &atomLink{Href: f.Link}
+		a.Link = link
+	}
+	for _, it := range f.Items {
+		t := it.Updated
+		if t.IsZero() {
+			t = updated
+		}
+		e := atomEntry{ID: it.ID, Title: it.Title, Updated: t.Format(time.RFC3339), Link: link}
+		switch {
+		case it.Content != "":
+			e.Content = &atomText{Type: "text", Body: it.Content}
+		case link == nil:
+			e.Content = &atomText{Type: "text", Body: it.Title}
+		}
+		a.Entries = append(a.Entries, e)
+	}
+	return encode(w, a)
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+// WriteRSS writes f as an RSS 2.0 feed. RSS has no updated time so each
+// item's Updated is used as its publication date.
+func WriteRSS(w io.Writer, f *Feed) error {
+	link := f.Link
+	if link == "" {
+		link = DefaultLink
+	}
+	r := rss{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        link,
+			Description: f.Title,
+		},
+	}
+	r.Channel.LastBuildDate = f.updated().Format(time.RFC1123Z)
+	for _, it := range f.Items {
+		item := rssItem{
+			Title:       it.Title,
+			Link:        f.Link,
+			Description: it.Content,
+			GUID:        rssGUID{ID: it.ID},
+		}
+		if !it.Updated.IsZero() {
+			item.PubDate = it.Updated.Format(time.RFC1123Z)
+		}
+		r.Channel.Items = append(r.Channel.Items, item)
+	}
+	return encode(w, r)
+}
+
+func encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
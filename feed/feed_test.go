@@ -0,0 +1,170 @@
+package feed
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+func ts(s string) *metservice.Timestamp {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return &metservice.Timestamp{Time: t}
+}
+
+var forecast = &metservice.Forecast{
+	Days: []metservice.ForecastDay{
+		{
+			Date:         ts("2006-01-02T00:00:00+13:00"),
+			IssuedAt:     ts("2006-01-02T05:00:00+13:00"),
+			ForecastWord: metservice.String("Showers"),
+			Forecast:     metservice.String("Showers & <some> heavy."),
+			Min:          metservice.Int(8),
+			Max:          metservice.Int(14),
+		},
+		{Forecast: metservice.String("no date")},
+	},
+}
+
+var fetched = ts("2006-01-02T04:00:00+13:00").Time
+
+var pollen = &metservice.Pollen{
+	PollenDays: []metservice.PollenDay{
+		{
+			ValidFrom: ts("2006-01-02T00:00:00+13:00"),
+			ValidTo:   ts("2006-01-03T00:00:00+13:00"),
+			Level:     metservice.String("High"),
+			Type:      metservice.String("Grass"),
+		},
+	},
+}
+
+func TestWriteAtom(t *testing.T) {
+	f := &Feed{
+		Title: "Dunedin weather",
+		Link:  "https://example.com/dunedin",
+		Items: append(ForecastItems("Dunedin", forecast), PollenItems("Dunedin", pollen, fetched, nil)...),
+	}
+	var b bytes.Buffer
+	if err := WriteAtom(&b, f); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>tag:metservice-go,2021:feed/dunedin-weather</id>
+  <title>Dunedin weather</title>
+  <updated>2006-01-02T05:00:00+13:00</updated>
+  <link href="https://example.com/dunedin"></link>
+  <author>
+    <name>MetService</name>
+  </author>
+  <entry>
+    <id>tag:metservice-go,2021:forecast/dunedin/2006-01-02</id>
+    <title>Dunedin Mon 2 Jan: Showers, 8–14°C</title>
+    <updated>2006-01-02T05:00:00+13:00</updated>
+    <link href="https://example.com/dunedin"></link>
+    <content type="text">Showers &amp; &lt;some&gt; heavy.</content>
+  </entry>
+  <entry>
+    <id>tag:metservice-go,2021:pollen/dunedin/2006-01-02</id>
+    <title>Dunedin pollen Mon 2 Jan: High (Grass)</title>
+    <updated>2006-01-02T04:00:00+13:00</updated>
+    <link href="https://example.com/dunedin"></link>
+    <content type="text">Valid from 12:00am Mon 2 Jan to 12:00am Tue 3 Jan.</content>
+  </entry>
+</feed>
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteRSS(t *testing.T) {
+	f := &Feed{Title: "Dunedin forecast", Items: ForecastItems("Dunedin", forecast)}
+	var b bytes.Buffer
+	if err := WriteRSS(&b, f); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Dunedin forecast</title>
+    <link>https://www.metservice.com/</link>
+    <description>Dunedin forecast</description>
+    <lastBuildDate>Mon, 02 Jan 2006 05:00:00 +1300</lastBuildDate>
+    <item>
+      <title>Dunedin Mon 2 Jan: Showers, 8–14°C</title>
+      <description>Showers &amp; &lt;some&gt; heavy.</description>
+      <guid isPermaLink="false">tag:metservice-go,2021:forecast/dunedin/2006-01-02</guid>
+      <pubDate>Mon, 02 Jan 2006 05:00:00 +1300</pubDate>
+    </item>
+  </channel>
+</rss>
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestForecastItems_StableID(t *testing.T) {
+	// A reissued forecast keeps its ID but is updated.
+	reissued := &metservice.Forecast{Days: []metservice.ForecastDay{forecast.Days[0]}}
+	reissued.Days[0].IssuedAt = ts("2006-01-02T11:00:00+13:00")
+	a, b := ForecastItems("Dunedin", forecast)[0], ForecastItems("Dunedin", reissued)[0]
+	if a.ID != b.ID {
+		t.Errorf("ID changed from %s to %s", a.ID, b.ID)
+	}
+	if !b.Updated.After(a.Updated) {
+		t.Errorf("Updated didn't advance: %v then %v", a.Updated, b.Updated)
+	}
+}
+
+func TestWriteAtom_Empty(t *testing.T) {
+	// An empty feed is still updated at some point, and entries without
+	// content or a link get their title as content.
+	for _, f := range []*Feed{
+		{Title: "Nothing"},
+		{Title: "Bare", Items: []Item{{ID: "tag:example.com,2021:bare", Title: "Bare item"}}},
+	} {
+		var b bytes.Buffer
+		if err := WriteAtom(&b, f); err != nil {
+			t.Fatal(err)
+		}
+		got := b.String()
+		if strings.Contains(got, "0001-01-01") {
+			t.Errorf("%s feed has a zero updated time:\n%s", f.Title, got)
+		}
+		for _, it := range f.Items {
+			if !strings.Contains(got, `<content type="text">`+it.Title+`</content>`) {
+				t.Errorf("%s feed entry has no content:\n%s", f.Title, got)
+			}
+		}
+	}
+}
+
+func TestPollenItems_Revised(t *testing.T) {
+	first := PollenItems("Dunedin", pollen, fetched, nil)
+
+	// The same level fetched later keeps its updated time.
+	later := fetched.Add(time.Hour)
+	same := PollenItems("Dunedin", pollen, later, first)
+	if !same[0].Updated.Equal(fetched) {
+		t.Errorf("unchanged item updated at %v, want %v", same[0].Updated, fetched)
+	}
+
+	// A revised level keeps its ID but is updated.
+	revised := &metservice.Pollen{PollenDays: []metservice.PollenDay{pollen.PollenDays[0]}}
+	revised.PollenDays[0].Level = metservice.String("Very High")
+	changed := PollenItems("Dunedin", revised, later, same)
+	if changed[0].ID != first[0].ID {
+		t.Errorf("ID changed from %s to %s", first[0].ID, changed[0].ID)
+	}
+	if !changed[0].Updated.Equal(later) {
+		t.Errorf("revised item updated at %v, want %v", changed[0].Updated, later)
+	}
+}
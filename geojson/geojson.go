@@ -0,0 +1,216 @@
+// geojson encodes metservice observations and forecasts as GeoJSON
+// (RFC 7946) features for map overlays, placing each location at its
+// coordinates from metservice.Towns.
+package geojson
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Properties that can be set on a feature. Each is only present if the
+// value it comes from is.
+const (
+	PropLocation      = "location"       // location name, always present
+	PropError         = "error"          // error fetching the location
+	PropObservedAt    = "observed_at"    // time of the observation
+	PropTemp          = "temp"           // °C
+	PropHumidity      = "humidity"       // %
+	PropRainfall      = "rainfall"       // mm in the last three hours
+	PropRainfall24h   = "rainfall_24h"   // mm in the last day
+	PropWindSpeed     = "wind_speed"     // km/h
+	PropWindDirection = "wind_direction" // compass point
+	PropWindChill     = "wind_chill"     // °C
+	PropPressure      = "pressure"       // pressure trend, such as "Rising"
+	PropForecastDate  = "forecast_date"  // date of the forecast day used
+	PropForecastWord  = "forecast_word"  // such as "Showers"
+	PropForecast      = "forecast"       // forecast text
+	PropMax           = "max"            // forecast maximum °C
+	PropMin           = "min"            // forecast minimum °C
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature. Geometry is nil for a location without
+// known coordinates.
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON Point.
+type Geometry struct {
+	Type string `json:"type"`
+	// Coordinates are longitude then latitude.
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// Result is what was fetched for a single location. Observation and
+// Forecast may each be nil.
+type Result struct {
+	Location    string
+	Observation *metservice.Observation
+	Forecast    *metservice.Forecast
+	// Err is the first error fetching the location, if any.
+	Err error
+}
+
+// Options controls which properties are written.
+type Options struct {
+	// Properties lists the properties to include, such as PropTemp. If
+	// empty every property is included. The location is always included.
+	Properties []string
+	// Day is the index of the forecast day used for the forecast
+	// properties. The default of 0 is usually today.
+	Day int
+}
+
+func (o Options) want(name string) bool {
+	if len(o.Properties) == 0 || name == PropLocation {
+		return true
+	}
+	for _, p := range o.Properties {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch gets the observation and forecast for each location concurrently.
+// The results are in the same order as locations.
+func Fetch(ctx context.Context, client *metservice.Client, locations ...string) []Result {
+	results := make([]Result, len(locations))
+	var wg sync.WaitGroup
+	for i, loc := range locations {
+		wg.Add(1)
+		go func(r *Result, loc string) {
+			defer wg.Done()
+			*r = FetchOne(ctx, client, loc)
+		}(&results[i], loc)
+	}
+	wg.Wait()
+	return results
+}
+
+// FetchOne gets the observation and forecast for a single location.
+func FetchOne(ctx context.Context, client *metservice.Client, location string) Result {
+	r := Result{Location: location}
+	var obsErr, forecastErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		var o *metservice.Observation
+		if o, _, obsErr = client.GetObservation(ctx, location); obsErr == nil {
+			r.Observation = o
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var f *metservice.Forecast
+		if f, _, forecastErr = client.GetForecast(ctx, location); forecastErr == nil {
+			r.Forecast = f
+		}
+	}()
+	wg.Wait()
+	r.Err = obsErr
+	if r.Err == nil {
+		r.Err = forecastErr
+	}
+	return r
+}
+
+// NewFeature returns a feature for r.
+func NewFeature(r Result, opts Options) Feature {
+	f := Feature{
+		Type:       "Feature",
+		ID:         r.Location,
+		Properties: make(map[string]interface{}),
+	}
+	if t, ok := metservice.LookupTown(r.Location); ok {
+		f.Geometry = &Geometry{Type: "Point", Coordinates: [2]float64{t.Longitude, t.Latitude}}
+	}
+
+	set := func(name string, v interface{}) {
+		if opts.want(name) {
+			f.Properties[name] = v
+		}
+	}
+	setInt := func(name string, v *int) {
+		if v != nil {
+			set(name, *v)
+		}
+	}
+	setFloat := func(name string, v *float64) {
+		if v != nil {
+			set(name, *v)
+		}
+	}
+	setString := func(name string, v *string) {
+		if v != nil && *v != "" {
+			set(name, *v)
+		}
+	}
+	setTime := func(name string, v *metservice.Timestamp) {
+		if v != nil {
+			set(name, v.Format(time.RFC3339))
+		}
+	}
+
+	set(PropLocation, r.Location)
+	if r.Err != nil {
+		set(PropError, r.Err.Error())
+	}
+	if o := r.Observation; o != nil {
+		if h := o.ThreeHour; h != nil {
+			setTime(PropObservedAt, h.Date)
+			setInt(PropTemp, h.Temp)
+			setInt(PropHumidity, h.Humidity)
+			setFloat(PropRainfall, h.Rainfall)
+			setInt(PropWindSpeed, h.WindSpeed)
+			setString(PropWindDirection, h.WindDirection)
+			setInt(PropWindChill, h.WindChill)
+			setString(PropPressure, h.Pressure)
+		}
+		if d := o.TwentyFourHour; d != nil {
+			setFloat(PropRainfall24h, d.Rainfall)
+		}
+	}
+	if fc := r.Forecast; fc != nil && opts.Day >= 0 && opts.Day < len(fc.Days) {
+		d := fc.Days[opts.Day]
+		if d.Date != nil {
+			set(PropForecastDate, d.Date.Format("2006-01-02"))
+		}
+		setString(PropForecastWord, d.ForecastWord)
+		setString(PropForecast, d.Forecast)
+		setInt(PropMax, d.Max)
+		setInt(PropMin, d.Min)
+	}
+	return f
+}
+
+// NewFeatureCollection returns a collection with a feature for each result.
+func NewFeatureCollection(results []Result, opts Options) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, r := range results {
+		fc.Features = append(fc.Features, NewFeature(r, opts))
+	}
+	return fc
+}
+
+// Write writes a collection of results to w as JSON.
+func Write(w io.Writer, results []Result, opts Options) error {
+	return json.NewEncoder(w).Encode(NewFeatureCollection(results, opts))
+}
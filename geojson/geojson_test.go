@@ -0,0 +1,97 @@
+package geojson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWrite(t *testing.T) {
+	date := &metservice.Timestamp{}
+	json.Unmarshal([]byte(`"2006-01-02T15:00:00+13:00"`), date)
+	results := []Result{
+		{
+			Location: "Dunedin",
+			Observation: &metservice.Observation{
+				ThreeHour: &metservice.ObservationThreeHour{
+					Date: date, Temp: metservice.Int(11), WindSpeed: metservice.Int(20), Pressure: metservice.String("Rising"),
+				},
+				TwentyFourHour: &metservice.ObservationTwentyFourHour{Rainfall: metservice.Float64(5.5)},
+			},
+			Forecast: &metservice.Forecast{Days: []metservice.ForecastDay{
+				{Date: date, ForecastWord: metservice.String("Showers"), Max: metservice.Int(14)},
+			}},
+		},
+		{Location: "Atlantis", Err: metservice.StatusError{Code: 404}},
+	}
+
+	testCases := []struct {
+		desc string
+		opts Options
+		want string
+	}{
+		{
+			"All properties",
+			Options{},
+			`{"type":"FeatureCollection","features":[` +
+				`{"type":"Feature","id":"Dunedin","geometry":{"type":"Point","coordinates":[170.5,-45.87]},"properties":{` +
+				`"forecast_date":"2006-01-02","forecast_word":"Showers","location":"Dunedin","max":14,` +
+				`"observed_at":"2006-01-02T15:00:00+13:00","pressure":"Rising","rainfall_24h":5.5,"temp":11,"wind_speed":20}},` +
+				`{"type":"Feature","id":"Atlantis","geometry":null,"properties":{"error":"bad responce status code: 404","location":"Atlantis"}}]}` + "\n",
+		},
+		{
+			"Selected properties",
+			Options{Properties: []string{PropTemp, PropMax}},
+			`{"type":"FeatureCollection","features":[` +
+				`{"type":"Feature","id":"Dunedin","geometry":{"type":"Point","coordinates":[170.5,-45.87]},"properties":{"location":"Dunedin","max":14,"temp":11}},` +
+				`{"type":"Feature","id":"Atlantis","geometry":null,"properties":{"location":"Atlantis"}}]}` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		var b bytes.Buffer
+		if err := Write(&b, results, tc.opts); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := metservice.NewClient()
+	client.BaseURL = server.URL + "/"
+
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"threeHour": {"temp": "11"}}`)
+	})
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"days": [{"max": "14"}]}`)
+	})
+	mux.HandleFunc("/localForecastWellington", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"days": [{"max": "17"}]}`)
+	})
+
+	results := Fetch(context.Background(), client, "Dunedin", "Wellington")
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	d, w := results[0], results[1]
+	if d.Location != "Dunedin" || d.Err != nil || *d.Observation.ThreeHour.Temp != 11 || *d.Forecast.Days[0].Max != 14 {
+		t.Errorf("got Dunedin result %+v", d)
+	}
+	// Wellington's observation is missing but its forecast is still used.
+	if w.Location != "Wellington" || w.Err == nil || w.Observation != nil || *w.Forecast.Days[0].Max != 17 {
+		t.Errorf("got Wellington result %+v", w)
+	}
+}
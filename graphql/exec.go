@@ -0,0 +1,294 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// executor runs a validated operation.
+type executor struct {
+	ctx    context.Context
+	doc    *document
+	vars   map[string]interface{}
+	loader *loader
+
+	mu   sync.Mutex
+	errs []*Error
+}
+
+// object is a result object, which keeps its fields in query order.
+type object struct {
+	keys []string
+	vals []interface{}
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		b.Write(key)
+		b.WriteByte(':')
+		val, err := json.Marshal(o.vals[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// fieldSet is the fields of a selection set grouped by response name, in
+// the order they first appear.
+type fieldSet struct {
+	keys  []string
+	byKey map[string][]*field
+}
+
+func (e *executor) addError(err error, f *field, path []interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errs = append(e.errs, &Error{
+		Message:   err.Error(),
+		Locations: []Location{f.loc},
+		Path:      path,
+	})
+}
+
+// size is the number of fields in the set, counting repeats of a key.
+func (s fieldSet) size() int {
+	n := 0
+	for _, fs := range s.byKey {
+		n += len(fs)
+	}
+	return n
+}
+
+// run resolves the top level fields, collected from the operation,
+// concurrently.
+func (e *executor) run(fields fieldSet) *object {
+	query := schema.types["Query"]
+	out := &object{keys: fields.keys, vals: make([]interface{}, len(fields.keys))}
+	var wg sync.WaitGroup
+	for i, key := range fields.keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			out.vals[i] = e.resolveRoot(query, key, fields.byKey[key])
+		}(i, key)
+	}
+	wg.Wait()
+	return out
+}
+
+func (e *executor) resolveRoot(query *objectType, key string, fields []*field) interface{} {
+	f := fields[0]
+	if f.name == "__typename" {
+		return query.name
+	}
+	def := query.byName[f.name]
+	var location string
+	for _, a := range f.args {
+		if a.name == "location" {
+			v, _ := coerce(a.value, def.arg("location").typ, e.vars)
+			location, _ = v.(string)
+		}
+	}
+	v, err := e.loader.load(e.ctx, def.endpoint, location)
+	path := []interface{}{key}
+	if err != nil {
+		e.addError(err, f, path)
+		return nil
+	}
+	return e.complete(def.typ, fields, reflect.ValueOf(v), path)
+}
+
+// complete converts v, of type t, to its result for the given fields.
+func (e *executor) complete(t *typeRef, fields []*field, v reflect.Value, path []interface{}) interface{} {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if t.elem != nil {
+		if v.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = e.complete(t.elem, fields, v.Index(i), append(path[:len(path):len(path)], i))
+		}
+		return list
+	}
+	if v.Type() == timestampType {
+		return v.Interface().(metservice.Timestamp).Format(time.RFC3339)
+	}
+	switch t.name {
+	case "String":
+		return v.String()
+	case "Int":
+		return v.Int()
+	case "Float":
+		return v.Float()
+	case "Boolean":
+		return v.Bool()
+	}
+
+	obj := schema.types[t.name]
+	var sels []selection
+	for _, f := range fields {
+		sels = append(sels, f.selections...)
+	}
+	sub := e.collect(obj, sels)
+	out := &object{keys: sub.keys, vals: make([]interface{}, len(sub.keys))}
+	for i, key := range sub.keys {
+		fs := sub.byKey[key]
+		if fs[0].name == "__typename" {
+			out.vals[i] = obj.name
+			continue
+		}
+		def := obj.byName[fs[0].name]
+		out.vals[i] = e.complete(def.typ, fs, v.FieldByIndex(def.index), append(path[:len(path):len(path)], key))
+	}
+	return out
+}
+
+// collect gathers the fields of a selection set on t, expanding fragments
+// and applying @skip and @include.
+func (e *executor) collect(t *objectType, sels []selection) fieldSet {
+	set := fieldSet{byKey: make(map[string][]*field)}
+	visited := make(map[string]bool)
+	var walk func(sels []selection)
+	walk = func(sels []selection) {
+		for _, sel := range sels {
+			switch s := sel.(type) {
+			case *field:
+				if !e.included(s.directives) {
+					continue
+				}
+				key := s.key()
+				if _, ok := set.byKey[key]; !ok {
+					set.keys = append(set.keys, key)
+				}
+				set.byKey[key] = append(set.byKey[key], s)
+			case *fragmentSpread:
+				if visited[s.name] || !e.included(s.directives) {
+					continue
+				}
+				visited[s.name] = true
+				if f := e.doc.fragments[s.name]; f.on == t.name {
+					walk(f.selections)
+				}
+			case *inlineFragment:
+				if !e.included(s.directives) {
+					continue
+				}
+				if s.on == "" || s.on == t.name {
+					walk(s.selections)
+				}
+			}
+		}
+	}
+	walk(sels)
+	return set
+}
+
+// included applies @skip(if:) and @include(if:).
+func (e *executor) included(dirs []*directive) bool {
+	for _, d := range dirs {
+		var cond bool
+		for _, a := range d.args {
+			if a.name == "if" {
+				v, _ := coerce(a.value, ifArg[0].typ, e.vars)
+				cond, _ = v.(bool)
+			}
+		}
+		if d.name == "skip" && cond || d.name == "include" && !cond {
+			return false
+		}
+	}
+	return true
+}
+
+// loader fetches from the API, making only one request per endpoint and
+// location however many times it's asked.
+type loader struct {
+	fetcher Fetcher
+
+	mu    sync.Mutex
+	calls map[loadKey]*call
+}
+
+type loadKey struct {
+	endpoint metservice.Endpoint
+	location string
+}
+
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func (l *loader) load(ctx context.Context, endpoint metservice.Endpoint, location string) (interface{}, error) {
+	key := loadKey{endpoint, location}
+	l.mu.Lock()
+	if l.calls == nil {
+		l.calls = make(map[loadKey]*call)
+	}
+	c, ok := l.calls[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		l.calls[key] = c
+	}
+	l.mu.Unlock()
+
+	if !ok {
+		c.value, c.err = l.fetcher.Fetch(ctx, endpoint, location)
+		close(c.done)
+	}
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return c.value, c.err
+}
+
+// clientFetcher fetches straight from a Client.
+type clientFetcher struct {
+	client *metservice.Client
+}
+
+func (f clientFetcher) Fetch(ctx context.Context, endpoint metservice.Endpoint, loc string) (interface{}, error) {
+	c := f.client
+	switch endpoint {
+	case metservice.EndpointForecast:
+		v, _, err := c.GetForecast(ctx, loc)
+		return v, err
+	case metservice.EndpointObservation:
+		v, _, err := c.GetObservation(ctx, loc)
+		return v, err
+	case metservice.EndpointObservationForecastHours:
+		v, _, err := c.GetObservationForecastHours(ctx, loc)
+		return v, err
+	case metservice.EndpointPollen:
+		v, _, err := c.GetPollen(ctx, loc)
+		return v, err
+	case metservice.EndpointRiseSet:
+		v, _, err := c.GetRiseSet(ctx, loc)
+		return v, err
+	}
+	return nil, metservice.UnknownEndpointError{Endpoint: endpoint}
+}
@@ -0,0 +1,274 @@
+// graphql answers GraphQL queries over the metservice API, so a client can
+// ask for just the fields it needs from several endpoints in one request:
+//
+//	{
+//	  dunedin: observation(location: "Dunedin") { threeHour { temp } }
+//	  forecast(location: "Dunedin") { days { date forecastWord max min } }
+//	  pollen(location: "Dunedin") { pollenDays { type level } }
+//	}
+//
+// The schema mirrors the library's Forecast, Observation,
+// ObservationForecastHours, Pollen and RiseSet types, see SDL. The top level
+// fields are fetched concurrently and each endpoint is only fetched once per
+// location within a query, however many times it's asked for. A query may
+// have at most MaxRootFields top level fields, counting aliases.
+//
+// Only queries are supported. Introspection isn't, use SDL for the schema.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// MaxRootFields is the most top level fields a query may have, so that one
+// request can't start an unbounded number of fetches.
+const MaxRootFields = 20
+
+// Fetcher fetches a single endpoint for a location, returning the library's
+// type for it, such as *metservice.Forecast. It must be safe to call
+// concurrently.
+type Fetcher interface {
+	Fetch(ctx context.Context, endpoint metservice.Endpoint, location string) (interface{}, error)
+}
+
+// Request is a GraphQL request, as sent in the body of a POST.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent if the request
+// couldn't be executed at all, such as when the query is invalid; otherwise
+// it holds the fields in the order they were asked for.
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []*Error        `json:"errors,omitempty"`
+}
+
+// Error is an error in a Response. Path is set for an error fetching a
+// field, such as a location the API doesn't know.
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+var _ error = &Error{}
+
+func (e *Error) Error() string {
+	if len(e.Locations) > 0 {
+		return fmt.Sprintf("%d:%d: %s", e.Locations[0].Line, e.Locations[0].Column, e.Message)
+	}
+	return e.Message
+}
+
+// Location is a position in the query.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Execute runs a request, fetching from client.
+func Execute(ctx context.Context, client *metservice.Client, req Request) *Response {
+	return ExecuteFetcher(ctx, clientFetcher{client}, req)
+}
+
+// ExecuteFetcher runs a request, fetching with f. Use it to put a cache in
+// front of the API.
+func ExecuteFetcher(ctx context.Context, f Fetcher, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return errorResponse(err)
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return errorResponse(err)
+	}
+	vars, errs := coerceVariables(op, req.Variables)
+	if len(errs) == 0 {
+		errs = validate(doc, op, vars)
+	}
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	e := &executor{
+		ctx:    ctx,
+		doc:    doc,
+		vars:   vars,
+		loader: &loader{fetcher: f},
+	}
+	fields := e.collect(schema.types["Query"], op.selections)
+	if n := fields.size(); n > MaxRootFields {
+		return errorResponse(&Error{
+			Message:   fmt.Sprintf("Query has %d top level fields, more than the limit of %d.", n, MaxRootFields),
+			Locations: []Location{op.loc},
+		})
+	}
+	data, err := json.Marshal(e.run(fields))
+	if err != nil {
+		return errorResponse(err)
+	}
+	return &Response{Data: data, Errors: e.errs}
+}
+
+func errorResponse(err error) *Response {
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Message: err.Error()}
+	}
+	return &Response{Errors: []*Error{e}}
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	var op *operation
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		op = doc.operations[0]
+	} else {
+		for _, o := range doc.operations {
+			if o.name == name {
+				op = o
+			}
+		}
+		if op == nil {
+			return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+		}
+	}
+	if op.kind != "query" {
+		return nil, &Error{
+			Message:   fmt.Sprintf("Schema is not configured to execute %s operation.", op.kind),
+			Locations: []Location{op.loc},
+		}
+	}
+	return op, nil
+}
+
+// coerceVariables checks the given variables against the operation's
+// definitions, filling in defaults. JSON numbers are accepted for Int when
+// they're whole.
+func coerceVariables(op *operation, given map[string]interface{}) (map[string]interface{}, []*Error) {
+	vars := make(map[string]interface{})
+	var errs []*Error
+	for _, d := range op.vars {
+		if !scalars[d.typ.named()] {
+			errs = append(errs, &Error{
+				Message:   fmt.Sprintf("Variable \"$%s\" cannot be non-input type %q.", d.name, d.typ),
+				Locations: []Location{d.loc},
+			})
+			continue
+		}
+		v, ok := given[d.name]
+		if !ok && d.hasDef {
+			v, ok = d.defValue, true
+		}
+		if !ok {
+			if d.typ.nonNull {
+				errs = append(errs, &Error{
+					Message:   fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", d.name, d.typ),
+					Locations: []Location{d.loc},
+				})
+			}
+			continue
+		}
+		c, err := coerce(v, d.typ, nil)
+		if err != nil {
+			errs = append(errs, &Error{
+				Message:   fmt.Sprintf("Variable \"$%s\" got invalid value: %v", d.name, err),
+				Locations: []Location{d.loc},
+			})
+			continue
+		}
+		vars[d.name] = c
+	}
+	return vars, errs
+}
+
+// coerce converts a literal or JSON value to the input type t, replacing
+// variables with their values.
+func coerce(v interface{}, t *typeRef, vars map[string]interface{}) (interface{}, error) {
+	if name, ok := v.(variable); ok {
+		var defined bool
+		v, defined = vars[string(name)]
+		if !defined && t.nonNull {
+			return nil, fmt.Errorf("Variable \"$%s\" of required type %q was not provided.", name, t)
+		}
+	}
+	if v == nil {
+		if t.nonNull {
+			return nil, fmt.Errorf("Expected value of type %q, found null.", t)
+		}
+		return nil, nil
+	}
+	if t.elem != nil {
+		list, ok := v.([]interface{})
+		if !ok {
+			// A single value is coerced to a list of one.
+			c, err := coerce(v, t.elem, vars)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{c}, nil
+		}
+		out := make([]interface{}, len(list))
+		for i, e := range list {
+			c, err := coerce(e, t.elem, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	}
+
+	switch t.name {
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "Int":
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case float64:
+			if n == float64(int32(n)) {
+				return int(n), nil
+			}
+		}
+	case "Float":
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("Unknown type %q.", t.name)
+	}
+	return nil, fmt.Errorf("Expected value of type %q, found %s.", t, printValue(v))
+}
+
+// printValue formats a value as it would appear in a query.
+func printValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		b, _ := json.Marshal(v)
+		return string(b)
+	case variable:
+		return "$" + string(v)
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,245 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+// setup returns a client for a test server that serves mux.
+func setup() (client *metservice.Client, mux *http.ServeMux, teardown func()) {
+	mux = http.NewServeMux()
+	server := httptest.NewServer(mux)
+	client = metservice.NewClient()
+	client.BaseURL = server.URL + "/"
+	return client, mux, server.Close
+}
+
+// handle serves body at path, returning a count of the requests for it.
+func handle(mux *http.ServeMux, path, body string) *int32 {
+	var n int32
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		fmt.Fprint(w, body)
+	})
+	return &n
+}
+
+func TestExecute(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	forecasts := handle(mux, "/localForecastDunedin", `{"days": [{"dateISO": "2006-01-02T00:00:00+13:00",
+		"forecastWord": "Fine", "max": "14", "min": "5",
+		"riseSet": {"sunRiseISO": "2006-01-02T05:59:00+13:00"}}]}`)
+	observations := handle(mux, "/localObs_Dunedin", `{"threeHour": {"temp": "11", "rainfall": "0.2"}}`)
+	handle(mux, "/pollen_town_Dunedin", `{"pollen": [{"type": "Grass", "level": "High"}], "pollenEnabled": true}`)
+
+	testCases := []struct {
+		name  string
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{
+			name: "fields in query order",
+			query: `{
+				pollen(location: "Dunedin") { enabled pollenDays { level type } }
+				observation(location: "Dunedin") { threeHour { temp rainfall windSpeed } }
+			}`,
+			want: `{"pollen":{"enabled":true,"pollenDays":[{"level":"High","type":"Grass"}]},` +
+				`"observation":{"threeHour":{"temp":11,"rainfall":0.2,"windSpeed":null}}}`,
+		},
+		{
+			name: "aliases and nested types",
+			query: `{
+				today: forecast(location: "Dunedin") { days { word: forecastWord date riseSet { sunRise } } }
+				temps: forecast(location: "Dunedin") { days { max min } }
+			}`,
+			want: `{"today":{"days":[{"word":"Fine","date":"2006-01-02T00:00:00+13:00",` +
+				`"riseSet":{"sunRise":"2006-01-02T05:59:00+13:00"}}]},"temps":{"days":[{"max":14,"min":5}]}}`,
+		},
+		{
+			name: "fragments, directives and variables",
+			query: `query ($loc: String!, $withMin: Boolean = false) {
+				forecast(location: $loc) { __typename days { ...Temps } }
+			}
+			fragment Temps on ForecastDay { max min @include(if: $withMin) ... on ForecastDay { max } }`,
+			vars: map[string]interface{}{"loc": "Dunedin"},
+			want: `{"forecast":{"__typename":"Forecast","days":[{"max":14}]}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rsp := Execute(context.Background(), client, Request{Query: tc.query, Variables: tc.vars})
+			if len(rsp.Errors) > 0 {
+				t.Fatalf("got errors %v", rsp.Errors)
+			}
+			if got := string(rsp.Data); got != tc.want {
+				t.Errorf("got %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+
+	// Each test fetched the forecast once at most, however many fields
+	// asked for it.
+	if n := atomic.LoadInt32(forecasts); n != 2 {
+		t.Errorf("fetched the forecast %d times, want 2", n)
+	}
+	if n := atomic.LoadInt32(observations); n != 1 {
+		t.Errorf("fetched the observation %d times, want 1", n)
+	}
+}
+
+func TestExecute_fieldErrors(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+	handle(mux, "/riseSet_Dunedin", `{"sunRiseISO": "2006-01-02T05:59:00+13:00"}`)
+
+	rsp := Execute(context.Background(), client, Request{Query: `{
+		riseSet(location: "Dunedin") { sunRise }
+		nowhere: riseSet(location: "Nowhere") { sunRise }
+	}`})
+	want := &Response{
+		Data: []byte(`{"riseSet":{"sunRise":"2006-01-02T05:59:00+13:00"},"nowhere":null}`),
+		Errors: []*Error{{
+			Message:   metservice.StatusError{Code: 404}.Error(),
+			Locations: []Location{{Line: 3, Column: 3}},
+			Path:      []interface{}{"nowhere"},
+		}},
+	}
+	if diff := cmp.Diff(want, rsp); diff != "" {
+		t.Errorf("response mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExecute_requestErrors(t *testing.T) {
+	testCases := []struct {
+		query string
+		op    string
+		vars  map[string]interface{}
+		want  []string
+	}{
+		{
+			query: `mutation { forecast(location: "Dunedin") { days { max } } }`,
+			want:  []string{"1:1: Schema is not configured to execute mutation operation."},
+		},
+		{
+			query: `query A { __typename } query B { __typename }`,
+			want:  []string{"Must provide operation name if query contains multiple operations."},
+		},
+		{
+			query: `query A { __typename }`,
+			op:    "B",
+			want:  []string{`Unknown operation named "B".`},
+		},
+		{
+			query: `{ forecast(location: "Dunedin") { days { wind } } weather }`,
+			want: []string{
+				`1:42: Cannot query field "wind" on type "ForecastDay".`,
+				`1:51: Cannot query field "weather" on type "Query".`,
+			},
+		},
+		{
+			query: `{ forecast { days { max { value } } } pollen(location: "Dunedin", day: 1) }`,
+			want: []string{
+				`1:3: Field "forecast" argument "location" of type "String!" is required, but it was not provided.`,
+				`1:21: Field "max" must not have a selection since type "Int" has no subfields.`,
+				`1:67: Unknown argument "day" on Query.pollen.`,
+				`1:39: Field "pollen" of type "Pollen" must have a selection of subfields. Did you mean "pollen { ... }"?`,
+			},
+		},
+		{
+			query: `{ forecast(location: 5) { days { max } } }`,
+			want:  []string{`1:12: Argument "location" has invalid value 5. Expected value of type "String!", found 5.`},
+		},
+		{
+			query: `query ($loc: String!) { forecast(location: $where) { days { max } } }`,
+			vars:  map[string]interface{}{"loc": "Dunedin"},
+			want:  []string{`1:34: Variable "$where" is not defined.`},
+		},
+		{
+			query: `query ($loc: String!) { forecast(location: $loc) { days { max } } }`,
+			want:  []string{`1:8: Variable "$loc" of required type "String!" was not provided.`},
+		},
+		{
+			query: `query ($loc: String!) { forecast(location: $loc) { days { max } } }`,
+			vars:  map[string]interface{}{"loc": 5.0},
+			want:  []string{`1:8: Variable "$loc" got invalid value: Expected value of type "String!", found 5.`},
+		},
+		{
+			query: `{ forecast(location: "Dunedin") { days { ...F ...G } } } fragment F on ForecastDay { ...F } fragment G on Pollen { level }`,
+			want: []string{
+				`1:86: Cannot spread fragment "F" within itself.`,
+				`1:47: Fragment "G" cannot be spread here as objects of type "ForecastDay" can never be of type "Pollen".`,
+			},
+		},
+		{
+			query: `{ a: forecast(location: "Dunedin") { days { max } } a: pollen(location: "Dunedin") { location } }`,
+			want:  []string{`1:53: Fields "a" conflict because "forecast" and "pollen" are different fields. Use different aliases on the fields to fetch both if this was intentional.`},
+		},
+		{
+			query: `{ __typename @defer }`,
+			want:  []string{`1:14: Unknown directive "@defer".`},
+		},
+		{
+			query: "{" + strings.Repeat(` __typename`, MaxRootFields) + ` a: __typename }`,
+			want:  []string{fmt.Sprintf("1:1: Query has %d top level fields, more than the limit of %d.", MaxRootFields+1, MaxRootFields)},
+		},
+	}
+	for _, tc := range testCases {
+		// No request should reach the client.
+		rsp := Execute(context.Background(), nil, Request{Query: tc.query, OperationName: tc.op, Variables: tc.vars})
+		if rsp.Data != nil {
+			t.Errorf("%s: got data %s, want none", tc.query, rsp.Data)
+		}
+		var got []string
+		for _, e := range rsp.Errors {
+			got = append(got, e.Error())
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: errors mismatch (-want +got):\n%s", tc.query, diff)
+		}
+	}
+}
+
+// fragmentChain returns a query spreading a chain of n fragments, each of
+// which spreads the next one twice when double is set.
+func fragmentChain(n int, double bool) string {
+	var b strings.Builder
+	b.WriteString("{ ...F0 }")
+	for i := 0; i < n-1; i++ {
+		fmt.Fprintf(&b, " fragment F%d on Query { ...F%d", i, i+1)
+		if double {
+			fmt.Fprintf(&b, " ...F%d", i+1)
+		}
+		b.WriteString(" }")
+	}
+	fmt.Fprintf(&b, " fragment F%d on Query { __typename }", n-1)
+	return b.String()
+}
+
+func TestExecute_fragmentChain(t *testing.T) {
+	// Each fragment doubles the spreads of the last, which must not double
+	// the work of validating and running it.
+	rsp := Execute(context.Background(), nil, Request{Query: fragmentChain(40, true)})
+	if len(rsp.Errors) > 0 {
+		t.Fatalf("got errors %v", rsp.Errors)
+	}
+	if got, want := string(rsp.Data), `{"__typename":"Query"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	rsp = Execute(context.Background(), nil, Request{Query: fragmentChain(maxSpreads+1, false)})
+	if rsp.Data != nil || len(rsp.Errors) != 1 ||
+		!strings.HasSuffix(rsp.Errors[0].Message, fmt.Sprintf("more than %d fragment spreads.", maxSpreads)) {
+		t.Errorf("got %s %v, want an error for too many spreads", rsp.Data, rsp.Errors)
+	}
+}
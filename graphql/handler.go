@@ -0,0 +1,97 @@
+package graphql
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// maxBody is the largest request body a Handler reads.
+const maxBody = 1 << 20
+
+// Handler serves GraphQL over HTTP. Queries can be sent as a JSON Request in
+// the body of a POST, as the body of a POST with the content type
+// application/graphql, or in the query, operationName and variables
+// parameters of a GET.
+type Handler struct {
+	Client *metservice.Client
+	// Fetcher, if set, is used instead of Client, such as to share a cache
+	// with other handlers.
+	Fetcher Fetcher
+}
+
+// NewHandler returns a Handler that fetches with client.
+func NewHandler(client *metservice.Client) *Handler {
+	return &Handler{Client: client}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeResponse(w, r, http.StatusBadRequest, errorResponse(&Error{Message: "Variables are invalid JSON."}))
+				return
+			}
+		}
+	case http.MethodPost:
+		body := io.LimitReader(r.Body, maxBody)
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mt {
+		case "application/json", "":
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				writeResponse(w, r, http.StatusBadRequest, errorResponse(&Error{Message: "Body is invalid JSON."}))
+				return
+			}
+		case "application/graphql":
+			b, err := ioutil.ReadAll(body)
+			if err != nil {
+				writeResponse(w, r, http.StatusBadRequest, errorResponse(err))
+				return
+			}
+			req.Query = string(b)
+		default:
+			writeResponse(w, r, http.StatusUnsupportedMediaType, errorResponse(&Error{Message: "Unsupported content type " + mt + "."}))
+			return
+		}
+	case http.MethodOptions:
+		w.Header().Set("Allow", "GET, HEAD, POST, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, OPTIONS")
+		writeResponse(w, r, http.StatusMethodNotAllowed, errorResponse(&Error{Message: r.Method + " is not allowed."}))
+		return
+	}
+	if req.Query == "" {
+		writeResponse(w, r, http.StatusBadRequest, errorResponse(&Error{Message: "Must provide query string."}))
+		return
+	}
+
+	var f Fetcher = clientFetcher{h.Client}
+	if h.Fetcher != nil {
+		f = h.Fetcher
+	}
+	rsp := ExecuteFetcher(r.Context(), f, req)
+	status := http.StatusOK
+	if rsp.Data == nil {
+		status = http.StatusBadRequest
+	}
+	writeResponse(w, r, status, rsp)
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, rsp *Response) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		json.NewEncoder(w).Encode(rsp)
+	}
+}
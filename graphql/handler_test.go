@@ -0,0 +1,102 @@
+package graphql
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+	handle(mux, "/localObs_Dunedin", `{"threeHour": {"temp": "11"}}`)
+	h := NewHandler(client)
+
+	const query = `query Temp($loc: String!) { observation(location: $loc) { threeHour { temp } } }`
+	const want = `{"data":{"observation":{"threeHour":{"temp":11}}}}` + "\n"
+	get := "/graphql?" + url.Values{
+		"query":     {query},
+		"variables": {`{"loc": "Dunedin"}`},
+	}.Encode()
+
+	testCases := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		want        string
+	}{
+		{
+			name:   "get",
+			method: "GET", target: get,
+			status: http.StatusOK, want: want,
+		},
+		{
+			name:   "post json",
+			method: "POST", target: "/graphql", contentType: "application/json",
+			body:   `{"query": "` + strings.Replace(query, `"`, `\"`, -1) + `", "operationName": "Temp", "variables": {"loc": "Dunedin"}}`,
+			status: http.StatusOK, want: want,
+		},
+		{
+			name:   "post graphql",
+			method: "POST", target: "/graphql", contentType: "application/graphql",
+			body:   `{ observation(location: "Dunedin") { threeHour { temp } } }`,
+			status: http.StatusOK, want: want,
+		},
+		{
+			name:   "invalid query",
+			method: "GET", target: "/graphql?query={nothing}",
+			status: http.StatusBadRequest,
+			want:   `{"errors":[{"message":"Cannot query field \"nothing\" on type \"Query\".","locations":[{"line":1,"column":2}]}]}` + "\n",
+		},
+		{
+			name:   "missing query",
+			method: "POST", target: "/graphql", body: `{}`,
+			status: http.StatusBadRequest,
+			want:   `{"errors":[{"message":"Must provide query string."}]}` + "\n",
+		},
+		{
+			name:   "bad json",
+			method: "POST", target: "/graphql", body: `{`,
+			status: http.StatusBadRequest,
+			want:   `{"errors":[{"message":"Body is invalid JSON."}]}` + "\n",
+		},
+		{
+			name:   "bad content type",
+			method: "POST", target: "/graphql", contentType: "text/plain", body: `{}`,
+			status: http.StatusUnsupportedMediaType,
+			want:   `{"errors":[{"message":"Unsupported content type text/plain."}]}` + "\n",
+		},
+		{
+			name:   "options",
+			method: "OPTIONS", target: "/graphql",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "bad method",
+			method: "DELETE", target: "/graphql",
+			status: http.StatusMethodNotAllowed,
+			want:   `{"errors":[{"message":"DELETE is not allowed."}]}` + "\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Errorf("got status %d, want %d", rec.Code, tc.status)
+			}
+			if got := rec.Body.String(); got != tc.want {
+				t.Errorf("got body %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
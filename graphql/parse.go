@@ -0,0 +1,724 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This is a parser for GraphQL query documents, see
+// https://spec.graphql.org/October2021/#sec-Document. Type system
+// definitions aren't supported as the schema is fixed.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	loc  Location
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "<EOF>"
+	case tokString:
+		return strconv.Quote(t.val)
+	case tokPunct:
+		return `"` + t.val + `"`
+	}
+	return t.val
+}
+
+func syntaxError(loc Location, format string, args ...interface{}) *Error {
+	return &Error{
+		Message:   "Syntax Error: " + fmt.Sprintf(format, args...),
+		Locations: []Location{loc},
+	}
+}
+
+// lex splits src into tokens, dropping whitespace, commas and comments.
+func lex(src string) ([]token, error) {
+	var toks []token
+	line, lineStart := 1, 0
+	i := 0
+	for i < len(src) {
+		loc := Location{Line: line, Column: i - lineStart + 1}
+		c := src[i]
+		switch {
+		case c == '\n':
+			i++
+			line, lineStart = line+1, i
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\ufeff"):
+			i += len("\ufeff")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			toks = append(toks, token{tokPunct, "...", loc})
+			i += 3
+		case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+			toks = append(toks, token{tokPunct, string(c), loc})
+			i++
+		case c == '_' || isLetter(c):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || isLetter(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			toks = append(toks, token{tokName, src[i:j], loc})
+			i = j
+		case c == '-' || isDigit(c):
+			j, kind, err := lexNumber(src, i)
+			if err != "" {
+				return nil, syntaxError(loc, "%s", err)
+			}
+			toks = append(toks, token{kind, src[i:j], loc})
+			i = j
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			for end >= 0 && src[i+3+end-1] == '\\' {
+				next := strings.Index(src[i+3+end+3:], `"""`)
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += 3 + next
+			}
+			if end < 0 {
+				return nil, syntaxError(loc, "Unterminated string.")
+			}
+			raw := src[i+3 : i+3+end]
+			toks = append(toks, token{tokString, blockString(raw), loc})
+			for _, r := range raw {
+				if r == '\n' {
+					line++
+				}
+			}
+			i += 3 + end + 3
+			if n := strings.LastIndexByte(src[:i], '\n'); n >= 0 {
+				lineStart = n + 1
+			}
+		case c == '"':
+			s, n, err := lexString(src[i:])
+			if err != "" {
+				return nil, syntaxError(loc, "%s", err)
+			}
+			toks = append(toks, token{tokString, s, loc})
+			i += n
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return nil, syntaxError(loc, "Unexpected character %q.", r)
+		}
+	}
+	loc := Location{Line: line, Column: len(src) - lineStart + 1}
+	return append(toks, token{tokEOF, "", loc}), nil
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+// lexNumber returns the end of the number starting at src[i].
+func lexNumber(src string, i int) (int, tokenKind, string) {
+	kind := tokInt
+	j := i
+	if src[j] == '-' {
+		j++
+	}
+	digits := func() bool {
+		start := j
+		for j < len(src) && isDigit(src[j]) {
+			j++
+		}
+		return j > start
+	}
+	if !digits() {
+		return j, kind, "Invalid number, expected digit."
+	}
+	if j < len(src) && src[j] == '.' {
+		kind = tokFloat
+		j++
+		if !digits() {
+			return j, kind, "Invalid number, expected digit."
+		}
+	}
+	if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+		kind = tokFloat
+		j++
+		if j < len(src) && (src[j] == '+' || src[j] == '-') {
+			j++
+		}
+		if !digits() {
+			return j, kind, "Invalid number, expected digit."
+		}
+	}
+	if j < len(src) && (src[j] == '_' || src[j] == '.' || isLetter(src[j])) {
+		return j, kind, fmt.Sprintf("Invalid number, unexpected character %q.", src[j])
+	}
+	return j, kind, ""
+}
+
+// lexString decodes the quoted string at the start of src, returning it and
+// the number of bytes it used.
+func lexString(src string) (string, int, string) {
+	var b strings.Builder
+	i := 1
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1, ""
+		case c == '\n' || c == '\r':
+			return "", i, "Unterminated string."
+		case c == '\\':
+			if i+1 >= len(src) {
+				return "", i, "Unterminated string."
+			}
+			switch e := src[i+1]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if i+6 > len(src) {
+					return "", i, "Invalid Unicode escape sequence."
+				}
+				n, err := strconv.ParseUint(src[i+2:i+6], 16, 16)
+				if err != nil {
+					return "", i, "Invalid Unicode escape sequence."
+				}
+				b.WriteRune(rune(n))
+				i += 4
+			default:
+				return "", i, fmt.Sprintf("Invalid character escape sequence: \\%c.", e)
+			}
+			i += 2
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return "", i, "Unterminated string."
+}
+
+// blockString removes the common indentation and blank first and last lines
+// of a block string.
+func blockString(raw string) string {
+	raw = strings.Replace(raw, `\"""`, `"""`, -1)
+	lines := strings.Split(strings.Replace(raw, "\r\n", "\n", -1), "\n")
+	indent := -1
+	for _, l := range lines[1:] {
+		n := len(l) - len(strings.TrimLeft(l, " \t"))
+		if n < len(l) && (indent < 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// document is a parsed query.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	vars       []*varDef
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+type varDef struct {
+	name     string
+	typ      *typeRef
+	defValue interface{}
+	hasDef   bool
+	loc      Location
+}
+
+type fragment struct {
+	name       string
+	on         string
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+// selection is a *field, *fragmentSpread or *inlineFragment.
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+// key is the name of the field in the response.
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	on         string
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+type argument struct {
+	name  string
+	value interface{}
+	loc   Location
+}
+
+type directive struct {
+	name string
+	args []*argument
+	loc  Location
+}
+
+// Values are parsed to nil, bool, int, float64, string, enumValue,
+// variable, []interface{} or map[string]interface{}.
+type (
+	enumValue string
+	variable  string
+)
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// parse parses a query document.
+func parse(src string) (*document, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.peek().kind != tokEOF {
+		t := p.peek()
+		switch {
+		case t.kind == tokPunct && t.val == "{":
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: sels, loc: t.loc})
+		case t.kind == tokName && (t.val == "query" || t.val == "mutation" || t.val == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case t.kind == tokName && t.val == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, &Error{
+					Message:   fmt.Sprintf("There can be only one fragment named %q.", f.name),
+					Locations: []Location{f.loc},
+				}
+			}
+			doc.fragments[f.name] = f
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "Document does not contain an operation."}
+	}
+	return doc, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	return syntaxError(t.loc, "Unexpected %s.", t)
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.val == s
+}
+
+// skipPunct consumes s if it is next.
+func (p *parser) skipPunct(s string) bool {
+	if p.isPunct(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.skipPunct(s) {
+		t := p.peek()
+		return syntaxError(t.loc, "Expected %q, found %s.", s, t)
+	}
+	return nil
+}
+
+func (p *parser) expectName() (token, error) {
+	t := p.peek()
+	if t.kind != tokName {
+		return t, syntaxError(t.loc, "Expected Name, found %s.", t)
+	}
+	return p.next(), nil
+}
+
+func (p *parser) operation() (*operation, error) {
+	t := p.next()
+	op := &operation{kind: t.val, loc: t.loc}
+	if p.peek().kind == tokName {
+		op.name = p.next().val
+	}
+	if p.skipPunct("(") {
+		for !p.skipPunct(")") {
+			v, err := p.varDef()
+			if err != nil {
+				return nil, err
+			}
+			op.vars = append(op.vars, v)
+		}
+	}
+	var err error
+	if op.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if op.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) varDef() (*varDef, error) {
+	loc := p.peek().loc
+	if err := p.expectPunct("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct(":"); err != nil {
+		return nil, err
+	}
+	v := &varDef{name: name.val, loc: loc}
+	if v.typ, err = p.typ(); err != nil {
+		return nil, err
+	}
+	if p.skipPunct("=") {
+		v.hasDef = true
+		if v.defValue, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+	// Directives on variables are allowed but have no meaning here.
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (p *parser) typ() (*typeRef, error) {
+	var t *typeRef
+	if p.skipPunct("[") {
+		elem, err := p.typ()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+		t = &typeRef{elem: elem}
+	} else {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		t = &typeRef{name: name.val}
+	}
+	t.nonNull = p.skipPunct("!")
+	return t, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	loc := p.next().loc
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if name.val == "on" {
+		return nil, syntaxError(name.loc, "Unexpected Name \"on\".")
+	}
+	if t := p.next(); t.kind != tokName || t.val != "on" {
+		return nil, syntaxError(t.loc, "Expected \"on\", found %s.", t)
+	}
+	on, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	f := &fragment{name: name.val, on: on.val, loc: loc}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if f.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var sels []selection
+	for !p.skipPunct("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, s)
+	}
+	if len(sels) == 0 {
+		return nil, p.unexpected()
+	}
+	return sels, nil
+}
+
+func (p *parser) selection() (selection, error) {
+	loc := p.peek().loc
+	if p.skipPunct("...") {
+		if t := p.peek(); t.kind == tokName && t.val != "on" {
+			p.next()
+			dirs, err := p.directives()
+			if err != nil {
+				return nil, err
+			}
+			return &fragmentSpread{name: t.val, directives: dirs, loc: loc}, nil
+		}
+		f := &inlineFragment{loc: loc}
+		if t := p.peek(); t.kind == tokName && t.val == "on" {
+			p.next()
+			on, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			f.on = on.val
+		}
+		var err error
+		if f.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	f := &field{name: name.val, loc: loc}
+	if p.skipPunct(":") {
+		n, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		f.alias, f.name = f.name, n.val
+	}
+	if f.args, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.isPunct("{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments(constant bool) ([]*argument, error) {
+	if !p.skipPunct("(") {
+		return nil, nil
+	}
+	var args []*argument
+	for !p.skipPunct(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, &argument{name: name.val, value: v, loc: name.loc})
+	}
+	if len(args) == 0 {
+		return nil, p.unexpected()
+	}
+	return args, nil
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var dirs []*directive
+	for p.isPunct("@") {
+		loc := p.next().loc
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, &directive{name: name.val, args: args, loc: loc})
+	}
+	return dirs, nil
+}
+
+// value parses a value. Variables aren't allowed in a constant value.
+func (p *parser) value(constant bool) (interface{}, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokInt:
+		p.next()
+		n, err := strconv.Atoi(t.val)
+		if err != nil {
+			return nil, syntaxError(t.loc, "Invalid Int %s.", t.val)
+		}
+		return n, nil
+	case tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, syntaxError(t.loc, "Invalid Float %s.", t.val)
+		}
+		return f, nil
+	case tokString:
+		p.next()
+		return t.val, nil
+	case tokName:
+		p.next()
+		switch t.val {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return enumValue(t.val), nil
+	case tokPunct:
+		switch t.val {
+		case "$":
+			if constant {
+				break
+			}
+			p.next()
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			return variable(name.val), nil
+		case "[":
+			p.next()
+			list := []interface{}{}
+			for !p.skipPunct("]") {
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		case "{":
+			p.next()
+			obj := make(map[string]interface{})
+			for !p.skipPunct("}") {
+				name, err := p.expectName()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectPunct(":"); err != nil {
+					return nil, err
+				}
+				if obj[name.val], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			return obj, nil
+		}
+	}
+	return nil, p.unexpected()
+}
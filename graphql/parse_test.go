@@ -0,0 +1,99 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# The weather at home.
+		query Home($loc: String! = "Dunedin", $skip: Boolean) {
+			now: observation(location: $loc) {
+				threeHour { temp @skip(if: $skip) }
+			}
+			...Days
+			... on Query { pollen(location: "Dunedin") { pollenDays { type } } }
+		}
+
+		fragment Days on Query {
+			forecast(location: """
+				Dunedin
+			""") { days { max, min } }
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.operations) != 1 || len(doc.fragments) != 1 {
+		t.Fatalf("got %d operations and %d fragments, want 1 of each", len(doc.operations), len(doc.fragments))
+	}
+	op := doc.operations[0]
+	if op.kind != "query" || op.name != "Home" {
+		t.Errorf("got operation %s %s, want query Home", op.kind, op.name)
+	}
+	if len(op.vars) != 2 || op.vars[0].typ.String() != "String!" || op.vars[0].defValue != "Dunedin" || op.vars[1].hasDef {
+		t.Errorf("variables parsed wrong: %+v %+v", op.vars[0], op.vars[1])
+	}
+
+	now := op.selections[0].(*field)
+	if now.alias != "now" || now.name != "observation" || now.args[0].value != variable("loc") {
+		t.Errorf("got field %+v, want now: observation(location: $loc)", now)
+	}
+	temp := now.selections[0].(*field).selections[0].(*field)
+	if len(temp.directives) != 1 || temp.directives[0].name != "skip" {
+		t.Errorf("temp directives parsed wrong: %+v", temp.directives)
+	}
+	if s, ok := op.selections[1].(*fragmentSpread); !ok || s.name != "Days" {
+		t.Errorf("got %#v, want spread of Days", op.selections[1])
+	}
+	if f, ok := op.selections[2].(*inlineFragment); !ok || f.on != "Query" {
+		t.Errorf("got %#v, want inline fragment on Query", op.selections[2])
+	}
+
+	forecast := doc.fragments["Days"].selections[0].(*field)
+	if got := forecast.args[0].value; got != "Dunedin" {
+		t.Errorf("block string parsed as %q, want %q", got, "Dunedin")
+	}
+	if got := forecast.loc; got != (Location{Line: 12, Column: 4}) {
+		t.Errorf("forecast location is %+v, want 12:4", got)
+	}
+}
+
+func TestParse_values(t *testing.T) {
+	doc, err := parse(`{ f(a: [1, -2.5e1, "xé\n", true, null, ENUM, {b: $c}]) }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		1, -25.0, "xé\n", true, nil, enumValue("ENUM"),
+		map[string]interface{}{"b": variable("c")},
+	}
+	got := doc.operations[0].selections[0].(*field).args[0].value
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("values mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{``, "Document does not contain an operation."},
+		{`{`, `1:2: Syntax Error: Expected Name, found <EOF>.`},
+		{`{ a }}`, `1:6: Syntax Error: Unexpected "}".`},
+		{`{ a(b: "c) }`, `1:8: Syntax Error: Unterminated string.`},
+		{`{ a(b: 1x) }`, `1:8: Syntax Error: Invalid number, unexpected character 'x'.`},
+		{`{ a ? }`, `1:5: Syntax Error: Unexpected character '?'.`},
+		{"query Q($a: Int = $b) { a }", `1:19: Syntax Error: Unexpected "$".`},
+		{"fragment F on Query { a }\nfragment F on Query { b }", `2:1: There can be only one fragment named "F".`},
+	}
+	for _, tc := range testCases {
+		_, err := parse(tc.query)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("parse(%q) returned %v, want %s", tc.query, err, tc.want)
+		}
+	}
+}
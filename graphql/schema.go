@@ -0,0 +1,229 @@
+package graphql
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// The schema is built from the library's types, so it always matches them.
+// Each exported struct field becomes a field named in lower camel case, such
+// as ForecastWord becoming forecastWord, and each struct type becomes an
+// object type of the same name. Timestamps are RFC 3339 strings.
+
+// typeRef refers to a type, such as String! or [ForecastDay!].
+type typeRef struct {
+	name    string   // named type, if elem is nil
+	elem    *typeRef // list element type
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// named returns the named type at the bottom of any lists.
+func (t *typeRef) named() string {
+	for t.elem != nil {
+		t = t.elem
+	}
+	return t.name
+}
+
+var scalars = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+}
+
+type objectType struct {
+	name   string
+	fields []*fieldDef
+	byName map[string]*fieldDef
+}
+
+type fieldDef struct {
+	name  string
+	typ   *typeRef
+	args  []*argDef
+	index []int // of the struct field holding the value
+
+	// endpoint is fetched to resolve a Query field.
+	endpoint metservice.Endpoint
+}
+
+func (f *fieldDef) arg(name string) *argDef {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+type argDef struct {
+	name string
+	typ  *typeRef
+}
+
+type schemaTypes struct {
+	types map[string]*objectType
+	order []string
+}
+
+var timestampType = reflect.TypeOf(metservice.Timestamp{})
+
+// schema is the GraphQL schema, with the root query type named Query.
+var schema = buildSchema()
+
+func buildSchema() *schemaTypes {
+	s := &schemaTypes{types: make(map[string]*objectType)}
+	query := s.add("Query")
+	roots := []struct {
+		name     string
+		endpoint metservice.Endpoint
+		typ      reflect.Type
+	}{
+		{"forecast", metservice.EndpointForecast, reflect.TypeOf(metservice.Forecast{})},
+		{"observation", metservice.EndpointObservation, reflect.TypeOf(metservice.Observation{})},
+		{"observationForecastHours", metservice.EndpointObservationForecastHours, reflect.TypeOf(metservice.ObservationForecastHours{})},
+		{"pollen", metservice.EndpointPollen, reflect.TypeOf(metservice.Pollen{})},
+		{"riseSet", metservice.EndpointRiseSet, reflect.TypeOf(metservice.RiseSet{})},
+	}
+	for _, r := range roots {
+		t := s.object(r.typ)
+		query.addField(&fieldDef{
+			name:     r.name,
+			typ:      &typeRef{name: t.name},
+			args:     []*argDef{{name: "location", typ: &typeRef{name: "String", nonNull: true}}},
+			endpoint: r.endpoint,
+		})
+	}
+	return s
+}
+
+func (s *schemaTypes) add(name string) *objectType {
+	t := &objectType{name: name, byName: make(map[string]*fieldDef)}
+	s.types[name] = t
+	s.order = append(s.order, name)
+	return t
+}
+
+func (t *objectType) addField(f *fieldDef) {
+	t.fields = append(t.fields, f)
+	t.byName[f.name] = f
+}
+
+// object returns the object type for the struct type rt, adding it and the
+// types of its fields if needed.
+func (s *schemaTypes) object(rt reflect.Type) *objectType {
+	if t, ok := s.types[rt.Name()]; ok {
+		return t
+	}
+	t := s.add(rt.Name())
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		ref := s.ref(sf.Type)
+		if ref == nil {
+			continue
+		}
+		t.addField(&fieldDef{name: lowerCamel(sf.Name), typ: ref, index: sf.Index})
+	}
+	return t
+}
+
+// ref returns the type for a Go type, or nil if it has none. Pointers and
+// slices may be null.
+func (s *schemaTypes) ref(rt reflect.Type) *typeRef {
+	var ref *typeRef
+	switch rt.Kind() {
+	case reflect.Ptr:
+		ref = s.ref(rt.Elem())
+		if ref != nil {
+			ref.nonNull = false
+		}
+		return ref
+	case reflect.Slice:
+		elem := s.ref(rt.Elem())
+		if elem == nil {
+			return nil
+		}
+		return &typeRef{elem: elem}
+	case reflect.String:
+		ref = &typeRef{name: "String"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ref = &typeRef{name: "Int"}
+	case reflect.Float32, reflect.Float64:
+		ref = &typeRef{name: "Float"}
+	case reflect.Bool:
+		ref = &typeRef{name: "Boolean"}
+	case reflect.Struct:
+		if rt == timestampType {
+			ref = &typeRef{name: "String"}
+		} else {
+			ref = &typeRef{name: s.object(rt).name}
+		}
+	default:
+		return nil
+	}
+	ref.nonNull = true
+	return ref
+}
+
+// lowerCamel lower cases the leading capitals of a Go name, so ID becomes
+// id and LocationGFS becomes locationGFS.
+func lowerCamel(name string) string {
+	r := []rune(name)
+	n := 0
+	for n < len(r) && unicode.IsUpper(r[n]) {
+		n++
+	}
+	if n > 1 && n < len(r) {
+		n-- // the last capital starts the next word
+	}
+	for i := 0; i < n; i++ {
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+// SDL returns the schema in the GraphQL schema definition language.
+func SDL() string {
+	var b strings.Builder
+	for i, name := range schema.order {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		t := schema.types[name]
+		b.WriteString("type " + t.name + " {\n")
+		for _, f := range t.fields {
+			b.WriteString("  " + f.name)
+			if len(f.args) > 0 {
+				b.WriteString("(")
+				for j, a := range f.args {
+					if j > 0 {
+						b.WriteString(", ")
+					}
+					b.WriteString(a.name + ": " + a.typ.String())
+				}
+				b.WriteString(")")
+			}
+			b.WriteString(": " + f.typ.String() + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
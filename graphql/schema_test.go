@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLowerCamel(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{"Days", "days"},
+		{"ForecastWord", "forecastWord"},
+		{"ID", "id"},
+		{"LocationGFS", "locationGFS"},
+		{"IDNumber", "idNumber"},
+	}
+	for _, tc := range testCases {
+		if got := lowerCamel(tc.name); got != tc.want {
+			t.Errorf("lowerCamel(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSDL(t *testing.T) {
+	sdl := SDL()
+	for _, want := range []string{
+		"type Query {\n  forecast(location: String!): Forecast\n",
+		"  riseSet(location: String!): RiseSet\n}\n",
+		"type Forecast {\n  days: [ForecastDay!]\n",
+		"type ForecastDay {\n  date: String\n",
+		"  part: DayPart\n  riseSet: RiseSet\n",
+		"type ObservationHour {\n  date: String\n  offset: Int\n  rainfall: Float\n",
+		"  enabled: Boolean\n",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL is missing %q", want)
+		}
+	}
+	// RiseSet is used by both Query and ForecastDay but only defined once.
+	if n := strings.Count(sdl, "type RiseSet {"); n != 1 {
+		t.Errorf("RiseSet is defined %d times, want once", n)
+	}
+}
@@ -0,0 +1,283 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// validate checks an operation against the schema, returning every problem
+// found. The variables must already be coerced.
+func validate(doc *document, op *operation, vars map[string]interface{}) []*Error {
+	v := &validator{
+		doc:       doc,
+		vars:      vars,
+		defined:   make(map[string]bool),
+		validated: make(map[string]bool),
+	}
+	for _, d := range op.vars {
+		v.defined[d.name] = true
+	}
+	v.directives(op.directives, "QUERY")
+	query := schema.types["Query"]
+	v.selections(query, op.selections, nil)
+	v.conflicts(query, op.selections)
+	return v.errs
+}
+
+// maxSpreads is the most fragment spreads a query may expand while being
+// validated, so that a deep chain of fragments can't tie up the server.
+const maxSpreads = 1000
+
+type validator struct {
+	doc     *document
+	vars    map[string]interface{}
+	defined map[string]bool
+	errs    []*Error
+
+	// validated holds the fragments whose selections have been checked.
+	// A fragment's selections don't depend on where it's spread, so each is
+	// only checked once.
+	validated map[string]bool
+	// spreads counts the fragment spreads expanded, up to maxSpreads.
+	spreads int
+}
+
+// expand counts a fragment spread, reporting whether it's within
+// maxSpreads. The error for going over is only added once.
+func (v *validator) expand(loc Location) bool {
+	v.spreads++
+	if v.spreads == maxSpreads+1 {
+		v.errorf(loc, "Query expands more than %d fragment spreads.", maxSpreads)
+	}
+	return v.spreads <= maxSpreads
+}
+
+func (v *validator) errorf(loc Location, format string, args ...interface{}) {
+	v.errs = append(v.errs, &Error{
+		Message:   fmt.Sprintf(format, args...),
+		Locations: []Location{loc},
+	})
+}
+
+// selections checks a selection set on t. spreading holds the fragments
+// being expanded, to catch cycles. A fragment that has already been checked
+// isn't expanded again.
+func (v *validator) selections(t *objectType, sels []selection, spreading []string) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			v.field(t, s)
+		case *fragmentSpread:
+			v.directives(s.directives, "FRAGMENT_SPREAD")
+			f, ok := v.doc.fragments[s.name]
+			if !ok {
+				v.errorf(s.loc, "Unknown fragment %q.", s.name)
+				continue
+			}
+			cycle := false
+			for _, name := range spreading {
+				if name == s.name {
+					cycle = true
+				}
+			}
+			if cycle {
+				v.errorf(s.loc, "Cannot spread fragment %q within itself.", s.name)
+				continue
+			}
+			if !v.typeCondition(t, f.on, s.loc, s.name) || v.validated[s.name] {
+				continue
+			}
+			if !v.expand(s.loc) {
+				return
+			}
+			v.validated[s.name] = true
+			v.directives(f.directives, "FRAGMENT_DEFINITION")
+			v.selections(t, f.selections, append(spreading, s.name))
+		case *inlineFragment:
+			v.directives(s.directives, "INLINE_FRAGMENT")
+			if s.on != "" && !v.typeCondition(t, s.on, s.loc, "") {
+				continue
+			}
+			v.selections(t, s.selections, spreading)
+		}
+	}
+}
+
+// typeCondition checks a fragment on type "on" can be spread in t. As the
+// schema only has object types they must be the same.
+func (v *validator) typeCondition(t *objectType, on string, loc Location, name string) bool {
+	if _, ok := schema.types[on]; !ok {
+		v.errorf(loc, "Unknown type %q.", on)
+		return false
+	}
+	if on == t.name {
+		return true
+	}
+	if name == "" {
+		v.errorf(loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", t.name, on)
+	} else {
+		v.errorf(loc, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", name, t.name, on)
+	}
+	return false
+}
+
+func (v *validator) field(t *objectType, f *field) {
+	v.directives(f.directives, "FIELD")
+	if f.name == "__typename" {
+		if len(f.args) > 0 {
+			v.errorf(f.args[0].loc, "Unknown argument %q on field \"__typename\".", f.args[0].name)
+		}
+		if f.selections != nil {
+			v.errorf(f.loc, "Field \"__typename\" must not have a selection since type \"String!\" has no subfields.")
+		}
+		return
+	}
+	def, ok := t.byName[f.name]
+	if !ok {
+		v.errorf(f.loc, "Cannot query field %q on type %q.", f.name, t.name)
+		return
+	}
+	v.arguments(f.args, def.args, f.loc, fmt.Sprintf("Field %q", f.name), t.name+"."+f.name)
+
+	named := def.typ.named()
+	if scalars[named] {
+		if f.selections != nil {
+			v.errorf(f.loc, "Field %q must not have a selection since type %q has no subfields.", f.name, def.typ)
+		}
+		return
+	}
+	if f.selections == nil {
+		v.errorf(f.loc, "Field %q of type %q must have a selection of subfields. Did you mean \"%s { ... }\"?", f.name, def.typ, f.name)
+		return
+	}
+	v.selections(schema.types[named], f.selections, nil)
+	v.conflicts(schema.types[named], f.selections)
+}
+
+// arguments checks the arguments given to a field or directive. owner names
+// it in messages about missing arguments and qualified in messages about
+// unknown ones.
+func (v *validator) arguments(args []*argument, defs []*argDef, loc Location, owner, qualified string) {
+	seen := make(map[string]bool)
+	for _, a := range args {
+		if seen[a.name] {
+			v.errorf(a.loc, "There can be only one argument named %q.", a.name)
+			continue
+		}
+		seen[a.name] = true
+		var def *argDef
+		for _, d := range defs {
+			if d.name == a.name {
+				def = d
+			}
+		}
+		if def == nil {
+			v.errorf(a.loc, "Unknown argument %q on %s.", a.name, qualified)
+			continue
+		}
+		if !v.variablesDefined(a.value, a.loc) {
+			continue
+		}
+		if _, err := coerce(a.value, def.typ, v.vars); err != nil {
+			v.errorf(a.loc, "Argument %q has invalid value %s. %v", a.name, printValue(a.value), err)
+		}
+	}
+	for _, d := range defs {
+		if d.typ.nonNull && !seen[d.name] {
+			v.errorf(loc, "%s argument %q of type %q is required, but it was not provided.", owner, d.name, d.typ)
+		}
+	}
+}
+
+// variablesDefined reports whether every variable used in value is
+// defined by the operation.
+func (v *validator) variablesDefined(value interface{}, loc Location) bool {
+	ok := true
+	switch x := value.(type) {
+	case variable:
+		if !v.defined[string(x)] {
+			v.errorf(loc, "Variable \"$%s\" is not defined.", x)
+			ok = false
+		}
+	case []interface{}:
+		for _, e := range x {
+			ok = v.variablesDefined(e, loc) && ok
+		}
+	case map[string]interface{}:
+		for _, e := range x {
+			ok = v.variablesDefined(e, loc) && ok
+		}
+	}
+	return ok
+}
+
+var ifArg = []*argDef{{name: "if", typ: &typeRef{name: "Boolean", nonNull: true}}}
+
+// directives checks @skip and @include, the only directives supported.
+func (v *validator) directives(dirs []*directive, where string) {
+	seen := make(map[string]bool)
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		if where == "QUERY" || where == "FRAGMENT_DEFINITION" {
+			v.errorf(d.loc, "Directive \"@%s\" may not be used on %s.", d.name, where)
+			continue
+		}
+		if seen[d.name] {
+			v.errorf(d.loc, "The directive \"@%s\" can only be used once at this location.", d.name)
+			continue
+		}
+		seen[d.name] = true
+		v.arguments(d.args, ifArg, d.loc, "Directive \"@"+d.name+"\"", "directive \"@"+d.name+"\"")
+	}
+}
+
+// conflicts reports fields with the same response name in a selection set
+// that aren't the same field with the same arguments, as they can't be
+// merged. Each fragment is only walked once per selection set.
+func (v *validator) conflicts(t *objectType, sels []selection) {
+	byKey := make(map[string]*field)
+	var walk func(sels []selection, seen map[string]bool)
+	walk = func(sels []selection, seen map[string]bool) {
+		for _, sel := range sels {
+			switch s := sel.(type) {
+			case *field:
+				prev, ok := byKey[s.key()]
+				if !ok {
+					byKey[s.key()] = s
+					continue
+				}
+				if prev.name != s.name {
+					v.errorf(s.loc, "Fields %q conflict because %q and %q are different fields. Use different aliases on the fields to fetch both if this was intentional.", s.key(), prev.name, s.name)
+				} else if argString(prev.args) != argString(s.args) {
+					v.errorf(s.loc, "Fields %q conflict because they have differing arguments. Use different aliases on the fields to fetch both if this was intentional.", s.key())
+				}
+			case *fragmentSpread:
+				if f, ok := v.doc.fragments[s.name]; ok && !seen[s.name] && f.on == t.name {
+					seen[s.name] = true
+					if !v.expand(s.loc) {
+						return
+					}
+					walk(f.selections, seen)
+				}
+			case *inlineFragment:
+				if s.on == "" || s.on == t.name {
+					walk(s.selections, seen)
+				}
+			}
+		}
+	}
+	walk(sels, make(map[string]bool))
+}
+
+func argString(args []*argument) string {
+	s := make([]string, len(args))
+	for i, a := range args {
+		s[i] = a.name + ":" + fmt.Sprintf("%#v", a.value)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
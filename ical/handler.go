@@ -0,0 +1,82 @@
+package ical
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Handler serves a calendar for the location named by the last element of
+// the request path, such as /calendars/Dunedin.ics. The calendar holds the
+// daily forecasts and the sun and moon times for each day they're known.
+type Handler struct {
+	Client *metservice.Client
+}
+
+// NewHandler returns a Handler that fetches with client.
+func NewHandler(client *metservice.Client) *Handler {
+	return &Handler{Client: client}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	location := strings.TrimSuffix(path.Base(r.URL.Path), ".ics")
+	if location == "" || location == "/" || location == "." {
+		http.Error(w, "missing location", http.StatusNotFound)
+		return
+	}
+
+	cal, err := h.calendar(r.Context(), location)
+	if err != nil {
+		code := http.StatusBadGateway
+		if e, ok := err.(metservice.StatusError); ok && e.Code == http.StatusNotFound {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	var b bytes.Buffer
+	cal.WriteTo(&b)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="`+strings.Replace(location, `"`, "", -1)+`.ics"`)
+	if r.Method != http.MethodHead {
+		b.WriteTo(w)
+	}
+}
+
+// calendar fetches the forecast and today's rise and set times for
+// location. A failure of the rise and set request alone isn't an error, as
+// the forecast usually includes them.
+func (h *Handler) calendar(ctx context.Context, location string) (*Calendar, error) {
+	var (
+		wg                 sync.WaitGroup
+		forecast           *metservice.Forecast
+		riseSet            *metservice.RiseSet
+		forecastErr, rsErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		forecast, _, forecastErr = h.Client.GetForecast(ctx, location)
+	}()
+	go func() {
+		defer wg.Done()
+		riseSet, _, rsErr = h.Client.GetRiseSet(ctx, location)
+	}()
+	wg.Wait()
+	if forecastErr != nil {
+		return nil, forecastErr
+	}
+
+	cal := &Calendar{Name: "Weather for " + location}
+	if rsErr == nil {
+		cal.AddRiseSet(location, riseSet)
+	}
+	cal.AddForecast(location, forecast)
+	return cal, nil
+}
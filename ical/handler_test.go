@@ -0,0 +1,52 @@
+package ical
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := metservice.NewClient()
+	client.BaseURL = server.URL + "/"
+
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"days": [{"dateISO": "2006-01-02T00:00:00+13:00", "forecastWord": "Fine"}]}`)
+	})
+	mux.HandleFunc("/riseSet_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sunRiseISO": "2006-01-02T05:59:00+13:00"}`)
+	})
+
+	h := NewHandler(client)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/calendars/Dunedin.ics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("got Content-Type %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"UID:forecast-20060102-dunedin@metservice-go\r\n",
+		"SUMMARY:Fine\r\n",
+		"DTSTART;TZID=Pacific/Auckland:20060102T055900\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("calendar missing %q:\n%s", want, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/calendars/Nowhere.ics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown location got status %d, want 404", rec.Code)
+	}
+}
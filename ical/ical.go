@@ -0,0 +1,298 @@
+// ical generates iCalendar (RFC 5545) feeds of sun and moon times and daily
+// forecasts, suitable for subscribing to from a calendar app.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// TZID is the time zone all event times are written in. A matching
+// VTIMEZONE is included in every calendar.
+const TZID = "Pacific/Auckland"
+
+// vtimezone describes New Zealand time as in force since 2007.
+const vtimezone = `BEGIN:VTIMEZONE
+TZID:Pacific/Auckland
+BEGIN:DAYLIGHT
+TZOFFSETFROM:+1200
+TZOFFSETTO:+1300
+TZNAME:NZDT
+DTSTART:19700927T020000
+RRULE:FREQ=YEARLY;BYMONTH=9;BYDAY=-1SU
+END:DAYLIGHT
+BEGIN:STANDARD
+TZOFFSETFROM:+1300
+TZOFFSETTO:+1200
+TZNAME:NZST
+DTSTART:19700405T030000
+RRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU
+END:STANDARD
+END:VTIMEZONE
+`
+
+// Event is a single VEVENT.
+type Event struct {
+	// UID identifies the event. Calendar apps replace an event with a newer
+	// one with the same UID, so it should be stable across updates.
+	UID string
+	// Start and End of the event. For an all day event only the date of
+	// Start, in New Zealand, is used. A zero End makes an event without a
+	// duration.
+	Start, End time.Time
+	AllDay     bool
+	Summary    string
+	// Description and Location are optional.
+	Description string
+	Location    string
+	// Stamp is when the event was last changed. It defaults to the time the
+	// calendar is written.
+	Stamp time.Time
+}
+
+// Calendar is a collection of events.
+type Calendar struct {
+	// Name is shown by calendar apps that support it.
+	Name   string
+	Events []Event
+}
+
+// Add adds events to the calendar, replacing any existing events with the
+// same UID.
+func (c *Calendar) Add(events ...Event) {
+	for _, e := range events {
+		replaced := false
+		for i := range c.Events {
+			if c.Events[i].UID == e.UID {
+				c.Events[i] = e
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			c.Events = append(c.Events, e)
+		}
+	}
+}
+
+// AddRiseSet adds an event for each of the first light, sunrise, sunset,
+// last light, moonrise and moonset times in rs. Times that are nil are
+// skipped.
+func (c *Calendar) AddRiseSet(location string, rs *metservice.RiseSet) {
+	if rs == nil {
+		return
+	}
+	times := []struct {
+		kind    string
+		summary string
+		t       *metservice.Timestamp
+	}{
+		{"firstlight", "First light", rs.FirstLight},
+		{"sunrise", "Sunrise", rs.SunRise},
+		{"sunset", "Sunset", rs.SunSet},
+		{"lastlight", "Last light", rs.LastLight},
+		{"moonrise", "Moonrise", rs.MoonRise},
+		{"moonset", "Moonset", rs.MoonSet},
+	}
+	for _, t := range times {
+		if t.t == nil {
+			continue
+		}
+		c.Add(Event{
+			UID:      uid(t.kind, t.t.Time, location),
+			Start:    t.t.Time,
+			Summary:  t.summary,
+			Location: location,
+		})
+	}
+}
+
+// AddForecast adds an all day event for each day of f summarising its
+// forecast, along with the sun and moon times of each day that has them.
+func (c *Calendar) AddForecast(location string, f *metservice.Forecast) {
+	if f == nil {
+		return
+	}
+	for _, d := range f.Days {
+		if d.Date == nil {
+			continue
+		}
+		e := Event{
+			UID:         uid("forecast", d.Date.Time, location),
+			Start:       d.Date.Time,
+			AllDay:      true,
+			Summary:     summary(d),
+			Description: str(d.Forecast),
+			Location:    location,
+		}
+		if d.IssuedAt != nil {
+			e.Stamp = d.IssuedAt.Time
+		}
+		c.Add(e)
+		c.AddRiseSet(location, d.RiseSet)
+	}
+}
+
+// summary returns a short description of a day such as "Showers, 8–14°C".
+func summary(d metservice.ForecastDay) string {
+	s := str(d.ForecastWord)
+	if s == "" {
+		s = "Forecast"
+	}
+	switch {
+	case d.Min != nil && d.Max != nil:
+		s += fmt.Sprintf(", %d–%d°C", *d.Min, *d.Max)
+	case d.Max != nil:
+		s += fmt.Sprintf(", high %d°C", *d.Max)
+	case d.Min != nil:
+		s += fmt.Sprintf(", low %d°C", *d.Min)
+	}
+	return s
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// uid returns a UID that stays the same for an event of kind on the same
+// New Zealand day in location.
+func uid(kind string, t time.Time, location string) string {
+	slug := strings.ToLower(strings.Join(strings.Fields(location), "-"))
+	return fmt.Sprintf("%s-%s-%s@metservice-go", kind, nzTime(t).Format("20060102"), slug)
+}
+
+// WriteTo writes the calendar to w. Events are sorted by start time and then
+// UID so the output is stable.
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	return c.write(w, time.Now())
+}
+
+func (c *Calendar) write(w io.Writer, now time.Time) (int64, error) {
+	events := append([]Event(nil), c.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+		return events[i].UID < events[j].UID
+	})
+
+	var b bytes.Buffer
+	line := func(s string) {
+		fold(&b, s)
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//metservice-go//ical//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME:" + escape(c.Name))
+	}
+	line("X-WR-TIMEZONE:" + TZID)
+	for _, l := range strings.Split(strings.TrimSpace(vtimezone), "\n") {
+		line(l)
+	}
+	for _, e := range events {
+		stamp := e.Stamp
+		if stamp.IsZero() {
+			stamp = now
+		}
+		line("BEGIN:VEVENT")
+		line("UID:" + escape(e.UID))
+		line("DTSTAMP:" + stamp.UTC().Format("20060102T150405Z"))
+		if e.AllDay {
+			start := nzTime(e.Start)
+			day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			end := day.AddDate(0, 0, 1)
+			if !e.End.IsZero() {
+				end = nzTime(e.End)
+				end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+				if !end.After(day) {
+					end = day.AddDate(0, 0, 1)
+				}
+			}
+			line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + end.Format("20060102"))
+		} else {
+			line("DTSTART;TZID=" + TZID + ":" + nzTime(e.Start).Format("20060102T150405"))
+			if !e.End.IsZero() {
+				line("DTEND;TZID=" + TZID + ":" + nzTime(e.End).Format("20060102T150405"))
+			}
+		}
+		// Weather shouldn't show as busy time.
+		line("TRANSP:TRANSPARENT")
+		line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escape(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION:" + escape(e.Location))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.WriteTo(w)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// fold writes a content line ending in CRLF, folding it so no line is
+// longer than 75 octets without splitting a UTF-8 sequence.
+func fold(b *bytes.Buffer, s string) {
+	limit := 75
+	for len(s) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(s[i]) {
+			i--
+		}
+		b.WriteString(s[:i])
+		b.WriteString("\r\n ")
+		s = s[i:]
+		// Continuation lines start with a space, which counts.
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
+
+// nzTime returns t as wall clock time in New Zealand, in a UTC location,
+// using the daylight saving rules in force since 2007. This avoids relying
+// on the system's time zone database.
+func nzTime(t time.Time) time.Time {
+	u := t.UTC()
+	y := u.Year()
+	// Daylight time starts at 2am NZST on the last Sunday in September and
+	// ends at 3am NZDT on the first Sunday in April.
+	start := time.Date(y, time.September, lastSunday(y, time.September), 2, 0, 0, 0, time.UTC).Add(-12 * time.Hour)
+	end := time.Date(y, time.April, firstSunday(y, time.April), 3, 0, 0, 0, time.UTC).Add(-13 * time.Hour)
+	offset := 12 * time.Hour
+	if u.Before(end) || !u.Before(start) {
+		offset = 13 * time.Hour
+	}
+	return u.Add(offset)
+}
+
+func firstSunday(year int, month time.Month) int {
+	d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return 1 + (7-int(d.Weekday()))%7
+}
+
+func lastSunday(year int, month time.Month) int {
+	d := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	return d.Day() - int(d.Weekday())
+}
@@ -0,0 +1,150 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+func ts(s string) *metservice.Timestamp {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return &metservice.Timestamp{Time: t}
+}
+
+func TestCalendar_Write(t *testing.T) {
+	f := &metservice.Forecast{
+		Days: []metservice.ForecastDay{
+			{
+				Date:         ts("2006-01-02T00:00:00+13:00"),
+				IssuedAt:     ts("2006-01-01T16:00:00+13:00"),
+				ForecastWord: metservice.String("Showers"),
+				Forecast:     metservice.String("Showers, some heavy; clearing later."),
+				Min:          metservice.Int(8),
+				Max:          metservice.Int(14),
+				RiseSet: &metservice.RiseSet{
+					SunRise: ts("2006-01-02T05:59:00+13:00"),
+					SunSet:  ts("2006-01-02T21:40:00+13:00"),
+				},
+			},
+		},
+	}
+	var cal Calendar
+	cal.Name = "Weather for Dunedin"
+	// A time in UTC is still written in New Zealand time.
+	cal.AddRiseSet("Dunedin", &metservice.RiseSet{FirstLight: ts("2006-01-01T16:20:00Z")})
+	cal.AddForecast("Dunedin", f)
+	// Adding the same days again replaces the events.
+	cal.AddForecast("Dunedin", f)
+	if len(cal.Events) != 4 {
+		t.Errorf("got %d events, want 4", len(cal.Events))
+	}
+
+	var b bytes.Buffer
+	now := time.Date(2006, time.January, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := cal.write(&b, now); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//metservice-go//ical//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Weather for Dunedin",
+		"X-WR-TIMEZONE:Pacific/Auckland",
+		strings.Replace(strings.TrimSpace(vtimezone), "\n", "\r\n", -1),
+		"BEGIN:VEVENT",
+		"UID:forecast-20060102-dunedin@metservice-go",
+		"DTSTAMP:20060101T030000Z",
+		"DTSTART;VALUE=DATE:20060102",
+		"DTEND;VALUE=DATE:20060103",
+		"TRANSP:TRANSPARENT",
+		`SUMMARY:Showers\, 8–14°C`,
+		`DESCRIPTION:Showers\, some heavy\; clearing later.`,
+		"LOCATION:Dunedin",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:firstlight-20060102-dunedin@metservice-go",
+		"DTSTAMP:20060102T030405Z",
+		"DTSTART;TZID=Pacific/Auckland:20060102T052000",
+		"TRANSP:TRANSPARENT",
+		"SUMMARY:First light",
+		"LOCATION:Dunedin",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:sunrise-20060102-dunedin@metservice-go",
+		"DTSTAMP:20060102T030405Z",
+		"DTSTART;TZID=Pacific/Auckland:20060102T055900",
+		"TRANSP:TRANSPARENT",
+		"SUMMARY:Sunrise",
+		"LOCATION:Dunedin",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:sunset-20060102-dunedin@metservice-go",
+		"DTSTAMP:20060102T030405Z",
+		"DTSTART;TZID=Pacific/Auckland:20060102T214000",
+		"TRANSP:TRANSPARENT",
+		"SUMMARY:Sunset",
+		"LOCATION:Dunedin",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFold(t *testing.T) {
+	var b bytes.Buffer
+	s := "DESCRIPTION:" + strings.Repeat("é", 70)
+	fold(&b, s)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	if len(lines) < 2 {
+		t.Fatalf("got %d lines, want the line folded", len(lines))
+	}
+	var joined string
+	for i, l := range lines {
+		if len(l) > 75 {
+			t.Errorf("line %d is %d octets", i, len(l))
+		}
+		if i > 0 {
+			if !strings.HasPrefix(l, " ") {
+				t.Errorf("continuation line %d doesn't start with a space", i)
+			}
+			l = l[1:]
+		}
+		joined += l
+	}
+	if joined != s {
+		t.Errorf("unfolded to %q, want %q", joined, s)
+	}
+}
+
+func TestNZTime(t *testing.T) {
+	testCases := []struct {
+		utc  string
+		want string
+	}{
+		{"2006-01-01T12:00:00Z", "2006-01-02 01:00"}, // summer
+		{"2006-07-01T12:00:00Z", "2006-07-02 00:00"}, // winter
+		// 2023 daylight time ended 2 April 03:00 NZDT and began 24 September
+		// 02:00 NZST.
+		{"2023-04-01T13:59:00Z", "2023-04-02 02:59"},
+		{"2023-04-01T14:00:00Z", "2023-04-02 02:00"},
+		{"2023-09-23T13:59:00Z", "2023-09-24 01:59"},
+		{"2023-09-23T14:00:00Z", "2023-09-24 03:00"},
+	}
+	for _, tc := range testCases {
+		got := nzTime(ts(tc.utc).Time).Format("2006-01-02 15:04")
+		if got != tc.want {
+			t.Errorf("nzTime(%s) = %s, want %s", tc.utc, got, tc.want)
+		}
+	}
+}
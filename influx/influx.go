@@ -0,0 +1,225 @@
+// influx encodes metservice observations and forecasts in the InfluxDB line
+// protocol, which is also accepted by VictoriaMetrics and other time series
+// databases, and writes them in batches.
+package influx
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Measurements written by the encoders in this package.
+const (
+	MeasurementHourly    = "metservice_hourly"
+	MeasurementThreeHour = "metservice_three_hour"
+	MeasurementOneMinute = "metservice_one_minute"
+)
+
+// Values of the source tag.
+const (
+	SourceObserved = "observed"
+	SourceForecast = "forecast"
+)
+
+// Point is a single line of line protocol. Field values may be int, int64,
+// float64, bool or string, anything else is written as a string. NaN and
+// infinite floats are skipped as the protocol can't represent them.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+}
+
+// String returns p as a line of line protocol without the trailing newline.
+func (p Point) String() string {
+	var b bytes.Buffer
+	p.appendTo(&b)
+	return b.String()
+}
+
+// appendTo writes p to b as a line of line protocol. Tags and fields are
+// sorted by key so the output is stable. Nothing is written if p has no
+// fields, as such a line is invalid.
+func (p Point) appendTo(b *bytes.Buffer) bool {
+	fields := make([]string, 0, len(p.Fields))
+	for k, v := range p.Fields {
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			continue
+		}
+		fields = append(fields, k)
+	}
+	if len(fields) == 0 {
+		return false
+	}
+	sort.Strings(fields)
+	tags := make([]string, 0, len(p.Tags))
+	for k, v := range p.Tags {
+		// Empty tag values are not allowed.
+		if v != "" {
+			tags = append(tags, k)
+		}
+	}
+	sort.Strings(tags)
+
+	b.WriteString(measurementEscaper.Replace(p.Measurement))
+	for _, k := range tags {
+		b.WriteByte(',')
+		b.WriteString(keyEscaper.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(keyEscaper.Replace(p.Tags[k]))
+	}
+	for i, k := range fields {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(keyEscaper.Replace(k))
+		b.WriteByte('=')
+		switch v := p.Fields[k].(type) {
+		case int:
+			b.WriteString(strconv.Itoa(v) + "i")
+		case int64:
+			b.WriteString(strconv.FormatInt(v, 10) + "i")
+		case float64:
+			b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			b.WriteString(strconv.FormatBool(v))
+		case string:
+			b.WriteString(`"` + stringEscaper.Replace(v) + `"`)
+		default:
+			b.WriteString(`"` + stringEscaper.Replace(fmt.Sprint(v)) + `"`)
+		}
+	}
+	if !p.Time.IsZero() {
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(p.Time.UnixNano(), 10))
+	}
+	return true
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// fields collects the non-nil values of an observation or forecast.
+type fields map[string]interface{}
+
+func (f fields) int(k string, v *int) {
+	if v != nil {
+		f[k] = *v
+	}
+}
+
+func (f fields) float(k string, v *float64) {
+	if v != nil {
+		f[k] = *v
+	}
+}
+
+func (f fields) string(k string, v *string) {
+	if v != nil && *v != "" {
+		f[k] = *v
+	}
+}
+
+func (f fields) bool(k string, v *bool) {
+	if v != nil {
+		f[k] = *v
+	}
+}
+
+func point(measurement, location, source string, date *metservice.Timestamp, f fields) (Point, bool) {
+	if date == nil || len(f) == 0 {
+		return Point{}, false
+	}
+	tags := map[string]string{"location": location}
+	if source != "" {
+		tags["source"] = source
+	}
+	return Point{Measurement: measurement, Tags: tags, Fields: f, Time: date.Time}, true
+}
+
+// ObservationHour returns a point for an hourly observation. The second
+// result is false if h has no date or no values.
+func ObservationHour(location string, h metservice.ObservationHour) (Point, bool) {
+	f := fields{}
+	f.float("temp", h.Temp)
+	f.float("rainfall", h.Rainfall)
+	f.int("wind_speed", h.WindSpeed)
+	f.string("wind_direction", h.WindDirection)
+	return point(MeasurementHourly, location, SourceObserved, h.Date, f)
+}
+
+// ForecastHour returns a point for an hourly forecast. Temperatures are
+// written as floats so they share a type with ObservationHour. The second
+// result is false if h has no date or no values.
+func ForecastHour(location string, h metservice.ForecastHour) (Point, bool) {
+	f := fields{}
+	if h.Temp != nil {
+		f["temp"] = float64(*h.Temp)
+	}
+	f.float("rainfall", h.Rainfall)
+	f.int("humidity", h.Humidity)
+	f.int("wind_speed", h.WindSpeed)
+	f.string("wind_direction", h.WindDirection)
+	return point(MeasurementHourly, location, SourceForecast, h.Date, f)
+}
+
+// ObservationForecastHours returns points for all the observations and
+// forecasts in ofh, skipping any without a date or values.
+func ObservationForecastHours(location string, ofh *metservice.ObservationForecastHours) []Point {
+	var points []Point
+	for _, h := range ofh.Observations {
+		if p, ok := ObservationHour(location, h); ok {
+			points = append(points, p)
+		}
+	}
+	for _, h := range ofh.Forecasts {
+		if p, ok := ForecastHour(location, h); ok {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// ObservationThreeHour returns a point for a three hourly observation. The
+// second result is false if o has no date or no values.
+func ObservationThreeHour(location string, o *metservice.ObservationThreeHour) (Point, bool) {
+	if o == nil {
+		return Point{}, false
+	}
+	f := fields{}
+	f.int("temp", o.Temp)
+	f.int("humidity", o.Humidity)
+	f.float("rainfall", o.Rainfall)
+	f.int("wind_chill", o.WindChill)
+	f.int("wind_speed", o.WindSpeed)
+	f.string("wind_direction", o.WindDirection)
+	f.string("pressure", o.Pressure)
+	return point(MeasurementThreeHour, location, SourceObserved, o.Date, f)
+}
+
+// ObservationOneMin returns a point for a one minute observation. The
+// second result is false if o has no date or no values.
+func ObservationOneMin(location string, o *metservice.ObservationOneMin) (Point, bool) {
+	if o == nil {
+		return Point{}, false
+	}
+	f := fields{}
+	f.float("rainfall", o.Rainfall)
+	f.int("humidity", o.RelativeHumidity)
+	f.string("status", o.Status)
+	f.bool("current", o.Current)
+	return point(MeasurementOneMinute, location, SourceObserved, o.Date, f)
+}
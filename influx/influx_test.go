@@ -0,0 +1,103 @@
+package influx
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+var referenceTime = time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+
+func TestPoint_String(t *testing.T) {
+	testCases := []struct {
+		desc string
+		p    Point
+		want string
+	}{
+		{
+			"Types",
+			Point{
+				Measurement: "m",
+				Tags:        map[string]string{"b": "2", "a": "1"},
+				Fields: map[string]interface{}{
+					"i": 1, "f": 1.5, "s": "x", "t": true, "l": int64(2), "big": 1e21,
+				},
+				Time: referenceTime,
+			},
+			`m,a=1,b=2 big=1000000000000000000000,f=1.5,i=1i,l=2i,s="x",t=true 1136214245000000000`,
+		},
+		{
+			"Escaping",
+			Point{
+				Measurement: "my m,x",
+				Tags:        map[string]string{"loc": "Mt Cook, NZ", "k=v": "a", "empty": ""},
+				Fields:      map[string]interface{}{"a b": `say "hi" \o/`},
+			},
+			`my\ m\,x,k\=v=a,loc=Mt\ Cook\,\ NZ a\ b="say \"hi\" \\o/"`,
+		},
+		{
+			"NaN",
+			Point{Measurement: "m", Fields: map[string]interface{}{"a": math.NaN(), "b": 1.0}},
+			`m b=1`,
+		},
+		{
+			"No fields",
+			Point{Measurement: "m", Fields: map[string]interface{}{"a": math.Inf(1)}},
+			``,
+		},
+	}
+	for _, tc := range testCases {
+		if got := tc.p.String(); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	date := &metservice.Timestamp{Time: referenceTime}
+	ofh := &metservice.ObservationForecastHours{
+		Observations: []metservice.ObservationHour{
+			{Date: date, Temp: metservice.Float64(10.5), Rainfall: metservice.Float64(0), WindDirection: metservice.String("NW")},
+			{Temp: metservice.Float64(11)}, // no date
+		},
+		Forecasts: []metservice.ForecastHour{
+			{Date: &metservice.Timestamp{Time: referenceTime.Add(time.Hour)}, Temp: metservice.Int(12), Humidity: metservice.Int(80)},
+			{Date: date}, // no values
+		},
+	}
+	var got []string
+	for _, p := range ObservationForecastHours("Dunedin", ofh) {
+		got = append(got, p.String())
+	}
+	p, ok := ObservationThreeHour("Dunedin", &metservice.ObservationThreeHour{
+		Date: date, Temp: metservice.Int(9), Pressure: metservice.String("Rising"), WindChill: metservice.Int(7),
+	})
+	if !ok {
+		t.Fatal("ObservationThreeHour returned false")
+	}
+	got = append(got, p.String())
+	p, ok = ObservationOneMin("Dunedin", &metservice.ObservationOneMin{
+		Date: date, Rainfall: metservice.Float64(0.2), RelativeHumidity: metservice.Int(90), Current: metservice.Bool(true),
+	})
+	if !ok {
+		t.Fatal("ObservationOneMin returned false")
+	}
+	got = append(got, p.String())
+
+	want := []string{
+		`metservice_hourly,location=Dunedin,source=observed rainfall=0,temp=10.5,wind_direction="NW" 1136214245000000000`,
+		`metservice_hourly,location=Dunedin,source=forecast humidity=80i,temp=12 1136217845000000000`,
+		`metservice_three_hour,location=Dunedin,source=observed pressure="Rising",temp=9i,wind_chill=7i 1136214245000000000`,
+		`metservice_one_minute,location=Dunedin,source=observed current=true,humidity=90i,rainfall=0.2 1136214245000000000`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("encoders mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, ok := ObservationOneMin("Dunedin", nil); ok {
+		t.Error("ObservationOneMin(nil) returned true")
+	}
+}
@@ -0,0 +1,137 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// DefaultBatchSize is the number of points a Writer buffers before
+// flushing.
+const DefaultBatchSize = 5000
+
+// Writer buffers points and writes them as line protocol in batches. Each
+// batch is passed to the underlying io.Writer in a single Write call, so an
+// HTTP sink sends one request per batch. It is safe for concurrent use.
+type Writer struct {
+	// BatchSize is the number of points to buffer before flushing.
+	BatchSize int
+
+	mu  sync.Mutex
+	w   io.Writer
+	buf bytes.Buffer
+	n   int
+}
+
+// NewWriter returns a Writer that writes batches of DefaultBatchSize points
+// to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{BatchSize: DefaultBatchSize, w: w}
+}
+
+// Write adds points to the current batch, flushing once it is full. Points
+// without any fields are dropped.
+func (w *Writer) Write(points ...Point) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	size := w.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	for _, p := range points {
+		if !p.appendTo(&w.buf) {
+			continue
+		}
+		w.buf.WriteByte('\n')
+		w.n++
+		if w.n >= size {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered points. On error the points are kept and sent
+// again by the next Flush.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	if _, err := w.w.Write(w.buf.Bytes()); err != nil {
+		return err
+	}
+	w.buf.Reset()
+	w.n = 0
+	return nil
+}
+
+// HTTP is an io.Writer that POSTs each write to an InfluxDB compatible
+// write endpoint, such as http://localhost:8086/api/v2/write?bucket=b or
+// VictoriaMetrics' http://localhost:8428/write. Timestamps are in
+// nanoseconds, which is the default precision for both.
+type HTTP struct {
+	URL string
+	// Token, if set, is sent in an "Authorization: Token ..." header.
+	Token string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Context is used for each request. It defaults to
+	// context.Background.
+	Context context.Context
+}
+
+// Write sends p as the body of a single request. A non 2xx status is
+// returned as a StatusError.
+func (h *HTTP) Write(p []byte) (int, error) {
+	ctx := h.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(p))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Token "+h.Token)
+	}
+	client := h.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to do request: %v", err)
+	}
+	defer rsp.Body.Close()
+	io.Copy(ioutil.Discard, rsp.Body)
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return 0, StatusError{Code: rsp.StatusCode}
+	}
+	return len(p), nil
+}
+
+// StatusError is returned by HTTP when the server responds with a non 2xx
+// status.
+type StatusError struct {
+	Code int
+}
+
+var _ error = StatusError{}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("bad response status code: %d", e.Code)
+}
@@ -0,0 +1,81 @@
+package influx
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recorder records each Write call, optionally failing them.
+type recorder struct {
+	writes []string
+	err    error
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	r.writes = append(r.writes, string(p))
+	return len(p), nil
+}
+
+func TestWriter(t *testing.T) {
+	rec := &recorder{}
+	w := NewWriter(rec)
+	w.BatchSize = 2
+	p := func(v int) Point {
+		return Point{Measurement: "m", Fields: map[string]interface{}{"v": v}}
+	}
+
+	if err := w.Write(p(1), Point{Measurement: "empty"}, p(2), p(3)); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.writes) != 1 || rec.writes[0] != "m v=1i\nm v=2i\n" {
+		t.Errorf("got writes %q after first batch", rec.writes)
+	}
+
+	rec.err = errors.New("down")
+	if err := w.Flush(); err == nil {
+		t.Error("Flush returned nil error while the sink is failing")
+	}
+	rec.err = nil
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.writes) != 2 || rec.writes[1] != "m v=3i\n" {
+		t.Errorf("got writes %q after flush, want the failed batch retried", rec.writes)
+	}
+	if err := w.Flush(); err != nil || len(rec.writes) != 2 {
+		t.Errorf("empty Flush wrote %q, %v", rec.writes, err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var body []byte
+	var auth string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	h := &HTTP{URL: server.URL + "/write", Token: "secret"}
+	line := []byte("m v=1i\n")
+	if n, err := h.Write(line); err != nil || n != len(line) {
+		t.Fatalf("Write returned %d, %v", n, err)
+	}
+	if !bytes.Equal(body, line) || auth != "Token secret" {
+		t.Errorf("server got body %q and auth %q", body, auth)
+	}
+
+	status = http.StatusBadRequest
+	if _, err := h.Write(line); err != (StatusError{Code: http.StatusBadRequest}) {
+		t.Errorf("Write returned %v, want StatusError 400", err)
+	}
+}
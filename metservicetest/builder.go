@@ -0,0 +1,316 @@
+package metservicetest
+
+import (
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+func timestamp(t time.Time) *metservice.Timestamp {
+	return &metservice.Timestamp{Time: t}
+}
+
+// ForecastBuilder builds a metservice.Forecast.
+type ForecastBuilder struct {
+	f      metservice.Forecast
+	issued *time.Time
+}
+
+// Forecast starts a forecast without any days.
+func Forecast() *ForecastBuilder {
+	return &ForecastBuilder{}
+}
+
+// Day adds a day to the forecast, with word as both its forecast word and
+// text.
+func (b *ForecastBuilder) Day(date time.Time, word string, min, max int) *ForecastBuilder {
+	b.f.Days = append(b.f.Days, metservice.ForecastDay{
+		Date:         timestamp(date),
+		Forecast:     metservice.String(word + "."),
+		ForecastWord: metservice.String(word),
+		Min:          metservice.Int(min),
+		Max:          metservice.Int(max),
+	})
+	return b
+}
+
+// Parts sets the icons of the last day added, for the morning, afternoon,
+// evening and overnight.
+func (b *ForecastBuilder) Parts(morning, afternoon, evening, overnight string) *ForecastBuilder {
+	if len(b.f.Days) == 0 {
+		return b
+	}
+	part := func(icon string) *metservice.DayPartTime {
+		return &metservice.DayPartTime{IconType: metservice.String(icon)}
+	}
+	b.f.Days[len(b.f.Days)-1].Part = &metservice.DayPart{
+		Morning:   part(morning),
+		Afternoon: part(afternoon),
+		Evening:   part(evening),
+		Overnight: part(overnight),
+	}
+	return b
+}
+
+// RiseSet sets the rise and set times of the last day added.
+func (b *ForecastBuilder) RiseSet(r *metservice.RiseSet) *ForecastBuilder {
+	if len(b.f.Days) > 0 {
+		b.f.Days[len(b.f.Days)-1].RiseSet = r
+	}
+	return b
+}
+
+// IssuedAt sets when every day of the forecast was issued.
+func (b *ForecastBuilder) IssuedAt(t time.Time) *ForecastBuilder {
+	b.issued = &t
+	return b
+}
+
+// Build returns the forecast.
+func (b *ForecastBuilder) Build() *metservice.Forecast {
+	f := b.f
+	f.Days = append([]metservice.ForecastDay(nil), b.f.Days...)
+	if b.issued != nil {
+		for i := range f.Days {
+			f.Days[i].IssuedAt = timestamp(*b.issued)
+		}
+	}
+	return &f
+}
+
+// ObservationBuilder builds a metservice.Observation.
+type ObservationBuilder struct {
+	o metservice.Observation
+}
+
+// Observation starts an observation with a three hourly reading taken at t.
+func Observation(t time.Time) *ObservationBuilder {
+	b := &ObservationBuilder{}
+	b.o.ThreeHour = &metservice.ObservationThreeHour{Date: timestamp(t)}
+	return b
+}
+
+// Temp sets the temperature and wind chill in °C.
+func (b *ObservationBuilder) Temp(temp, windChill int) *ObservationBuilder {
+	b.o.ThreeHour.Temp = metservice.Int(temp)
+	b.o.ThreeHour.WindChill = metservice.Int(windChill)
+	return b
+}
+
+// Humidity sets the relative humidity in percent.
+func (b *ObservationBuilder) Humidity(h int) *ObservationBuilder {
+	b.o.ThreeHour.Humidity = metservice.Int(h)
+	return b
+}
+
+// Wind sets the wind speed in km/h and direction, such as "SW".
+func (b *ObservationBuilder) Wind(speed int, direction string) *ObservationBuilder {
+	b.o.ThreeHour.WindSpeed = metservice.Int(speed)
+	b.o.ThreeHour.WindDirection = metservice.String(direction)
+	return b
+}
+
+// Rainfall sets the rainfall in mm.
+func (b *ObservationBuilder) Rainfall(mm float64) *ObservationBuilder {
+	b.o.ThreeHour.Rainfall = metservice.Float64(mm)
+	return b
+}
+
+// Pressure sets the pressure trend, such as "Rising".
+func (b *ObservationBuilder) Pressure(trend string) *ObservationBuilder {
+	b.o.ThreeHour.Pressure = metservice.String(trend)
+	return b
+}
+
+// TwentyFourHour sets the temperature range in °C and rainfall in mm of the
+// last 24 hours.
+func (b *ObservationBuilder) TwentyFourHour(min, max int, rainfall float64) *ObservationBuilder {
+	b.o.TwentyFourHour = &metservice.ObservationTwentyFourHour{
+		Min:      metservice.Int(min),
+		Max:      metservice.Int(max),
+		Rainfall: metservice.Float64(rainfall),
+	}
+	return b
+}
+
+// Build returns the observation.
+func (b *ObservationBuilder) Build() *metservice.Observation {
+	o := b.o
+	three := *b.o.ThreeHour
+	o.ThreeHour = &three
+	if b.o.TwentyFourHour != nil {
+		day := *b.o.TwentyFourHour
+		o.TwentyFourHour = &day
+	}
+	return &o
+}
+
+// OneMinBuilder builds a metservice.ObservationOneMin.
+type OneMinBuilder struct {
+	o metservice.ObservationOneMin
+}
+
+// OneMin starts a current one minute observation taken at t.
+func OneMin(t time.Time) *OneMinBuilder {
+	return &OneMinBuilder{o: metservice.ObservationOneMin{
+		Date:    timestamp(t),
+		Current: metservice.Bool(true),
+		Status:  metservice.String("ok"),
+	}}
+}
+
+// Rainfall sets the rainfall in mm.
+func (b *OneMinBuilder) Rainfall(mm float64) *OneMinBuilder {
+	b.o.Rainfall = metservice.Float64(mm)
+	return b
+}
+
+// Humidity sets the relative humidity in percent.
+func (b *OneMinBuilder) Humidity(h int) *OneMinBuilder {
+	b.o.RelativeHumidity = metservice.Int(h)
+	return b
+}
+
+// Stale marks the observation as no longer current.
+func (b *OneMinBuilder) Stale() *OneMinBuilder {
+	b.o.Current = metservice.Bool(false)
+	return b
+}
+
+// Build returns the observation.
+func (b *OneMinBuilder) Build() *metservice.ObservationOneMin {
+	o := b.o
+	return &o
+}
+
+// HourlyBuilder builds a metservice.ObservationForecastHours.
+type HourlyBuilder struct {
+	o metservice.ObservationForecastHours
+}
+
+// Hourly starts hourly data for location without any hours.
+func Hourly(location string) *HourlyBuilder {
+	return &HourlyBuilder{o: metservice.ObservationForecastHours{
+		Location:     metservice.String(location),
+		LocationName: metservice.String(location),
+	}}
+}
+
+// Observed adds an observed hour.
+func (b *HourlyBuilder) Observed(t time.Time, temp, rainfall float64, windSpeed int) *HourlyBuilder {
+	b.o.Observations = append(b.o.Observations, metservice.ObservationHour{
+		Date:      timestamp(t),
+		Temp:      metservice.Float64(temp),
+		Rainfall:  metservice.Float64(rainfall),
+		WindSpeed: metservice.Int(windSpeed),
+	})
+	return b
+}
+
+// Forecast adds a forecast hour.
+func (b *HourlyBuilder) Forecast(t time.Time, temp int, rainfall float64, windSpeed int) *HourlyBuilder {
+	b.o.Forecasts = append(b.o.Forecasts, metservice.ForecastHour{
+		Date:      timestamp(t),
+		Temp:      metservice.Int(temp),
+		Rainfall:  metservice.Float64(rainfall),
+		WindSpeed: metservice.Int(windSpeed),
+	})
+	return b
+}
+
+// Build returns the hourly data, with the count, totals and latest wind
+// speed worked out from the hours.
+func (b *HourlyBuilder) Build() *metservice.ObservationForecastHours {
+	o := b.o
+	o.Observations = append([]metservice.ObservationHour(nil), b.o.Observations...)
+	o.Forecasts = append([]metservice.ForecastHour(nil), b.o.Forecasts...)
+	o.Count = metservice.Int(len(o.Observations) + len(o.Forecasts))
+	var observed, forecast float64
+	for _, h := range o.Observations {
+		observed += *h.Rainfall
+	}
+	for _, h := range o.Forecasts {
+		forecast += *h.Rainfall
+	}
+	o.RainfallTotalObserved = metservice.Float64(observed)
+	o.RainfallTotalForecast = metservice.Float64(forecast)
+	if n := len(o.Observations); n > 0 {
+		o.WindSpeed = metservice.Int(*o.Observations[n-1].WindSpeed)
+	}
+	return &o
+}
+
+// PollenBuilder builds a metservice.Pollen.
+type PollenBuilder struct {
+	p metservice.Pollen
+}
+
+// Pollen starts an enabled pollen forecast for location without any days.
+func Pollen(location string) *PollenBuilder {
+	return &PollenBuilder{p: metservice.Pollen{
+		Location: metservice.String(location),
+		Enabled:  metservice.Bool(true),
+	}}
+}
+
+// Day adds a day, valid for 24 hours from t, with the main pollen type and
+// its level, such as "Grass" and "High".
+func (b *PollenBuilder) Day(t time.Time, typ, level string) *PollenBuilder {
+	descriptor := "Today"
+	if len(b.p.PollenDays) > 0 {
+		descriptor = "Tomorrow"
+	}
+	b.p.PollenDays = append(b.p.PollenDays, metservice.PollenDay{
+		DayDescriptor: metservice.String(descriptor),
+		Type:          metservice.String(typ),
+		Level:         metservice.String(level),
+		ValidFrom:     timestamp(t),
+		ValidTo:       timestamp(t.Add(24 * time.Hour)),
+	})
+	return b
+}
+
+// Build returns the pollen forecast.
+func (b *PollenBuilder) Build() *metservice.Pollen {
+	p := b.p
+	p.PollenDays = append([]metservice.PollenDay(nil), b.p.PollenDays...)
+	return &p
+}
+
+// RiseSetBuilder builds a metservice.RiseSet.
+type RiseSetBuilder struct {
+	r metservice.RiseSet
+}
+
+// RiseSet starts rise and set times for location on the day starting at
+// date.
+func RiseSet(location string, date time.Time) *RiseSetBuilder {
+	return &RiseSetBuilder{r: metservice.RiseSet{
+		Location: metservice.String(location),
+		Date:     timestamp(date),
+	}}
+}
+
+// Sun sets the sun rise and set.
+func (b *RiseSetBuilder) Sun(rise, set time.Time) *RiseSetBuilder {
+	b.r.SunRise, b.r.SunSet = timestamp(rise), timestamp(set)
+	return b
+}
+
+// Light sets the first and last light.
+func (b *RiseSetBuilder) Light(first, last time.Time) *RiseSetBuilder {
+	b.r.FirstLight, b.r.LastLight = timestamp(first), timestamp(last)
+	return b
+}
+
+// Moon sets the moon rise and set.
+func (b *RiseSetBuilder) Moon(rise, set time.Time) *RiseSetBuilder {
+	b.r.MoonRise, b.r.MoonSet = timestamp(rise), timestamp(set)
+	return b
+}
+
+// Build returns the rise and set times.
+func (b *RiseSetBuilder) Build() *metservice.RiseSet {
+	r := b.r
+	return &r
+}
@@ -0,0 +1,163 @@
+package metservicetest
+
+import (
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func ts(d time.Duration) *metservice.Timestamp {
+	return &metservice.Timestamp{Time: referenceTime.Add(d)}
+}
+
+func TestForecastBuilder(t *testing.T) {
+	b := Forecast().
+		Day(referenceTime, "Fine", 5, 14).
+		Parts("sunny", "sunny", "fine", "clear").
+		Day(referenceTime.Add(24*time.Hour), "Showers", 7, 12).
+		IssuedAt(referenceTime.Add(-time.Hour))
+	got := b.Build()
+	want := &metservice.Forecast{Days: []metservice.ForecastDay{
+		{
+			Date:         ts(0),
+			Forecast:     metservice.String("Fine."),
+			ForecastWord: metservice.String("Fine"),
+			IssuedAt:     ts(-time.Hour),
+			Min:          metservice.Int(5),
+			Max:          metservice.Int(14),
+			Part: &metservice.DayPart{
+				Morning:   &metservice.DayPartTime{IconType: metservice.String("sunny")},
+				Afternoon: &metservice.DayPartTime{IconType: metservice.String("sunny")},
+				Evening:   &metservice.DayPartTime{IconType: metservice.String("fine")},
+				Overnight: &metservice.DayPartTime{IconType: metservice.String("clear")},
+			},
+		},
+		{
+			Date:         ts(24 * time.Hour),
+			Forecast:     metservice.String("Showers."),
+			ForecastWord: metservice.String("Showers"),
+			IssuedAt:     ts(-time.Hour),
+			Min:          metservice.Int(7),
+			Max:          metservice.Int(12),
+		},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Build mismatch (-want +got):\n%s", diff)
+	}
+
+	// Building again after changing the builder leaves the first alone.
+	b.Day(referenceTime.Add(48*time.Hour), "Rain", 8, 10)
+	if len(got.Days) != 2 || len(b.Build().Days) != 3 {
+		t.Error("Build shares days with the builder")
+	}
+}
+
+func TestObservationBuilder(t *testing.T) {
+	got := Observation(referenceTime).
+		Temp(11, 9).
+		Humidity(80).
+		Wind(20, "SW").
+		Rainfall(0.4).
+		Pressure("Rising").
+		TwentyFourHour(6, 15, 2.2).
+		Build()
+	want := &metservice.Observation{
+		ThreeHour: &metservice.ObservationThreeHour{
+			Date:          ts(0),
+			Temp:          metservice.Int(11),
+			WindChill:     metservice.Int(9),
+			Humidity:      metservice.Int(80),
+			WindSpeed:     metservice.Int(20),
+			WindDirection: metservice.String("SW"),
+			Rainfall:      metservice.Float64(0.4),
+			Pressure:      metservice.String("Rising"),
+		},
+		TwentyFourHour: &metservice.ObservationTwentyFourHour{
+			Min:      metservice.Int(6),
+			Max:      metservice.Int(15),
+			Rainfall: metservice.Float64(2.2),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Build mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHourlyBuilder(t *testing.T) {
+	got := Hourly("Dunedin").
+		Observed(referenceTime, 10, 0.5, 15).
+		Observed(referenceTime.Add(time.Hour), 11, 0.25, 20).
+		Forecast(referenceTime.Add(2*time.Hour), 12, 1, 25).
+		Build()
+	want := &metservice.ObservationForecastHours{
+		Observations: []metservice.ObservationHour{
+			{Date: ts(0), Temp: metservice.Float64(10), Rainfall: metservice.Float64(0.5), WindSpeed: metservice.Int(15)},
+			{Date: ts(time.Hour), Temp: metservice.Float64(11), Rainfall: metservice.Float64(0.25), WindSpeed: metservice.Int(20)},
+		},
+		Forecasts: []metservice.ForecastHour{
+			{Date: ts(2 * time.Hour), Temp: metservice.Int(12), Rainfall: metservice.Float64(1), WindSpeed: metservice.Int(25)},
+		},
+		Count:                 metservice.Int(3),
+		WindSpeed:             metservice.Int(20),
+		Location:              metservice.String("Dunedin"),
+		LocationName:          metservice.String("Dunedin"),
+		RainfallTotalForecast: metservice.Float64(1),
+		RainfallTotalObserved: metservice.Float64(0.75),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Build mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPollenBuilder(t *testing.T) {
+	got := Pollen("Dunedin").
+		Day(referenceTime, "Grass", "High").
+		Day(referenceTime.Add(24*time.Hour), "Grass", "Moderate").
+		Build()
+	want := &metservice.Pollen{
+		Location: metservice.String("Dunedin"),
+		Enabled:  metservice.Bool(true),
+		PollenDays: []metservice.PollenDay{
+			{
+				DayDescriptor: metservice.String("Today"),
+				Type:          metservice.String("Grass"),
+				Level:         metservice.String("High"),
+				ValidFrom:     ts(0),
+				ValidTo:       ts(24 * time.Hour),
+			},
+			{
+				DayDescriptor: metservice.String("Tomorrow"),
+				Type:          metservice.String("Grass"),
+				Level:         metservice.String("Moderate"),
+				ValidFrom:     ts(24 * time.Hour),
+				ValidTo:       ts(48 * time.Hour),
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Build mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRiseSetBuilder(t *testing.T) {
+	got := RiseSet("Dunedin", referenceTime).
+		Light(referenceTime.Add(5*time.Hour), referenceTime.Add(22*time.Hour)).
+		Sun(referenceTime.Add(6*time.Hour), referenceTime.Add(21*time.Hour)).
+		Moon(referenceTime.Add(10*time.Hour), referenceTime.Add(23*time.Hour)).
+		Build()
+	want := &metservice.RiseSet{
+		Location:   metservice.String("Dunedin"),
+		Date:       ts(0),
+		FirstLight: ts(5 * time.Hour),
+		LastLight:  ts(22 * time.Hour),
+		SunRise:    ts(6 * time.Hour),
+		SunSet:     ts(21 * time.Hour),
+		MoonRise:   ts(10 * time.Hour),
+		MoonSet:    ts(23 * time.Hour),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Build mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,277 @@
+// metservicetest provides a fake metservice API for testing code that uses
+// the metservice package.
+//
+// A Server serves values set on it, usually made with the builders in this
+// package, and records the requests it receives:
+//
+//	srv := metservicetest.NewServer()
+//	defer srv.Close()
+//	srv.SetForecast("Dunedin", metservicetest.Forecast().
+//		Day(today, "Fine", 5, 14).
+//		Build())
+//	f, _, err := srv.Client().GetForecast(ctx, "Dunedin")
+//
+// Faults, such as error status codes or slow responses, can be injected
+// with Server.Fail.
+package metservicetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// paths maps the prefix of each API path to its endpoint. The location
+// follows the prefix.
+var paths = []struct {
+	prefix   string
+	endpoint metservice.Endpoint
+}{
+	{"localForecast", metservice.EndpointForecast},
+	{"localObs_", metservice.EndpointObservation},
+	{"oneMinObs_", metservice.EndpointObservationOneMin},
+	{"hourlyObsAndForecast_", metservice.EndpointObservationForecastHours},
+	{"pollen_town_", metservice.EndpointPollen},
+	{"riseSet_", metservice.EndpointRiseSet},
+}
+
+// Request is a request received by a Server.
+type Request struct {
+	// Endpoint and Location are empty if the path wasn't an API path.
+	Endpoint metservice.Endpoint
+	Location string
+	Method   string
+	Path     string
+	Header   http.Header
+	Time     time.Time
+}
+
+// Fault changes how a Server answers requests.
+type Fault struct {
+	// Delay waits before answering, or until the request is cancelled.
+	Delay time.Duration
+	// Status, if set, is sent with Body instead of the value.
+	Status int
+	Body   string
+	// Hangup closes the connection without answering. An http.Client
+	// retries a GET when a reused connection is closed like this, so leave
+	// Times as zero for the client to be sure to see an error.
+	Hangup bool
+	// Times is the number of requests the fault applies to. Zero applies
+	// it to every request.
+	Times int
+}
+
+type key struct {
+	endpoint metservice.Endpoint
+	location string
+}
+
+// Server is a fake metservice API listening on a local port. Locations
+// without a value for an endpoint are answered with 404 Not Found, as the
+// API does for unknown locations. It is safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the server, for metservice.Client.BaseURL.
+	URL string
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	values   map[key]interface{}
+	faults   map[key]*Fault
+	requests []Request
+}
+
+// NewServer starts and returns a new Server. The caller should call Close
+// when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		values: make(map[key]interface{}),
+		faults: make(map[key]*Fault),
+	}
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL + "/"
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a new client for the server.
+func (s *Server) Client() *metservice.Client {
+	c := metservice.NewClient()
+	c.HTTPClient = s.server.Client()
+	c.BaseURL = s.URL
+	return c
+}
+
+func (s *Server) set(endpoint metservice.Endpoint, location string, v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reflect.ValueOf(v).IsNil() {
+		delete(s.values, key{endpoint, location})
+		return
+	}
+	s.values[key{endpoint, location}] = v
+}
+
+// SetForecast sets the forecast served for location. Nil removes it.
+func (s *Server) SetForecast(location string, f *metservice.Forecast) {
+	s.set(metservice.EndpointForecast, location, f)
+}
+
+// SetObservation sets the observation served for location. Nil removes it.
+func (s *Server) SetObservation(location string, o *metservice.Observation) {
+	s.set(metservice.EndpointObservation, location, o)
+}
+
+// SetObservationOneMin sets the one minute observation served for
+// location. Nil removes it.
+func (s *Server) SetObservationOneMin(location string, o *metservice.ObservationOneMin) {
+	s.set(metservice.EndpointObservationOneMin, location, o)
+}
+
+// SetObservationForecastHours sets the hourly observations and forecasts
+// served for location. Nil removes them.
+func (s *Server) SetObservationForecastHours(location string, o *metservice.ObservationForecastHours) {
+	s.set(metservice.EndpointObservationForecastHours, location, o)
+}
+
+// SetPollen sets the pollen forecast served for location. Nil removes it.
+func (s *Server) SetPollen(location string, p *metservice.Pollen) {
+	s.set(metservice.EndpointPollen, location, p)
+}
+
+// SetRiseSet sets the rise and set times served for location. Nil removes
+// them.
+func (s *Server) SetRiseSet(location string, r *metservice.RiseSet) {
+	s.set(metservice.EndpointRiseSet, location, r)
+}
+
+// Fail injects f into requests for endpoint and location. An empty endpoint
+// or location matches every one, and a fault for a specific endpoint and
+// location is used before a more general one. Setting a zero Fault removes
+// it.
+func (s *Server) Fail(endpoint metservice.Endpoint, location string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key{endpoint, location}
+	if f == (Fault{}) {
+		delete(s.faults, k)
+		return
+	}
+	s.faults[k] = &f
+}
+
+// Requests returns the requests received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset forgets the received requests and removes every value and fault.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.values = make(map[key]interface{})
+	s.faults = make(map[key]*Fault)
+}
+
+// fault returns the fault to use for k, counting it as used.
+func (s *Server) fault(k key) *Fault {
+	for _, k := range []key{k, {k.endpoint, ""}, {"", k.location}, {"", ""}} {
+		f, ok := s.faults[k]
+		if !ok {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				delete(s.faults, k)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Time:   time.Now(),
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	for _, p := range paths {
+		if strings.HasPrefix(name, p.prefix) && len(name) > len(p.prefix) {
+			req.Endpoint = p.endpoint
+			req.Location = name[len(p.prefix):]
+			break
+		}
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	k := key{req.Endpoint, req.Location}
+	v, ok := s.values[k]
+	var f Fault
+	if req.Endpoint != "" {
+		if p := s.fault(k); p != nil {
+			f = *p
+		}
+	}
+	s.mu.Unlock()
+
+	if f.Delay > 0 {
+		t := time.NewTimer(f.Delay)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		}
+	}
+	if f.Hangup {
+		if hj, ok := w.(http.Hijacker); ok {
+			if c, _, err := hj.Hijack(); err == nil {
+				c.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if f.Status != 0 {
+		w.WriteHeader(f.Status)
+		w.Write([]byte(f.Body))
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
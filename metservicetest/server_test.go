@@ -0,0 +1,147 @@
+package metservicetest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+var referenceTime = time.Date(2006, time.January, 02, 0, 0, 0, 0, time.UTC)
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	want := Forecast().Day(referenceTime, "Fine", 5, 14).Build()
+	srv.SetForecast("Palmerston North", want)
+	srv.SetRiseSet("Dunedin", RiseSet("Dunedin", referenceTime).Build())
+
+	got, _, err := client.GetForecast(ctx, "Palmerston North")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("forecast mismatch (-want +got):\n%s", diff)
+	}
+
+	_, _, err = client.GetForecast(ctx, "Dunedin")
+	if diff := cmp.Diff(metservice.StatusError{Code: 404}, err); diff != "" {
+		t.Errorf("unknown location error mismatch (-want +got):\n%s", diff)
+	}
+
+	srv.SetForecast("Palmerston North", nil)
+	_, _, err = client.GetForecast(ctx, "Palmerston North")
+	if diff := cmp.Diff(metservice.StatusError{Code: 404}, err); diff != "" {
+		t.Errorf("removed forecast error mismatch (-want +got):\n%s", diff)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("got %d requests, want 3", len(reqs))
+	}
+	if r := reqs[0]; r.Endpoint != metservice.EndpointForecast || r.Location != "Palmerston North" ||
+		r.Method != http.MethodGet || r.Path != "/localForecastPalmerston North" {
+		t.Errorf("first request is %+v", r)
+	}
+	if r := reqs[1]; r.Location != "Dunedin" {
+		t.Errorf("second request is for %q, want Dunedin", r.Location)
+	}
+
+	srv.Reset()
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("got %d requests after Reset, want 0", n)
+	}
+}
+
+func TestServer_Fail(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+	srv.SetPollen("Dunedin", Pollen("Dunedin").Day(referenceTime, "Grass", "High").Build())
+	srv.SetRiseSet("Dunedin", RiseSet("Dunedin", referenceTime).Build())
+
+	// Fails twice with a specific fault, then falls back to the general one
+	// for every endpoint in Dunedin.
+	srv.Fail(metservice.EndpointPollen, "Dunedin", Fault{Status: 503, Times: 2})
+	srv.Fail("", "Dunedin", Fault{Status: 500, Body: "oops"})
+	for _, code := range []int{503, 503, 500} {
+		_, _, err := client.GetPollen(ctx, "Dunedin")
+		if diff := cmp.Diff(metservice.StatusError{Code: code}, err); diff != "" {
+			t.Errorf("pollen error mismatch (-want +got):\n%s", diff)
+		}
+	}
+	srv.Fail("", "Dunedin", Fault{})
+	if _, _, err := client.GetPollen(ctx, "Dunedin"); err != nil {
+		t.Errorf("got error %v after removing faults", err)
+	}
+
+	srv.Fail(metservice.EndpointRiseSet, "", Fault{Hangup: true})
+	if _, _, err := client.GetRiseSet(ctx, "Dunedin"); err == nil {
+		t.Error("hangup returned no error")
+	}
+	srv.Fail(metservice.EndpointRiseSet, "", Fault{})
+	if _, _, err := client.GetRiseSet(ctx, "Dunedin"); err != nil {
+		t.Errorf("got error %v after the hangups", err)
+	}
+
+	srv.Fail(metservice.EndpointRiseSet, "", Fault{Delay: time.Minute})
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := client.GetRiseSet(ctx, "Dunedin"); err == nil {
+		t.Error("delay beyond the deadline returned no error")
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("delayed request took %v", d)
+	}
+}
+
+func TestServer_paths(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	srv.SetForecast("Nelson", Forecast().Build())
+	srv.SetObservation("Nelson", Observation(referenceTime).Build())
+	srv.SetObservationOneMin("Nelson", OneMin(referenceTime).Build())
+	srv.SetObservationForecastHours("Nelson", Hourly("Nelson").Build())
+	srv.SetPollen("Nelson", Pollen("Nelson").Build())
+	srv.SetRiseSet("Nelson", RiseSet("Nelson", referenceTime).Build())
+
+	calls := []func() error{
+		func() error { _, _, err := client.GetForecast(ctx, "Nelson"); return err },
+		func() error { _, _, err := client.GetObservation(ctx, "Nelson"); return err },
+		func() error { _, _, err := client.GetObservationOneMin(ctx, "Nelson"); return err },
+		func() error { _, _, err := client.GetObservationForecastHours(ctx, "Nelson"); return err },
+		func() error { _, _, err := client.GetPollen(ctx, "Nelson"); return err },
+		func() error { _, _, err := client.GetRiseSet(ctx, "Nelson"); return err },
+	}
+	for _, call := range calls {
+		if err := call(); err != nil {
+			t.Error(err)
+		}
+	}
+	var got []metservice.Endpoint
+	for _, r := range srv.Requests() {
+		got = append(got, r.Endpoint)
+	}
+	want := []metservice.Endpoint{
+		metservice.EndpointForecast,
+		metservice.EndpointObservation,
+		metservice.EndpointObservationOneMin,
+		metservice.EndpointObservationForecastHours,
+		metservice.EndpointPollen,
+		metservice.EndpointRiseSet,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("endpoints mismatch (-want +got):\n%s", diff)
+	}
+}
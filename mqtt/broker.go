@@ -0,0 +1,307 @@
+package mqtt
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Broker is a small in-process MQTT 3.1.1 broker, enough for testing a
+// Publisher and for home automation setups without one. It keeps retained
+// messages and forwards publishes to matching subscriptions at QoS 0.
+// Sessions aren't persisted. The zero value is ready to use.
+type Broker struct {
+	mu        sync.Mutex
+	retained  map[string]Message
+	sessions  map[*session]bool
+	listeners []net.Listener
+	closed    bool
+}
+
+type session struct {
+	conn net.Conn
+	wmu  sync.Mutex
+
+	// Guarded by Broker.mu.
+	filters map[string]bool
+}
+
+func (s *session) write(p packet) error {
+	b, err := p.encode()
+	if err != nil {
+		return err
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_, err = s.conn.Write(b)
+	return err
+}
+
+// Serve accepts connections on l until it fails or the Broker is closed.
+func (b *Broker) Serve(l net.Listener) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	b.listeners = append(b.listeners, l)
+	b.mu.Unlock()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			b.mu.Lock()
+			closed := b.closed
+			b.mu.Unlock()
+			if closed {
+				return ErrClosed
+			}
+			return err
+		}
+		go b.ServeConn(c)
+	}
+}
+
+// Close stops every Serve and closes every connection.
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	ls, sessions := b.listeners, b.sessions
+	b.listeners, b.sessions = nil, nil
+	b.mu.Unlock()
+	for _, l := range ls {
+		l.Close()
+	}
+	for s := range sessions {
+		s.conn.Close()
+	}
+	return nil
+}
+
+// Retained returns the retained messages with topics matching filter,
+// keyed by topic.
+func (b *Broker) Retained(filter string) map[string]Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]Message)
+	for t, m := range b.retained {
+		if match(filter, t) {
+			out[t] = m
+		}
+	}
+	return out
+}
+
+// ServeConn handles a single client connection, returning when it ends.
+func (b *Broker) ServeConn(c net.Conn) {
+	defer c.Close()
+	s := &session{conn: c, filters: make(map[string]bool)}
+	r := bufio.NewReader(c)
+
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	p, err := readPacket(r)
+	if err != nil || p.typ != typeConnect {
+		return
+	}
+	will, keepAlive, code := parseConnect(p)
+	s.write(packet{typ: typeConnack, body: []byte{0, code}})
+	if code != 0 {
+		return
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	if b.sessions == nil {
+		b.sessions = make(map[*session]bool)
+	}
+	b.sessions[s] = true
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.sessions, s)
+		b.mu.Unlock()
+		if will != nil {
+			b.publish(*will)
+		}
+	}()
+
+	for {
+		deadline := time.Time{}
+		if keepAlive > 0 {
+			deadline = time.Now().Add(keepAlive * 3 / 2)
+		}
+		c.SetReadDeadline(deadline)
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.typ {
+		case typePublish:
+			m, id, err := parsePublish(p)
+			if err != nil || m.QoS > 1 {
+				return
+			}
+			b.publish(m)
+			if m.QoS == 1 {
+				s.write(packet{typ: typePuback, body: appendUint16(nil, id)})
+			}
+		case typeSubscribe:
+			d := decoder{b: p.body}
+			id := d.u16()
+			var filters []string
+			for len(d.b) > 0 && d.err == nil {
+				filters = append(filters, d.str())
+				d.u8() // requested QoS; everything is sent at 0
+			}
+			if d.err != nil || len(filters) == 0 {
+				return
+			}
+			ack := appendUint16(nil, id)
+			for _, f := range filters {
+				if validFilter(f) {
+					ack = append(ack, 0)
+				} else {
+					ack = append(ack, 0x80)
+				}
+			}
+			b.mu.Lock()
+			var retained []Message
+			for _, f := range filters {
+				if !validFilter(f) {
+					continue
+				}
+				s.filters[f] = true
+				for t, m := range b.retained {
+					if match(f, t) {
+						retained = append(retained, m)
+					}
+				}
+			}
+			b.mu.Unlock()
+			s.write(packet{typ: typeSuback, body: ack})
+			for _, m := range retained {
+				m.QoS = 0
+				s.write(publishPacket(m, 0))
+			}
+		case typeUnsubscribe:
+			d := decoder{b: p.body}
+			id := d.u16()
+			b.mu.Lock()
+			for len(d.b) > 0 && d.err == nil {
+				delete(s.filters, d.str())
+			}
+			b.mu.Unlock()
+			s.write(packet{typ: typeUnsuback, body: appendUint16(nil, id)})
+		case typePingreq:
+			s.write(packet{typ: typePingresp})
+		case typeDisconnect:
+			will = nil
+			return
+		}
+	}
+}
+
+// parseConnect decodes a CONNECT, returning its will, keep alive and the
+// CONNACK return code.
+func parseConnect(p packet) (*Message, time.Duration, byte) {
+	d := decoder{b: p.body}
+	if d.str() != "MQTT" || d.u8() != 4 {
+		return nil, 0, 1
+	}
+	flags := d.u8()
+	keepAlive := time.Duration(d.u16()) * time.Second
+	id := d.str()
+	if id == "" && flags&0x02 == 0 {
+		return nil, 0, 2
+	}
+	var will *Message
+	if flags&0x04 != 0 {
+		will = &Message{
+			Topic:   d.str(),
+			Payload: d.bin(),
+			QoS:     flags >> 3 & 3,
+			Retain:  flags&0x20 != 0,
+		}
+	}
+	// The user name and password aren't checked.
+	if d.err != nil {
+		return nil, 0, 2
+	}
+	return will, keepAlive, 0
+}
+
+// publish stores m if it's retained and forwards it to subscribers.
+func (b *Broker) publish(m Message) {
+	m.Payload = append([]byte(nil), m.Payload...)
+	b.mu.Lock()
+	if m.Retain {
+		if b.retained == nil {
+			b.retained = make(map[string]Message)
+		}
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+	var to []*session
+	for s := range b.sessions {
+		for f := range s.filters {
+			if match(f, m.Topic) {
+				to = append(to, s)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	// Forwarded messages don't carry the retain flag.
+	m.Retain, m.QoS = false, 0
+	p := publishPacket(m, 0)
+	for _, s := range to {
+		s.write(p)
+	}
+}
+
+// validFilter reports whether f is a valid topic filter, with # only as the
+// last level and wildcards only as whole levels.
+func validFilter(f string) bool {
+	if f == "" {
+		return false
+	}
+	levels := strings.Split(f, "/")
+	for i, l := range levels {
+		if strings.ContainsAny(l, "+#") && len(l) > 1 {
+			return false
+		}
+		if l == "#" && i != len(levels)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether topic matches filter. Wildcards at the first level
+// don't match topics beginning with $.
+func match(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	fs, ts := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) {
+			return false
+		}
+		if f != "+" && f != ts[i] {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
@@ -0,0 +1,145 @@
+package mqtt
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// startBroker serves a Broker on a local port, returning its address.
+func startBroker(t *testing.T) (b *Broker, addr string, teardown func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = new(Broker)
+	go b.Serve(l)
+	return b, l.Addr().String(), func() { b.Close() }
+}
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"#", "$SYS/uptime", false},
+		{"+/uptime", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+		{"a/b/c", "a/b", false},
+	}
+	for _, tc := range testCases {
+		if got := match(tc.filter, tc.topic); got != tc.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tc.filter, tc.topic, got, tc.want)
+		}
+	}
+}
+
+func TestValidFilter(t *testing.T) {
+	for f, want := range map[string]bool{
+		"a/b":   true,
+		"a/+/c": true,
+		"a/#":   true,
+		"":      false,
+		"a/#/c": false,
+		"a/b+":  false,
+		"a#":    false,
+	} {
+		if got := validFilter(f); got != want {
+			t.Errorf("validFilter(%q) = %v, want %v", f, got, want)
+		}
+	}
+}
+
+func TestBroker(t *testing.T) {
+	b, addr, teardown := startBroker(t)
+	defer teardown()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got := make(chan Message, 10)
+	sub, err := Dial(ctx, addr, Options{
+		ClientID:  "sub",
+		OnMessage: func(m Message) { got <- m },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	pub, err := Dial(ctx, "tcp://"+addr, Options{ClientID: "pub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	// A retained message is kept and sent to later subscribers.
+	if err := pub.Publish(ctx, Message{Topic: "a/retained", Payload: []byte("1"), QoS: 1, Retain: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Subscribe(ctx, "a/#"); err != nil {
+		t.Fatal(err)
+	}
+	want := Message{Topic: "a/retained", Payload: []byte("1"), Retain: true}
+	if diff := cmp.Diff(want, <-got); diff != "" {
+		t.Errorf("retained message mismatch (-want +got):\n%s", diff)
+	}
+
+	// Live messages are forwarded without the retain flag.
+	pub.Publish(ctx, Message{Topic: "b/ignored", Payload: []byte("x")})
+	pub.Publish(ctx, Message{Topic: "a/live", Payload: []byte("2")})
+	want = Message{Topic: "a/live", Payload: []byte("2")}
+	if diff := cmp.Diff(want, <-got); diff != "" {
+		t.Errorf("live message mismatch (-want +got):\n%s", diff)
+	}
+
+	// An empty retained message clears the topic.
+	if err := pub.Publish(ctx, Message{Topic: "a/retained", QoS: 1, Retain: true}); err != nil {
+		t.Fatal(err)
+	}
+	if r := b.Retained("#"); len(r) != 0 {
+		t.Errorf("got retained messages %v, want none", r)
+	}
+}
+
+func TestBroker_will(t *testing.T) {
+	b, addr, teardown := startBroker(t)
+	defer teardown()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	will := &Message{Topic: "status", Payload: []byte("offline"), Retain: true}
+	closed, err := Dial(ctx, addr, Options{Will: will})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost, err := Dial(ctx, addr, Options{Will: will})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing cleanly doesn't publish the will.
+	closed.Close()
+	time.Sleep(50 * time.Millisecond)
+	if r := b.Retained("status"); len(r) != 0 {
+		t.Fatalf("clean close published the will")
+	}
+
+	// Losing the connection does.
+	lost.conn.Close()
+	for i := 0; i < 100 && len(b.Retained("status")) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := string(b.Retained("status")["status"].Payload); got != "offline" {
+		t.Errorf("got will %q, want offline", got)
+	}
+}
@@ -0,0 +1,379 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeepAlive is how often a Conn pings the broker unless told
+// otherwise.
+const DefaultKeepAlive = time.Minute
+
+// ErrClosed is returned when using a Conn that has been closed.
+var ErrClosed = errors.New("mqtt: connection closed")
+
+// Options configures a connection.
+type Options struct {
+	// ClientID identifies the client to the broker. If empty the broker
+	// assigns one.
+	ClientID string
+	Username string
+	Password string
+	// KeepAlive is how often to ping the broker. It defaults to
+	// DefaultKeepAlive.
+	KeepAlive time.Duration
+	// Will is published by the broker if the connection is lost without
+	// calling Close.
+	Will *Message
+	// OnMessage is called with each message received for a subscription.
+	// It's called from the goroutine reading the connection, so it mustn't
+	// block.
+	OnMessage func(Message)
+	// TLSConfig is used for mqtts:// and ssl:// addresses.
+	TLSConfig *tls.Config
+}
+
+// ConnectError is returned by Dial when the broker refuses the connection.
+type ConnectError struct {
+	Code byte
+}
+
+var _ error = ConnectError{}
+
+func (e ConnectError) Error() string {
+	reasons := map[byte]string{
+		1: "unacceptable protocol version",
+		2: "identifier rejected",
+		3: "server unavailable",
+		4: "bad user name or password",
+		5: "not authorized",
+	}
+	if r, ok := reasons[e.Code]; ok {
+		return "mqtt: connection refused: " + r
+	}
+	return fmt.Sprintf("mqtt: connection refused: code %d", e.Code)
+}
+
+// Conn is a connection to an MQTT 3.1.1 broker. It's safe for concurrent
+// use.
+type Conn struct {
+	conn      net.Conn
+	opts      Options
+	keepAlive time.Duration
+
+	wmu sync.Mutex // serialises writes
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan struct{}
+	err     error // why the connection ended
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial connects to the broker at addr, which is a host and port optionally
+// preceded by tcp://, mqtt://, ssl:// or mqtts://. The port defaults to
+// 1883, or 8883 for TLS.
+func Dial(ctx context.Context, addr string, opts Options) (*Conn, error) {
+	useTLS := false
+	if i := strings.Index(addr, "://"); i >= 0 {
+		switch addr[:i] {
+		case "tcp", "mqtt":
+		case "ssl", "tls", "mqtts":
+			useTLS = true
+		default:
+			return nil, fmt.Errorf("mqtt: unknown scheme in %q", addr)
+		}
+		addr = addr[i+3:]
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		port := "1883"
+		if useTLS {
+			port = "8883"
+		}
+		addr = net.JoinHostPort(addr, port)
+	}
+
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if useTLS {
+		cfg := opts.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		nc = tls.Client(nc, cfg)
+	}
+	c, err := NewConn(ctx, nc, opts)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewConn connects over an existing connection, such as one end of a
+// net.Pipe to a Broker.
+func NewConn(ctx context.Context, nc net.Conn, opts Options) (*Conn, error) {
+	c := &Conn{
+		conn:      nc,
+		opts:      opts,
+		keepAlive: opts.KeepAlive,
+		pending:   make(map[uint16]chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if c.keepAlive <= 0 {
+		c.keepAlive = DefaultKeepAlive
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(nc)
+	if err := c.write(c.connectPacket()); err != nil {
+		return nil, err
+	}
+	p, err := readPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	if p.typ != typeConnack || len(p.body) != 2 {
+		return nil, errors.New("mqtt: expected CONNACK")
+	}
+	if p.body[1] != 0 {
+		return nil, ConnectError{Code: p.body[1]}
+	}
+	nc.SetDeadline(time.Time{})
+
+	go c.read(r)
+	go c.ping()
+	return c, nil
+}
+
+func (c *Conn) connectPacket() packet {
+	var flags byte = 0x02 // clean session
+	if w := c.opts.Will; w != nil {
+		flags |= 0x04 | w.QoS<<3
+		if w.Retain {
+			flags |= 0x20
+		}
+	}
+	if c.opts.Username != "" {
+		flags |= 0x80
+	}
+	if c.opts.Password != "" {
+		flags |= 0x40
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = appendUint16(body, uint16(c.keepAlive/time.Second))
+	body = appendString(body, c.opts.ClientID)
+	if w := c.opts.Will; w != nil {
+		body = appendString(body, w.Topic)
+		body = appendString(body, string(w.Payload))
+	}
+	if c.opts.Username != "" {
+		body = appendString(body, c.opts.Username)
+	}
+	if c.opts.Password != "" {
+		body = appendString(body, c.opts.Password)
+	}
+	return packet{typ: typeConnect, body: body}
+}
+
+func (c *Conn) write(p packet) error {
+	b, err := p.encode()
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err = c.conn.Write(b)
+	return err
+}
+
+// read handles packets from the broker until the connection ends.
+func (c *Conn) read(r *bufio.Reader) {
+	for {
+		// The broker answers our pings, so nothing for one and a half
+		// keep alive periods means the connection is dead.
+		c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		p, err := readPacket(r)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+		switch p.typ {
+		case typePuback, typeSuback, typeUnsuback:
+			d := decoder{b: p.body}
+			id := d.u16()
+			c.mu.Lock()
+			if ch, ok := c.pending[id]; ok {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+		case typePublish:
+			m, id, err := parsePublish(p)
+			if err != nil {
+				c.shutdown(err)
+				return
+			}
+			if m.QoS == 1 {
+				c.write(packet{typ: typePuback, body: appendUint16(nil, id)})
+			}
+			if c.opts.OnMessage != nil {
+				c.opts.OnMessage(m)
+			}
+		}
+	}
+}
+
+func (c *Conn) ping() {
+	t := time.NewTicker(c.keepAlive)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := c.write(packet{typ: typePingreq}); err != nil {
+				c.shutdown(err)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// shutdown closes the connection, recording err as the reason unless one
+// has already been recorded.
+func (c *Conn) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = err
+		}
+		c.mu.Unlock()
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Err returns why the connection ended, or nil if it's still open.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Done is closed when the connection ends.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close disconnects from the broker. The will isn't published.
+func (c *Conn) Close() error {
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	// Record why first, as the broker may drop the connection as soon as
+	// it reads the DISCONNECT.
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrClosed
+	}
+	c.mu.Unlock()
+	err := c.write(packet{typ: typeDisconnect})
+	c.shutdown(ErrClosed)
+	return err
+}
+
+// await sends p, which uses packet id, and waits for its acknowledgement.
+func (c *Conn) await(ctx context.Context, id uint16, ch chan struct{}, p packet) error {
+	if err := c.write(p); err != nil {
+		c.shutdown(err)
+		return err
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// newID returns an unused packet id and the channel closed when it's
+// acknowledged.
+func (c *Conn) newID() (uint16, chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		if _, ok := c.pending[c.nextID]; !ok {
+			break
+		}
+	}
+	ch := make(chan struct{})
+	c.pending[c.nextID] = ch
+	return c.nextID, ch
+}
+
+// Publish sends a message. With QoS 1 it waits for the broker to
+// acknowledge it.
+func (c *Conn) Publish(ctx context.Context, m Message) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	switch m.QoS {
+	case 0:
+		err := c.write(publishPacket(m, 0))
+		if err != nil {
+			c.shutdown(err)
+		}
+		return err
+	case 1:
+		id, ch := c.newID()
+		return c.await(ctx, id, ch, publishPacket(m, id))
+	}
+	return errors.New("mqtt: QoS 2 is not supported")
+}
+
+// Subscribe asks the broker for messages on topics matching filters, which
+// may use the + and # wildcards. Messages are delivered at QoS 0 to
+// Options.OnMessage.
+func (c *Conn) Subscribe(ctx context.Context, filters ...string) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	id, ch := c.newID()
+	body := appendUint16(nil, id)
+	for _, f := range filters {
+		body = appendString(body, f)
+		body = append(body, 0)
+	}
+	return c.await(ctx, id, ch, packet{typ: typeSubscribe, flags: 0x02, body: body})
+}
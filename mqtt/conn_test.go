@@ -0,0 +1,85 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDial_refused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		readPacket(bufio.NewReader(c))
+		b, _ := packet{typ: typeConnack, body: []byte{0, 4}}.encode()
+		c.Write(b)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = Dial(ctx, l.Addr().String(), Options{Username: "u", Password: "wrong"})
+	if err != (ConnectError{Code: 4}) {
+		t.Errorf("got %v, want %v", err, ConnectError{Code: 4})
+	}
+}
+
+func TestConn_connectPacket(t *testing.T) {
+	c := &Conn{
+		keepAlive: 30 * time.Second,
+		opts: Options{
+			ClientID: "id",
+			Username: "u",
+			Password: "p",
+			Will:     &Message{Topic: "w", Payload: []byte("x"), QoS: 1, Retain: true},
+		},
+	}
+	p := c.connectPacket()
+	will, keepAlive, code := parseConnect(p)
+	if code != 0 || keepAlive != 30*time.Second {
+		t.Fatalf("got code %d and keep alive %v, want 0 and 30s", code, keepAlive)
+	}
+	if will == nil || will.Topic != "w" || string(will.Payload) != "x" || will.QoS != 1 || !will.Retain {
+		t.Errorf("will parsed as %+v", will)
+	}
+	if flags := p.body[7]; flags != 0x80|0x40|0x20|0x08|0x04|0x02 {
+		t.Errorf("got connect flags %#x", flags)
+	}
+}
+
+func TestConn_pipe(t *testing.T) {
+	var b Broker
+	client, server := net.Pipe()
+	go b.ServeConn(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := NewConn(ctx, client, Options{ClientID: "pipe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(ctx, Message{Topic: "t", Payload: []byte("v"), QoS: 1, Retain: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b.Retained("t")["t"].Payload); got != "v" {
+		t.Errorf("got %q, want v", got)
+	}
+	if err := c.Publish(ctx, Message{Topic: "t", QoS: 2}); err == nil {
+		t.Error("QoS 2 publish succeeded")
+	}
+
+	c.Close()
+	<-c.Done()
+	if err := c.Publish(ctx, Message{Topic: "t"}); err != ErrClosed {
+		t.Errorf("publish after close returned %v, want %v", err, ErrClosed)
+	}
+}
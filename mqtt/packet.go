@@ -0,0 +1,185 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Control packet types, see section 2.2.1 of the MQTT 3.1.1 spec.
+const (
+	typeConnect     = 1
+	typeConnack     = 2
+	typePublish     = 3
+	typePuback      = 4
+	typeSubscribe   = 8
+	typeSuback      = 9
+	typeUnsubscribe = 10
+	typeUnsuback    = 11
+	typePingreq     = 12
+	typePingresp    = 13
+	typeDisconnect  = 14
+)
+
+// maxRemaining is the largest remaining length that can be encoded.
+const maxRemaining = 268435455
+
+var errMalformed = errors.New("mqtt: malformed packet")
+
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+// readPacket reads a single control packet.
+func readPacket(r *bufio.Reader) (packet, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	var n, shift uint
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		n |= uint(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return packet{}, errMalformed
+		}
+		shift += 7
+	}
+	p := packet{typ: h >> 4, flags: h & 0x0f, body: make([]byte, n)}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return packet{}, err
+	}
+	return p, nil
+}
+
+// encode returns the packet with its fixed header.
+func (p packet) encode() ([]byte, error) {
+	n := len(p.body)
+	if n > maxRemaining {
+		return nil, errors.New("mqtt: packet too large")
+	}
+	b := make([]byte, 0, n+5)
+	b = append(b, p.typ<<4|p.flags)
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, p.body...), nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// decoder reads the fields of a packet body. The first error is kept and
+// later reads return zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) u8() byte {
+	if d.err != nil || len(d.b) < 1 {
+		d.err = errMalformed
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *decoder) u16() uint16 {
+	if d.err != nil || len(d.b) < 2 {
+		d.err = errMalformed
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.b)
+	d.b = d.b[2:]
+	return v
+}
+
+func (d *decoder) bin() []byte {
+	n := int(d.u16())
+	if d.err != nil || len(d.b) < n {
+		d.err = errMalformed
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) str() string {
+	return string(d.bin())
+}
+
+// rest returns the unread bytes.
+func (d *decoder) rest() []byte {
+	v := d.b
+	d.b = nil
+	return v
+}
+
+// Message is an application message.
+type Message struct {
+	Topic   string
+	Payload []byte
+	// QoS is 0 (at most once) or 1 (at least once). QoS 2 isn't supported.
+	QoS    byte
+	Retain bool
+}
+
+// publishPacket encodes m as a PUBLISH with packet id, which is only used
+// for QoS 1.
+func publishPacket(m Message, id uint16) packet {
+	flags := m.QoS << 1
+	if m.Retain {
+		flags |= 1
+	}
+	body := appendString(nil, m.Topic)
+	if m.QoS > 0 {
+		body = appendUint16(body, id)
+	}
+	body = append(body, m.Payload...)
+	return packet{typ: typePublish, flags: flags, body: body}
+}
+
+// parsePublish decodes a PUBLISH, returning the message and its packet id.
+func parsePublish(p packet) (Message, uint16, error) {
+	m := Message{QoS: p.flags >> 1 & 3, Retain: p.flags&1 == 1}
+	if m.QoS > 2 {
+		return m, 0, errMalformed
+	}
+	d := decoder{b: p.body}
+	m.Topic = d.str()
+	var id uint16
+	if m.QoS > 0 {
+		id = d.u16()
+	}
+	m.Payload = d.rest()
+	return m, id, d.err
+}
@@ -0,0 +1,71 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPacket_roundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 127, 128, 16383, 16384, 2097152} {
+		p := packet{typ: typePublish, flags: 3, body: bytes.Repeat([]byte{'x'}, n)}
+		b, err := p.encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := readPacket(bufio.NewReader(bytes.NewReader(b)))
+		if err != nil {
+			t.Fatalf("body of %d bytes: %v", n, err)
+		}
+		if got.typ != p.typ || got.flags != p.flags || !bytes.Equal(got.body, p.body) {
+			t.Errorf("body of %d bytes didn't round trip", n)
+		}
+	}
+}
+
+func TestPacket_remainingLength(t *testing.T) {
+	testCases := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+	}
+	for _, tc := range testCases {
+		b, _ := packet{typ: typePingreq, body: make([]byte, tc.n)}.encode()
+		if got := b[1 : 1+len(tc.want)]; !bytes.Equal(got, tc.want) {
+			t.Errorf("length %d encoded as % x, want % x", tc.n, got, tc.want)
+		}
+	}
+
+	// A fifth length byte is malformed.
+	r := bufio.NewReader(bytes.NewReader([]byte{0xc0, 0x80, 0x80, 0x80, 0x80, 0x01}))
+	if _, err := readPacket(r); err != errMalformed {
+		t.Errorf("got %v, want %v", err, errMalformed)
+	}
+}
+
+func TestPublishPacket(t *testing.T) {
+	m := Message{Topic: "a/b", Payload: []byte("hi"), QoS: 1, Retain: true}
+	p := publishPacket(m, 7)
+	want := []byte{0, 3, 'a', '/', 'b', 0, 7, 'h', 'i'}
+	if p.flags != 0x03 || !bytes.Equal(p.body, want) {
+		t.Errorf("got flags %#x body % x, want 0x3 and % x", p.flags, p.body, want)
+	}
+	got, id, err := parsePublish(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(m, got); diff != "" || id != 7 {
+		t.Errorf("parsed id %d, message mismatch (-want +got):\n%s", id, diff)
+	}
+
+	if _, _, err := parsePublish(packet{typ: typePublish, body: []byte{0, 9, 'a'}}); err != errMalformed {
+		t.Errorf("short topic returned %v, want %v", err, errMalformed)
+	}
+}
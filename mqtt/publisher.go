@@ -0,0 +1,413 @@
+// mqtt publishes metservice observations, forecasts and pollen levels to an
+// MQTT broker for home automation, with Home Assistant discovery. It
+// includes a small MQTT 3.1.1 client, Conn, and an in-process Broker.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Defaults used by New.
+const (
+	DefaultInterval        = 5 * time.Minute
+	DefaultPrefix          = "metservice"
+	DefaultDiscoveryPrefix = "homeassistant"
+)
+
+// Sensor describes a value published for each location.
+type Sensor struct {
+	// Key is the last level of the state topic, such as temp in
+	// metservice/Dunedin/temp.
+	Key  string
+	Name string
+	// Unit, DeviceClass and StateClass are passed to Home Assistant and
+	// may be empty.
+	Unit        string
+	DeviceClass string
+	StateClass  string
+	// Endpoint is where the value comes from.
+	Endpoint metservice.Endpoint
+}
+
+// Sensors are the values published for each location, if they're known.
+var Sensors = []Sensor{
+	{"temp", "Temperature", "°C", "temperature", "measurement", metservice.EndpointObservation},
+	{"wind_chill", "Wind chill", "°C", "temperature", "measurement", metservice.EndpointObservation},
+	{"humidity", "Humidity", "%", "humidity", "measurement", metservice.EndpointObservation},
+	{"wind_speed", "Wind speed", "km/h", "wind_speed", "measurement", metservice.EndpointObservation},
+	{"wind_direction", "Wind direction", "", "", "", metservice.EndpointObservation},
+	{"rainfall", "Rainfall (3 hours)", "mm", "precipitation", "measurement", metservice.EndpointObservation},
+	{"rainfall_24h", "Rainfall (24 hours)", "mm", "precipitation", "measurement", metservice.EndpointObservation},
+	{"pressure", "Pressure trend", "", "", "", metservice.EndpointObservation},
+	{"observed_at", "Observed at", "", "timestamp", "", metservice.EndpointObservation},
+	{"forecast", "Forecast", "", "", "", metservice.EndpointForecast},
+	{"max", "Forecast maximum", "°C", "temperature", "", metservice.EndpointForecast},
+	{"min", "Forecast minimum", "°C", "temperature", "", metservice.EndpointForecast},
+	{"pollen_type", "Pollen type", "", "", "", metservice.EndpointPollen},
+	{"pollen_level", "Pollen level", "", "", "measurement", metservice.EndpointPollen},
+}
+
+// Publisher polls locations and publishes each of Sensors as a retained
+// message on its own topic, such as metservice/Dunedin/temp. When a value
+// stops being reported its retained message is cleared, but values are kept
+// while their endpoint is failing. It also publishes Home Assistant MQTT
+// discovery configs so the values show up as sensors without any
+// configuration.
+type Publisher struct {
+	Client *metservice.Client
+	Conn   *Conn
+	// Locations are polled every Interval by Run.
+	Locations []string
+	Interval  time.Duration
+	// Prefix starts each topic. The Publisher's availability is published
+	// to Prefix/status.
+	Prefix string
+	// DiscoveryPrefix is where Home Assistant looks for discovery configs.
+	// Empty disables discovery.
+	DiscoveryPrefix string
+	// QoS is used for every message.
+	QoS byte
+	// OnError, if set, is called with each failed fetch as a *FetchError.
+	OnError func(error)
+
+	mu sync.Mutex
+	// retained holds the state topics with a retained value.
+	retained map[string]bool
+}
+
+// FetchError is a failure to fetch an endpoint for a location.
+type FetchError struct {
+	Location string
+	Endpoint metservice.Endpoint
+	Err      error
+}
+
+var _ error = &FetchError{}
+
+func (e *FetchError) Error() string {
+	return e.Location + " " + string(e.Endpoint) + ": " + e.Err.Error()
+}
+
+// New returns a Publisher for locations that polls every DefaultInterval
+// and uses the default prefixes.
+func New(client *metservice.Client, conn *Conn, locations ...string) *Publisher {
+	return &Publisher{
+		Client:          client,
+		Conn:            conn,
+		Locations:       locations,
+		Interval:        DefaultInterval,
+		Prefix:          DefaultPrefix,
+		DiscoveryPrefix: DefaultDiscoveryPrefix,
+	}
+}
+
+// StatusTopic is the topic the Publisher's availability is published to,
+// as online or offline. Use it for the Options.Will, with the payload
+// offline, so Home Assistant knows when the Publisher has gone away.
+func (p *Publisher) StatusTopic() string {
+	return p.Prefix + "/status"
+}
+
+// Topic returns the state topic for a sensor key at location.
+func (p *Publisher) Topic(location, key string) string {
+	return p.Prefix + "/" + location + "/" + key
+}
+
+// CheckLocation returns an error if location can't be used as a topic
+// level: if it's empty or contains the wildcards + or #, or the level
+// separator /.
+func CheckLocation(location string) error {
+	if location == "" || strings.ContainsAny(location, "+#/") {
+		return fmt.Errorf("mqtt: location %q can't be used in a topic", location)
+	}
+	return nil
+}
+
+func (p *Publisher) checkLocations() error {
+	for _, loc := range p.Locations {
+		if err := CheckLocation(loc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run publishes the discovery configs and online status, then calls
+// Publish straight away and every Interval until ctx is cancelled or
+// publishing fails. Offline is published before returning.
+func (p *Publisher) Run(ctx context.Context) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if err := p.checkLocations(); err != nil {
+		return err
+	}
+	if err := p.PublishDiscovery(ctx); err != nil {
+		return err
+	}
+	if err := p.publish(ctx, p.StatusTopic(), "online"); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		err := p.Publish(ctx)
+		if err == nil {
+			select {
+			case <-t.C:
+				continue
+			case <-p.Conn.Done():
+				return p.Conn.Err()
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() == nil {
+			return err
+		}
+		stop, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		p.publish(stop, p.StatusTopic(), "offline")
+		return ctx.Err()
+	}
+}
+
+func (p *Publisher) publish(ctx context.Context, topic, payload string) error {
+	return p.Conn.Publish(ctx, Message{
+		Topic:   topic,
+		Payload: []byte(payload),
+		QoS:     p.QoS,
+		Retain:  true,
+	})
+}
+
+// Publish fetches every location once, concurrently, and publishes the
+// values that are known, clearing those no longer reported. Failed fetches
+// are passed to OnError; only a failure to publish or a location that
+// fails CheckLocation is returned.
+func (p *Publisher) Publish(ctx context.Context) error {
+	if err := p.checkLocations(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.retained == nil {
+		p.retained = make(map[string]bool)
+	}
+
+	states := make([]map[string]string, len(p.Locations))
+	var wg sync.WaitGroup
+	for i, loc := range p.Locations {
+		wg.Add(1)
+		go func(i int, loc string) {
+			defer wg.Done()
+			states[i] = p.fetch(ctx, loc)
+		}(i, loc)
+	}
+	wg.Wait()
+
+	for i, loc := range p.Locations {
+		for _, s := range Sensors {
+			v, ok := states[i][s.Key]
+			topic := p.Topic(loc, s.Key)
+			// An empty value clears the topic, which is only needed if
+			// it was published before.
+			if !ok || v == "" && !p.retained[topic] {
+				continue
+			}
+			if err := p.publish(ctx, topic, v); err != nil {
+				return err
+			}
+			p.retained[topic] = v != ""
+		}
+	}
+	return nil
+}
+
+// fetch gets the values for a location, keyed by Sensor.Key. The values of
+// an endpoint that was fetched but didn't report them are empty, and those
+// of an endpoint that failed are missing.
+func (p *Publisher) fetch(ctx context.Context, location string) map[string]string {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		values = make(map[string]string)
+	)
+	set := func(key, v string) {
+		mu.Lock()
+		values[key] = v
+		mu.Unlock()
+	}
+	setInt := func(key string, v *int) {
+		if v != nil {
+			set(key, strconv.Itoa(*v))
+		}
+	}
+	setFloat := func(key string, v *float64) {
+		if v != nil {
+			set(key, strconv.FormatFloat(*v, 'f', -1, 64))
+		}
+	}
+	setString := func(key string, v *string) {
+		if v != nil && *v != "" {
+			set(key, *v)
+		}
+	}
+	fail := func(endpoint metservice.Endpoint, err error) {
+		if p.OnError != nil {
+			p.OnError(&FetchError{Location: location, Endpoint: endpoint, Err: err})
+		}
+	}
+	fetched := func(endpoint metservice.Endpoint) {
+		for _, s := range Sensors {
+			if s.Endpoint == endpoint {
+				set(s.Key, "")
+			}
+		}
+	}
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		o, _, err := p.Client.GetObservation(ctx, location)
+		if err != nil {
+			fail(metservice.EndpointObservation, err)
+			return
+		}
+		fetched(metservice.EndpointObservation)
+		if h := o.ThreeHour; h != nil {
+			setInt("temp", h.Temp)
+			setInt("wind_chill", h.WindChill)
+			setInt("humidity", h.Humidity)
+			setInt("wind_speed", h.WindSpeed)
+			setString("wind_direction", h.WindDirection)
+			setFloat("rainfall", h.Rainfall)
+			setString("pressure", h.Pressure)
+			if h.Date != nil {
+				set("observed_at", h.Date.Format(time.RFC3339))
+			}
+		}
+		if d := o.TwentyFourHour; d != nil {
+			setFloat("rainfall_24h", d.Rainfall)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		f, _, err := p.Client.GetForecast(ctx, location)
+		if err != nil {
+			fail(metservice.EndpointForecast, err)
+			return
+		}
+		fetched(metservice.EndpointForecast)
+		if len(f.Days) > 0 {
+			d := f.Days[0]
+			setString("forecast", d.ForecastWord)
+			setInt("max", d.Max)
+			setInt("min", d.Min)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		pollen, _, err := p.Client.GetPollen(ctx, location)
+		if err != nil {
+			fail(metservice.EndpointPollen, err)
+			return
+		}
+		fetched(metservice.EndpointPollen)
+		if len(pollen.PollenDays) > 0 {
+			d := pollen.PollenDays[0]
+			setString("pollen_type", d.Type)
+			if d.Level != nil {
+				if n, ok := metservice.PollenLevel(*d.Level); ok {
+					set("pollen_level", strconv.Itoa(n))
+				}
+			}
+		}
+	}()
+	wg.Wait()
+	return values
+}
+
+// discovery is a Home Assistant MQTT discovery config for a sensor, see
+// https://www.home-assistant.io/integrations/sensor.mqtt/.
+type discovery struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	ObjectID          string          `json:"object_id"`
+	StateTopic        string          `json:"state_topic"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	Unit              string          `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string          `json:"device_class,omitempty"`
+	StateClass        string          `json:"state_class,omitempty"`
+	Device            discoveryDevice `json:"device"`
+}
+
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+}
+
+// PublishDiscovery publishes a retained Home Assistant discovery config for
+// every sensor of every location, grouped into a device per location. It
+// does nothing if DiscoveryPrefix is empty.
+func (p *Publisher) PublishDiscovery(ctx context.Context) error {
+	if p.DiscoveryPrefix == "" {
+		return nil
+	}
+	if err := p.checkLocations(); err != nil {
+		return err
+	}
+	for _, loc := range p.Locations {
+		node := "metservice_" + slug(loc)
+		for _, s := range Sensors {
+			b, err := json.Marshal(discovery{
+				Name:              s.Name,
+				UniqueID:          node + "_" + s.Key,
+				ObjectID:          node + "_" + s.Key,
+				StateTopic:        p.Topic(loc, s.Key),
+				AvailabilityTopic: p.StatusTopic(),
+				Unit:              s.Unit,
+				DeviceClass:       s.DeviceClass,
+				StateClass:        s.StateClass,
+				Device: discoveryDevice{
+					Identifiers:  []string{node},
+					Name:         "Metservice " + loc,
+					Manufacturer: "MetService",
+				},
+			})
+			if err != nil {
+				return err
+			}
+			topic := p.DiscoveryPrefix + "/sensor/" + node + "/" + s.Key + "/config"
+			if err := p.publish(ctx, topic, string(b)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// slug makes a location safe for a discovery topic and entity id, so
+// "Palmerston North" becomes palmerston_north.
+func slug(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, s)
+}
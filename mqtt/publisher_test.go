@@ -0,0 +1,227 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func setup(t *testing.T) (p *Publisher, b *Broker, mux *http.ServeMux, teardown func()) {
+	b, addr, stopBroker := startBroker(t)
+	mux = http.NewServeMux()
+	server := httptest.NewServer(mux)
+	client := metservice.NewClient()
+	client.BaseURL = server.URL + "/"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, addr, Options{ClientID: "publisher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = New(client, conn, "Dunedin")
+	// With QoS 1 each publish waits until the broker has stored it.
+	p.QoS = 1
+	return p, b, mux, func() {
+		conn.Close()
+		server.Close()
+		stopBroker()
+	}
+}
+
+// retained returns the payloads of the retained messages matching filter.
+func retained(b *Broker, filter string) map[string]string {
+	out := make(map[string]string)
+	for t, m := range b.Retained(filter) {
+		out[t] = string(m.Payload)
+	}
+	return out
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	p, b, mux, teardown := setup(t)
+	defer teardown()
+
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"threeHour": {"dateTimeISO": "2006-01-02T15:00:00+13:00", "temp": "11", "humidity": "70",
+			"windDirection": "SW", "rainfall": "0.4"}, "twentyFourHour": {"rainfall": "5.5"}}`)
+	})
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"days": [{"forecastWord": "Showers", "max": "14", "min": "5"}]}`)
+	})
+	var (
+		mu   sync.Mutex
+		errs []string
+	)
+	p.OnError = func(err error) {
+		mu.Lock()
+		errs = append(errs, err.Error())
+		mu.Unlock()
+	}
+
+	if err := p.Publish(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"metservice/Dunedin/temp":           "11",
+		"metservice/Dunedin/humidity":       "70",
+		"metservice/Dunedin/wind_direction": "SW",
+		"metservice/Dunedin/rainfall":       "0.4",
+		"metservice/Dunedin/rainfall_24h":   "5.5",
+		"metservice/Dunedin/observed_at":    "2006-01-02T15:00:00+13:00",
+		"metservice/Dunedin/forecast":       "Showers",
+		"metservice/Dunedin/max":            "14",
+		"metservice/Dunedin/min":            "5",
+	}
+	if diff := cmp.Diff(want, retained(b, "metservice/#")); diff != "" {
+		t.Errorf("topics mismatch (-want +got):\n%s", diff)
+	}
+	wantErrs := []string{"Dunedin pollen: " + metservice.StatusError{Code: 404}.Error()}
+	if diff := cmp.Diff(wantErrs, errs); diff != "" {
+		t.Errorf("errors mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPublisher_Publish_clear(t *testing.T) {
+	p, b, mux, teardown := setup(t)
+	defer teardown()
+
+	var (
+		mu       sync.Mutex
+		forecast = `{"days": [{"forecastWord": "Showers", "max": "14", "min": "5"}]}`
+		obsOK    = true
+	)
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprint(w, forecast)
+	})
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !obsOK {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"threeHour": {"temp": "11"}}`)
+	})
+	if err := p.Publish(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The forecast stops reporting max and min, and the observation fails.
+	// The missing forecast values are cleared, but the last temperature is
+	// kept.
+	mu.Lock()
+	forecast = `{"days": [{"forecastWord": "Fine"}]}`
+	obsOK = false
+	mu.Unlock()
+	if err := p.Publish(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"metservice/Dunedin/temp":     "11",
+		"metservice/Dunedin/forecast": "Fine",
+	}
+	if diff := cmp.Diff(want, retained(b, "metservice/#")); diff != "" {
+		t.Errorf("topics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckLocation(t *testing.T) {
+	for loc, ok := range map[string]bool{
+		"Palmerston North": true,
+		"":                 false,
+		"Dunedin/Mosgiel":  false,
+		"Dunedin+":         false,
+		"#":                false,
+	} {
+		if err := CheckLocation(loc); (err == nil) != ok {
+			t.Errorf("CheckLocation(%q) = %v", loc, err)
+		}
+	}
+
+	p := New(nil, nil, "Dunedin", "a/b")
+	if err := p.Publish(context.Background()); err == nil {
+		t.Error("Publish with a bad location succeeded")
+	}
+}
+
+func TestPublisher_PublishDiscovery(t *testing.T) {
+	p, b, _, teardown := setup(t)
+	defer teardown()
+	p.Locations = []string{"Palmerston North"}
+
+	if err := p.PublishDiscovery(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	configs := retained(b, "homeassistant/sensor/+/+/config")
+	if len(configs) != len(Sensors) {
+		t.Errorf("got %d configs, want %d", len(configs), len(Sensors))
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(configs["homeassistant/sensor/metservice_palmerston_north/temp/config"]), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":                "Temperature",
+		"unique_id":           "metservice_palmerston_north_temp",
+		"object_id":           "metservice_palmerston_north_temp",
+		"state_topic":         "metservice/Palmerston North/temp",
+		"availability_topic":  "metservice/status",
+		"unit_of_measurement": "°C",
+		"device_class":        "temperature",
+		"state_class":         "measurement",
+		"device": map[string]interface{}{
+			"identifiers":  []interface{}{"metservice_palmerston_north"},
+			"name":         "Metservice Palmerston North",
+			"manufacturer": "MetService",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("config mismatch (-want +got):\n%s", diff)
+	}
+
+	p.DiscoveryPrefix = ""
+	b.Close()
+	if err := p.PublishDiscovery(context.Background()); err != nil {
+		t.Errorf("disabled discovery returned %v", err)
+	}
+}
+
+func TestPublisher_Run(t *testing.T) {
+	p, b, mux, teardown := setup(t)
+	defer teardown()
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"days": [{"forecastWord": "Fine"}]}`)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+	for i := 0; i < 100 && retained(b, "metservice/Dunedin/forecast")["metservice/Dunedin/forecast"] == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := retained(b, "metservice/status")["metservice/status"]; got != "online" {
+		t.Errorf("got status %q while running, want online", got)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run returned %v, want %v", err, context.Canceled)
+	}
+	if got := retained(b, "metservice/status")["metservice/status"]; got != "offline" {
+		t.Errorf("got status %q after Run, want offline", got)
+	}
+	if got := retained(b, "metservice/Dunedin/forecast")["metservice/Dunedin/forecast"]; got != "Fine" {
+		t.Errorf("got forecast %q, want Fine", got)
+	}
+}
@@ -0,0 +1,254 @@
+// notify pushes alerts and polled changes out to webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/alert"
+)
+
+// Kinds of Message.
+const (
+	KindAlert  = "alert"
+	KindChange = "change"
+)
+
+// Message is the JSON body POSTed to a webhook.
+type Message struct {
+	Kind     string    `json:"kind"`
+	Location string    `json:"location"`
+	Time     time.Time `json:"time"`
+	// Text is the rendered text body, see Webhook.Template.
+	Text string `json:"text"`
+
+	Alert    *alert.Alert        `json:"alert,omitempty"`
+	Endpoint metservice.Endpoint `json:"endpoint,omitempty"`
+	Changes  []metservice.Change `json:"changes,omitempty"`
+}
+
+// AlertMessage returns a Message for an alert.
+func AlertMessage(a alert.Alert) Message {
+	return Message{
+		Kind:     KindAlert,
+		Location: a.Location,
+		Time:     a.Time,
+		Alert:    &a,
+	}
+}
+
+// EventMessage returns a Message for the changes in a Watcher event.
+func EventMessage(ev metservice.Event) Message {
+	return Message{
+		Kind:     KindChange,
+		Location: ev.Location,
+		Time:     ev.Time,
+		Endpoint: ev.Endpoint,
+		Changes:  ev.Changes,
+	}
+}
+
+// DefaultTemplate renders the text body when a Webhook has no Template.
+var DefaultTemplate = template.Must(template.New("text").Parse(
+	`{{if .Alert}}{{.Alert.Message}}{{else}}{{.Location}} {{.Endpoint}} changed:` +
+		`{{range .Changes}}
+  {{.}}{{end}}{{end}}`))
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body,
+// prefixed with "sha256=", when a Webhook has a Secret.
+const SignatureHeader = "X-Metservice-Signature"
+
+// Defaults used by a Webhook with the matching fields unset.
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+)
+
+// Webhook is a single URL messages are POSTed to.
+type Webhook struct {
+	URL string
+	// Secret, if set, is used to sign each request body.
+	Secret []byte
+	// Template renders Message.Text. It is executed with the Message.
+	Template *template.Template
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// MaxAttempts is the number of times a message is tried before giving
+	// up.
+	MaxAttempts int
+	// Backoff is the delay after the first failed attempt. It doubles after
+	// each further failure.
+	Backoff time.Duration
+	// DeadLetter is a file that messages are appended to, one JSON object
+	// per line, once every attempt has failed. Empty disables it. Webhooks
+	// may share a file.
+	DeadLetter string
+}
+
+// deadLetterMu guards writes to every DeadLetter file, so webhooks sharing
+// one don't interleave their lines.
+var deadLetterMu sync.Mutex
+
+// StatusError is returned when a webhook responds with a non 2xx status.
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+var _ error = StatusError{}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("webhook %s: bad response status code: %d", e.URL, e.Code)
+}
+
+// Send renders and POSTs m, retrying failed attempts with exponential
+// backoff. Client errors other than 429 are not retried. If every attempt
+// fails the message is written to DeadLetter and the last error returned.
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	tmpl := w.Template
+	if tmpl == nil {
+		tmpl = DefaultTemplate
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, m); err != nil {
+		return fmt.Errorf("failed to render template: %v", err)
+	}
+	m.Text = text.String()
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %v", err)
+	}
+
+	attempts := w.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return w.deadLetter(body, ctx.Err())
+			}
+			backoff *= 2
+		}
+		err = w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if e, ok := err.(StatusError); ok && e.Code < 500 && e.Code != http.StatusTooManyRequests {
+			break
+		}
+	}
+	return w.deadLetter(body, err)
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+
+	client := w.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to do request: %v", err)
+	}
+	defer rsp.Body.Close()
+	io.Copy(ioutil.Discard, rsp.Body)
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return StatusError{URL: w.URL, Code: rsp.StatusCode}
+	}
+	return nil
+}
+
+// deadLetter records body in the dead letter file and returns err.
+func (w *Webhook) deadLetter(body []byte, err error) error {
+	if w.DeadLetter == "" {
+		return err
+	}
+	line, _ := json.Marshal(struct {
+		URL     string          `json:"url"`
+		Error   string          `json:"error"`
+		Time    time.Time       `json:"time"`
+		Message json.RawMessage `json:"message"`
+	}{w.URL, err.Error(), time.Now(), body})
+
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	f, ferr := os.OpenFile(w.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if ferr != nil {
+		return fmt.Errorf("%v (and failed to open dead letter file: %v)", err, ferr)
+	}
+	defer f.Close()
+	if _, ferr := f.Write(append(line, '\n')); ferr != nil {
+		return fmt.Errorf("%v (and failed to write dead letter file: %v)", err, ferr)
+	}
+	return err
+}
+
+// Sign returns the signature sent in SignatureHeader for body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid SignatureHeader value for
+// body. It is meant for receivers.
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Notifier sends every message to a set of webhooks.
+type Notifier struct {
+	Webhooks []*Webhook
+}
+
+// Notify sends m to every webhook concurrently and returns the first error
+// encountered, if any.
+func (n *Notifier) Notify(ctx context.Context, m Message) error {
+	errs := make(chan error, len(n.Webhooks))
+	for _, w := range n.Webhooks {
+		go func(w *Webhook) {
+			errs <- w.Send(ctx, m)
+		}(w)
+	}
+	var first error
+	for range n.Webhooks {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
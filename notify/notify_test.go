@@ -0,0 +1,192 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/alert"
+)
+
+var referenceTime = time.Date(2006, time.January, 02, 15, 04, 05, 0, time.UTC)
+
+// receiver is a local webhook endpoint that fails the first fail requests.
+type receiver struct {
+	mu     sync.Mutex
+	fail   int
+	code   int
+	bodies [][]byte
+	sigs   []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail > 0 {
+		r.fail--
+		w.WriteHeader(r.code)
+		return
+	}
+	r.bodies = append(r.bodies, body)
+	r.sigs = append(r.sigs, req.Header.Get(SignatureHeader))
+}
+
+func TestWebhook_Send(t *testing.T) {
+	rcv := &receiver{fail: 2, code: http.StatusServiceUnavailable}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	secret := []byte("hunter2")
+	w := &Webhook{
+		URL:     server.URL,
+		Secret:  secret,
+		Backoff: time.Millisecond,
+	}
+	a := alert.Alert{
+		Rule:     "temp < 2",
+		Location: "Dunedin",
+		Time:     referenceTime,
+		Message:  "Dunedin: temp is 1, < 2",
+	}
+	if err := w.Send(context.Background(), AlertMessage(a)); err != nil {
+		t.Fatalf("Webhook.Send returned error: %v", err)
+	}
+
+	if len(rcv.bodies) != 1 {
+		t.Fatalf("receiver got %d messages, want 1", len(rcv.bodies))
+	}
+	if !Verify(secret, rcv.bodies[0], rcv.sigs[0]) {
+		t.Errorf("signature %q does not verify", rcv.sigs[0])
+	}
+	var got Message
+	if err := json.Unmarshal(rcv.bodies[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != KindAlert || got.Text != a.Message || got.Alert.Rule != a.Rule {
+		t.Errorf("receiver got %+v", got)
+	}
+}
+
+func TestWebhook_DeadLetter(t *testing.T) {
+	rcv := &receiver{fail: 100, code: http.StatusBadRequest}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dead := filepath.Join(dir, "dead.jsonl")
+
+	w := &Webhook{
+		URL:        server.URL,
+		Backoff:    time.Millisecond,
+		DeadLetter: dead,
+	}
+	ev := metservice.Event{
+		Endpoint: metservice.EndpointRiseSet,
+		Location: "Dunedin",
+		Changes:  []metservice.Change{{Path: "Location", Old: "a", New: "b"}},
+	}
+	err = w.Send(context.Background(), EventMessage(ev))
+	if e, ok := err.(StatusError); !ok || e.Code != http.StatusBadRequest {
+		t.Fatalf("Webhook.Send error = %v, want StatusError 400", err)
+	}
+	// A 400 is not retried.
+	if rcv.fail != 99 {
+		t.Errorf("webhook was tried %d times, want 1", 100-rcv.fail)
+	}
+
+	f, err := os.Open(dead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]interface{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(s.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("dead letter has %d lines, want 1", len(lines))
+	}
+	msg := lines[0]["message"].(map[string]interface{})
+	if want := "Dunedin riseset changed:\n  Location: a -> b"; msg["text"] != want {
+		t.Errorf("dead letter text = %q, want %q", msg["text"], want)
+	}
+}
+
+func TestWebhook_DeadLetterShared(t *testing.T) {
+	rcv := &receiver{fail: 1000, code: http.StatusBadRequest}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dead := filepath.Join(dir, "dead.jsonl")
+
+	// Two webhooks writing to the same file at once keep their lines whole.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		w := &Webhook{URL: server.URL, DeadLetter: dead}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				w.Send(context.Background(), Message{Text: "frost"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	f, err := os.Open(dead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	n := 0
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(s.Bytes(), &line); err != nil {
+			t.Fatalf("line %d: %v", n, err)
+		}
+		n++
+	}
+	if n != 40 {
+		t.Errorf("dead letter has %d lines, want 40", n)
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	a, b := &receiver{}, &receiver{}
+	sa, sb := httptest.NewServer(a), httptest.NewServer(b)
+	defer sa.Close()
+	defer sb.Close()
+
+	n := &Notifier{Webhooks: []*Webhook{{URL: sa.URL}, {URL: sb.URL}}}
+	if err := n.Notify(context.Background(), Message{Kind: KindChange}); err != nil {
+		t.Fatalf("Notifier.Notify returned error: %v", err)
+	}
+	if len(a.bodies) != 1 || len(b.bodies) != 1 {
+		t.Errorf("webhooks got %d and %d messages, want 1 each", len(a.bodies), len(b.bodies))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 // Pollen represents the pollen/alergy data for the next few days.
@@ -35,3 +36,20 @@ func (c *Client) GetPollen(ctx context.Context, location string) (*Pollen, *http
 	}
 	return pollen, rsp, nil
 }
+
+// PollenLevel converts a PollenDay level such as "Moderate" into a number
+// from 1 (low) to 4 (very high) so levels can be compared. The second result
+// is false if the level isn't recognised.
+func PollenLevel(level string) (int, bool) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "low":
+		return 1, true
+	case "moderate":
+		return 2, true
+	case "high":
+		return 3, true
+	case "very high":
+		return 4, true
+	}
+	return 0, false
+}
@@ -62,3 +62,24 @@ func TestGetPollen_Get(t *testing.T) {
 		t.Errorf("Client.GetPollen returned %+v, want %+v", pollen, want)
 	}
 }
+
+func TestPollenLevel(t *testing.T) {
+	testCases := []struct {
+		level string
+		want  int
+		ok    bool
+	}{
+		{"Low", 1, true},
+		{"moderate", 2, true},
+		{" HIGH ", 3, true},
+		{"Very High", 4, true},
+		{"", 0, false},
+		{"extreme", 0, false},
+	}
+	for _, tc := range testCases {
+		got, ok := PollenLevel(tc.level)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("PollenLevel(%q) returned %d, %v, want %d, %v", tc.level, got, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package recorder
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+// setup returns a client for a test server, through rec, and a count of the
+// requests the server has had.
+func setup(rec *Recorder) (client *metservice.Client, hits *int32, teardown func()) {
+	hits = new(int32)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Header().Set("X-Request-Id", "1234")
+		fmt.Fprint(w, `{"days": [{"forecastWord": "Fine", "max": "14"}]}`)
+	})
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, `{"threeHour": {"temp": "11"}}`)
+		gz.Close()
+	})
+	server := httptest.NewServer(mux)
+	client = metservice.NewClient()
+	client.BaseURL = server.URL + "/"
+	client.HTTPClient = &http.Client{Transport: rec}
+	return client, hits, server.Close
+}
+
+func tempDir(t *testing.T) (dir string, teardown func()) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestRecorder(t *testing.T) {
+	dir, teardown := tempDir(t)
+	defer teardown()
+	ctx := context.Background()
+	rec := New(dir, ModeRecord)
+	client, hits, closeServer := setup(rec)
+
+	want := &metservice.Forecast{Days: []metservice.ForecastDay{{
+		ForecastWord: metservice.String("Fine"),
+		Max:          metservice.Int(14),
+	}}}
+	f, _, err := client.GetForecast(ctx, "Dunedin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, f); diff != "" {
+		t.Errorf("recorded forecast mismatch (-want +got):\n%s", diff)
+	}
+	if _, _, err := client.GetObservation(ctx, "Dunedin"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "localForecastDunedin.http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []string{"Date:", "Set-Cookie:", "X-Request-Id:"} {
+		if strings.Contains(string(b), h) {
+			t.Errorf("recording kept %s header:\n%s", h, b)
+		}
+	}
+	if !strings.HasPrefix(string(b), "HTTP/1.1 200 OK\r\n") {
+		t.Errorf("recording doesn't start with the status line:\n%s", b)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "localObs_Dunedin.http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(b), `{"threeHour": {"temp": "11"}}`) {
+		t.Errorf("recording isn't decompressed:\n%q", b)
+	}
+
+	// Everything is replayed once the server has gone.
+	closeServer()
+	rec.Mode = ModeReplay
+	f, _, err = client.GetForecast(ctx, "Dunedin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, f); diff != "" {
+		t.Errorf("replayed forecast mismatch (-want +got):\n%s", diff)
+	}
+	o, _, err := client.GetObservation(ctx, "Dunedin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.ThreeHour == nil || o.ThreeHour.Temp == nil || *o.ThreeHour.Temp != 11 {
+		t.Errorf("replayed observation is %+v", o)
+	}
+	if n := atomic.LoadInt32(hits); n != 2 {
+		t.Errorf("server had %d requests, want 2", n)
+	}
+
+	_, _, err = client.GetPollen(ctx, "Dunedin")
+	if err == nil || !strings.Contains(err.Error(), NotRecordedError{Path: filepath.Join(dir, "pollen_town_Dunedin.http")}.Error()) {
+		t.Errorf("replaying a missing recording got error %v", err)
+	}
+}
+
+func TestRecorder_auto(t *testing.T) {
+	dir, teardown := tempDir(t)
+	defer teardown()
+	client, hits, closeServer := setup(New(dir, ModeAuto))
+	defer closeServer()
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := client.GetForecast(context.Background(), "Dunedin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(hits); n != 1 {
+		t.Errorf("server had %d requests, want 1", n)
+	}
+}
+
+func TestRecorder_Path(t *testing.T) {
+	rec := New("testdata", ModeReplay)
+	testCases := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.metservice.com/publicData/localForecastDunedin", "testdata/publicData/localForecastDunedin.http"},
+		{"http://localhost:8080/localObs_Dunedin", "testdata/localObs_Dunedin.http"},
+		{"http://localhost/", "testdata/index.http"},
+		{"http://localhost/a/../../../etc/passwd", "testdata/etc/passwd.http"},
+		{"http://localhost/search?q=a b", "testdata/search%3Fq%3Da+b.http"},
+	}
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rec.Path(u); got != filepath.FromSlash(tc.want) {
+			t.Errorf("Path(%q) = %q, want %q", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, m := range []Mode{ModeReplay, ModeRecord, ModeAuto} {
+		got, err := ParseMode(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseMode(%q) = %v, %v", m, got, err)
+		}
+	}
+	if _, err := ParseMode("play"); err == nil {
+		t.Error("ParseMode(\"play\") succeeded")
+	}
+}
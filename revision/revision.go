@@ -0,0 +1,284 @@
+// revision tracks how the forecast for a day changes between successive
+// metservice issues.
+package revision
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Issue is a single issued forecast for a day.
+type Issue struct {
+	IssuedAt time.Time
+	Day      metservice.ForecastDay
+}
+
+// TextChange records a change in a piece of forecast wording.
+type TextChange struct {
+	Old string
+	New string
+}
+
+// TempChange records a change in a forecast temperature. Old or New is nil
+// if the temperature appeared or disappeared between the issues.
+type TempChange struct {
+	Old *int
+	New *int
+}
+
+// Delta returns the new temperature minus the old one, or zero if either is
+// missing.
+func (c TempChange) Delta() int {
+	if c.Old == nil || c.New == nil {
+		return 0
+	}
+	return *c.New - *c.Old
+}
+
+// Added reports whether the temperature is only in the newer issue.
+func (c TempChange) Added() bool {
+	return c.Old == nil && c.New != nil
+}
+
+// Removed reports whether the temperature is only in the older issue.
+func (c TempChange) Removed() bool {
+	return c.Old != nil && c.New == nil
+}
+
+// IconChange records a change in the icon for part of a day.
+type IconChange struct {
+	// Part is one of "morning", "afternoon", "evening" or "overnight".
+	Part string
+	Old  string
+	New  string
+}
+
+// Revision describes what changed in the forecast for a location and date
+// from one issue to the next. Nil fields and empty slices mean nothing
+// changed.
+type Revision struct {
+	Location string
+	Date     time.Time
+	From     time.Time
+	To       time.Time
+
+	Max          *TempChange
+	Min          *TempChange
+	Forecast     *TextChange
+	ForecastWord *TextChange
+	Icons        []IconChange
+
+	// Superseded marks a revision returned by an earlier call to Add that
+	// no longer applies, because an issue has since arrived that falls
+	// between From and To.
+	Superseded bool
+}
+
+// Changed reports whether anything differs between the two issues.
+func (r Revision) Changed() bool {
+	return r.Max != nil || r.Min != nil ||
+		r.Forecast != nil || r.ForecastWord != nil ||
+		len(r.Icons) > 0
+}
+
+// Tracker collects the issues of each forecast day. Days that have dropped
+// out of a location's forecast are forgotten, so a long-running Tracker only
+// holds the days still being forecast. It is safe for concurrent use. The
+// zero value is ready for use.
+type Tracker struct {
+	mu     sync.Mutex
+	issues map[key][]Issue
+	// first holds the earliest day in the newest forecast for each
+	// location, as a Unix time. Earlier days are dropped.
+	first map[string]int64
+}
+
+type key struct {
+	location string
+	date     int64
+}
+
+// New returns an empty Tracker.
+func New() *Tracker {
+	return new(Tracker)
+}
+
+// Add records every day in f for location and returns the revisions it
+// introduced, comparing each new issue with the issues either side of it.
+// Only revisions where something Changed are returned. If an issue arrives
+// out of order, the revision between its neighbours is returned first with
+// Superseded set, so callers can drop it. Days that lack a date or issue
+// time, whose issue has already been seen, or that are before the first day
+// of the newest forecast for location are skipped.
+func (t *Tracker) Add(location string, f *metservice.Forecast) []Revision {
+	if f == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.issues == nil {
+		t.issues = make(map[key][]Issue)
+		t.first = make(map[string]int64)
+	}
+	t.prune(location, f)
+
+	var revs []Revision
+	add := func(r Revision) {
+		if r.Changed() {
+			revs = append(revs, r)
+		}
+	}
+	for _, day := range f.Days {
+		if day.Date == nil || day.IssuedAt == nil {
+			continue
+		}
+		k := key{location, day.Date.Unix()}
+		if first, ok := t.first[location]; ok && k.date < first {
+			continue
+		}
+		issues := t.issues[k]
+		i := sort.Search(len(issues), func(i int) bool {
+			return !issues[i].IssuedAt.Before(day.IssuedAt.Time)
+		})
+		if i < len(issues) && issues[i].IssuedAt.Equal(day.IssuedAt.Time) {
+			continue
+		}
+		issue := Issue{IssuedAt: day.IssuedAt.Time, Day: day}
+		issues = append(issues, Issue{})
+		copy(issues[i+1:], issues[i:])
+		issues[i] = issue
+		t.issues[k] = issues
+
+		if i > 0 && i+1 < len(issues) {
+			stale := Compare(location, issues[i-1], issues[i+1])
+			stale.Superseded = true
+			add(stale)
+		}
+		if i > 0 {
+			add(Compare(location, issues[i-1], issue))
+		}
+		if i+1 < len(issues) {
+			add(Compare(location, issue, issues[i+1]))
+		}
+	}
+	return revs
+}
+
+// prune drops the days for location before the first day of f, if f starts
+// later than any forecast seen before.
+func (t *Tracker) prune(location string, f *metservice.Forecast) {
+	first, ok := int64(0), false
+	for _, day := range f.Days {
+		if day.Date == nil || day.IssuedAt == nil {
+			continue
+		}
+		if d := day.Date.Unix(); !ok || d < first {
+			first, ok = d, true
+		}
+	}
+	if prev, seen := t.first[location]; !ok || seen && first <= prev {
+		return
+	}
+	t.first[location] = first
+	for k := range t.issues {
+		if k.location == location && k.date < first {
+			delete(t.issues, k)
+		}
+	}
+}
+
+// History returns the issues seen for location and date, oldest first.
+func (t *Tracker) History(location string, date time.Time) []Issue {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	issues := t.issues[key{location, date.Unix()}]
+	return append([]Issue(nil), issues...)
+}
+
+// Revisions returns the revisions between each consecutive pair of issues
+// for location and date where something changed, oldest first.
+func (t *Tracker) Revisions(location string, date time.Time) []Revision {
+	issues := t.History(location, date)
+	var revs []Revision
+	for i := 1; i < len(issues); i++ {
+		if r := Compare(location, issues[i-1], issues[i]); r.Changed() {
+			revs = append(revs, r)
+		}
+	}
+	return revs
+}
+
+// Compare returns the Revision going from issue a to issue b.
+func Compare(location string, a, b Issue) Revision {
+	r := Revision{
+		Location: location,
+		From:     a.IssuedAt,
+		To:       b.IssuedAt,
+	}
+	if b.Day.Date != nil {
+		r.Date = b.Day.Date.Time
+	}
+	r.Max = temp(a.Day.Max, b.Day.Max)
+	r.Min = temp(a.Day.Min, b.Day.Min)
+	r.Forecast = text(a.Day.Forecast, b.Day.Forecast)
+	r.ForecastWord = text(a.Day.ForecastWord, b.Day.ForecastWord)
+
+	oldParts, newParts := parts(a.Day.Part), parts(b.Day.Part)
+	for i, name := range partNames {
+		if c := text(icon(oldParts[i]), icon(newParts[i])); c != nil {
+			r.Icons = append(r.Icons, IconChange{Part: name, Old: c.Old, New: c.New})
+		}
+	}
+	return r
+}
+
+var partNames = [...]string{"morning", "afternoon", "evening", "overnight"}
+
+func parts(p *metservice.DayPart) [4]*metservice.DayPartTime {
+	if p == nil {
+		return [4]*metservice.DayPartTime{}
+	}
+	return [4]*metservice.DayPartTime{p.Morning, p.Afternoon, p.Evening, p.Overnight}
+}
+
+func icon(p *metservice.DayPartTime) *string {
+	if p == nil {
+		return nil
+	}
+	return p.IconType
+}
+
+// temp returns a TempChange if a and b differ, including when only one of
+// them is missing.
+func temp(a, b *int) *TempChange {
+	if a == nil && b == nil || a != nil && b != nil && *a == *b {
+		return nil
+	}
+	c := &TempChange{}
+	if a != nil {
+		c.Old = metservice.Int(*a)
+	}
+	if b != nil {
+		c.New = metservice.Int(*b)
+	}
+	return c
+}
+
+// text returns a TextChange if a and b differ. A missing value is treated as
+// an empty string.
+func text(a, b *string) *TextChange {
+	var before, after string
+	if a != nil {
+		before = *a
+	}
+	if b != nil {
+		after = *b
+	}
+	if before == after {
+		return nil
+	}
+	return &TextChange{Old: before, New: after}
+}
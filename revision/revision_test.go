@@ -0,0 +1,174 @@
+package revision
+
+import (
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+var referenceTime = time.Date(2006, time.January, 02, 0, 0, 0, 0, time.UTC)
+
+func day(issued time.Duration, max, min int, word, morning string) metservice.ForecastDay {
+	return metservice.ForecastDay{
+		Date:         &metservice.Timestamp{Time: referenceTime},
+		IssuedAt:     &metservice.Timestamp{Time: referenceTime.Add(issued)},
+		Max:          metservice.Int(max),
+		Min:          metservice.Int(min),
+		ForecastWord: metservice.String(word),
+		Part: &metservice.DayPart{
+			Morning: &metservice.DayPartTime{IconType: metservice.String(morning)},
+		},
+	}
+}
+
+func TestTracker_Add(t *testing.T) {
+	tr := New()
+
+	revs := tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-24*time.Hour, 20, 10, "Fine", "sunny")},
+	})
+	if len(revs) != 0 {
+		t.Errorf("first issue returned %d revisions, want 0", len(revs))
+	}
+
+	revs = tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-12*time.Hour, 18, 10, "Showers", "showers")},
+	})
+	want := []Revision{{
+		Location:     "Dunedin",
+		Date:         referenceTime,
+		From:         referenceTime.Add(-24 * time.Hour),
+		To:           referenceTime.Add(-12 * time.Hour),
+		Max:          &TempChange{Old: metservice.Int(20), New: metservice.Int(18)},
+		ForecastWord: &TextChange{Old: "Fine", New: "Showers"},
+		Icons:        []IconChange{{Part: "morning", Old: "sunny", New: "showers"}},
+	}}
+	if diff := cmp.Diff(want, revs); diff != "" {
+		t.Errorf("Tracker.Add mismatch (-want +got):\n%s", diff)
+	}
+
+	// Seeing the same issue again changes nothing.
+	revs = tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-12*time.Hour, 18, 10, "Showers", "showers")},
+	})
+	if len(revs) != 0 {
+		t.Errorf("repeated issue returned %d revisions, want 0", len(revs))
+	}
+	if got := len(tr.History("Dunedin", referenceTime)); got != 2 {
+		t.Errorf("History has %d issues, want 2", got)
+	}
+}
+
+func TestTracker_OutOfOrder(t *testing.T) {
+	tr := New()
+	tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{
+			day(-24*time.Hour, 20, 10, "Fine", "sunny"),
+			day(-6*time.Hour, 22, 10, "Fine", "sunny"),
+		},
+	})
+
+	// An older fetch arriving late is compared with both neighbours, and
+	// the revision between them is superseded.
+	revs := tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-12*time.Hour, 21, 10, "Fine", "sunny")},
+	})
+	if len(revs) != 3 {
+		t.Fatalf("Tracker.Add returned %d revisions, want 3", len(revs))
+	}
+	if r := revs[0]; !r.Superseded || !r.From.Equal(referenceTime.Add(-24*time.Hour)) ||
+		!r.To.Equal(referenceTime.Add(-6*time.Hour)) {
+		t.Errorf("first revision = %+v, want the superseded one between the neighbours", r)
+	}
+	if got := revs[1].Max.Delta(); got != 1 || revs[1].Superseded {
+		t.Errorf("second Max.Delta() = %d, want 1", got)
+	}
+	if got := revs[2].Max.Delta(); got != 1 || revs[2].Superseded {
+		t.Errorf("third Max.Delta() = %d, want 1", got)
+	}
+
+	history := tr.Revisions("Dunedin", referenceTime)
+	if len(history) != 2 {
+		t.Fatalf("Tracker.Revisions returned %d revisions, want 2", len(history))
+	}
+	if !history[0].To.Equal(referenceTime.Add(-12 * time.Hour)) {
+		t.Errorf("Revisions not in issue order: %+v", history)
+	}
+}
+
+func TestTracker_Unchanged(t *testing.T) {
+	tr := New()
+	tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-24*time.Hour, 20, 10, "Fine", "sunny")},
+	})
+	revs := tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-12*time.Hour, 20, 10, "Fine", "sunny")},
+	})
+	if len(revs) != 0 {
+		t.Errorf("unchanged issue returned %d revisions, want 0: %+v", len(revs), revs)
+	}
+	if got := tr.Revisions("Dunedin", referenceTime); len(got) != 0 {
+		t.Errorf("Tracker.Revisions returned %d revisions, want 0", len(got))
+	}
+}
+
+func TestTracker_Prune(t *testing.T) {
+	tr := New()
+	tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-24*time.Hour, 20, 10, "Fine", "sunny")},
+	})
+	tr.Add("Christchurch", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-24*time.Hour, 20, 10, "Fine", "sunny")},
+	})
+
+	// Once the forecast moves on to the next day, the old day is dropped
+	// for that location only, and late issues for it are ignored.
+	next := day(0, 20, 10, "Fine", "sunny")
+	next.Date = &metservice.Timestamp{Time: referenceTime.AddDate(0, 0, 1)}
+	tr.Add("Dunedin", &metservice.Forecast{Days: []metservice.ForecastDay{next}})
+	if revs := tr.Add("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{day(-12*time.Hour, 25, 10, "Fine", "sunny")},
+	}); len(revs) != 0 {
+		t.Errorf("late issue for a dropped day returned %d revisions, want 0", len(revs))
+	}
+	if got := len(tr.History("Dunedin", referenceTime)); got != 0 {
+		t.Errorf("History has %d issues for a dropped day, want 0", got)
+	}
+	if got := len(tr.History("Dunedin", next.Date.Time)); got != 1 {
+		t.Errorf("History has %d issues for the current day, want 1", got)
+	}
+	if got := len(tr.History("Christchurch", referenceTime)); got != 1 {
+		t.Errorf("History has %d issues for another location, want 1", got)
+	}
+}
+
+func TestCompare_Temps(t *testing.T) {
+	a := Issue{IssuedAt: referenceTime, Day: day(0, 20, 10, "Fine", "sunny")}
+	b := Issue{IssuedAt: referenceTime.Add(time.Hour), Day: day(time.Hour, 20, 10, "Fine", "sunny")}
+	a.Day.Max = nil
+	b.Day.Min = nil
+
+	r := Compare("Dunedin", a, b)
+	if r.Max == nil || !r.Max.Added() || r.Max.Removed() || *r.Max.New != 20 {
+		t.Errorf("Max = %+v, want added", r.Max)
+	}
+	if r.Min == nil || !r.Min.Removed() || r.Min.Added() || *r.Min.Old != 10 {
+		t.Errorf("Min = %+v, want removed", r.Min)
+	}
+	if r.Max.Delta() != 0 || r.Min.Delta() != 0 {
+		t.Errorf("deltas = %d and %d, want 0", r.Max.Delta(), r.Min.Delta())
+	}
+	if !r.Changed() {
+		t.Error("Changed() = false, want true")
+	}
+}
+
+func TestCompare_Unchanged(t *testing.T) {
+	a := Issue{IssuedAt: referenceTime, Day: day(0, 20, 10, "Fine", "sunny")}
+	b := Issue{IssuedAt: referenceTime.Add(time.Hour), Day: day(time.Hour, 20, 10, "Fine", "sunny")}
+	if r := Compare("Dunedin", a, b); r.Changed() {
+		t.Errorf("Compare of identical days reported a change: %+v", r)
+	}
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func owmSetup() (s *Server, teardown func()) {
+	s, mux, teardown := setup()
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"threeHour": {"dateTimeISO": "2006-01-02T15:00:00+13:00", "temp": "11", "windChill": "8",
+			"humidity": "70", "windSpeed": "36", "windDirection": "SW", "rainfall": "0.0"},
+			"twentyFourHour": {"maxTemp": 15, "minTemp": 6}}`)
+	})
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"days": [{"dateISO": "2006-01-02T00:00:00+13:00", "forecastWord": "Partly cloudy",
+			"forecast": "Cloudy periods.", "max": "16", "min": "6",
+			"riseSet": {"sunRiseISO": "2006-01-02T05:59:00+13:00", "sunSetISO": "2006-01-02T21:30:00+13:00"}}]}`)
+	})
+	mux.HandleFunc("/riseSet_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sunRiseISO": "2006-01-02T05:59:00+13:00", "sunSetISO": "2006-01-02T21:30:00+13:00"}`)
+	})
+	mux.HandleFunc("/hourlyObsAndForecast_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"forecastData": [
+			{"dateISO": "2006-01-02T16:00:00+13:00", "temperature": "12", "rainfall": "0.0", "windSpeed": "18", "windDir": "N"},
+			{"dateISO": "2006-01-02T23:00:00+13:00", "temperature": "9", "rainfall": "1.5"}]}`)
+	})
+	return s, teardown
+}
+
+func TestServer_OWMWeather(t *testing.T) {
+	s, teardown := owmSetup()
+	defer teardown()
+
+	rec := get(s, "/data/2.5/weather?q=dunedin,nz&units=metric&appid=ignored")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get(PartialHeader); got != "" {
+		t.Errorf("got %s %q for a full response", PartialHeader, got)
+	}
+	if _, ok := s.cachedBody("weather?Dunedin&metric&"); !ok {
+		t.Error("full response wasn't cached")
+	}
+	var got OWMWeather
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	town, _ := metservice.LookupTown("Dunedin")
+	want := OWMWeather{
+		Coord:   OWMCoord{Lon: town.Longitude, Lat: town.Latitude},
+		Weather: []OWMCondition{{802, "Clouds", "scattered clouds", "03d"}},
+		Base:    "stations",
+		Main: OWMMain{
+			Temp:      11,
+			FeelsLike: 8,
+			TempMin:   6,
+			TempMax:   15,
+			Humidity:  metservice.Int(70),
+		},
+		Wind:     OWMWind{Speed: metservice.Float64(10), Deg: metservice.Float64(225)},
+		Rain:     &OWMRain{ThreeHour: metservice.Float64(0)},
+		Dt:       1136167200,
+		Sys:      OWMSys{Country: "NZ", Sunrise: 1136134740, Sunset: 1136190600},
+		Timezone: 13 * 3600,
+		Name:     "Dunedin",
+		Cod:      200,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("weather mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestServer_OWMUnits(t *testing.T) {
+	s, teardown := owmSetup()
+	defer teardown()
+
+	testCases := []struct {
+		units string
+		temp  float64
+		speed float64
+	}{
+		{"", 284.15, 10},
+		{"standard", 284.15, 10},
+		{"metric", 11, 10},
+		{"imperial", 51.8, 22.37},
+	}
+	for _, tc := range testCases {
+		rec := get(s, "/data/2.5/weather?q=Dunedin&units="+tc.units)
+		var got OWMWeather
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Main.Temp != tc.temp || got.Wind.Speed == nil || *got.Wind.Speed != tc.speed {
+			t.Errorf("units %q: got temp %v speed %v, want %v and %v",
+				tc.units, got.Main.Temp, got.Wind.Speed, tc.temp, tc.speed)
+		}
+	}
+}
+
+func TestServer_OWMOneCall(t *testing.T) {
+	s, teardown := owmSetup()
+	defer teardown()
+
+	town, _ := metservice.LookupTown("Dunedin")
+	path := fmt.Sprintf("/data/3.0/onecall?lat=%f&lon=%f&units=metric&exclude=current,minutely", town.Latitude, town.Longitude)
+	rec := get(s, path)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var got OWMOneCall
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := OWMOneCall{
+		Lat:            town.Latitude,
+		Lon:            town.Longitude,
+		Timezone:       "Pacific/Auckland",
+		TimezoneOffset: 13 * 3600,
+		Hourly: []OWMHour{
+			{
+				Dt:        1136170800,
+				Temp:      12,
+				FeelsLike: 12,
+				WindSpeed: metservice.Float64(5),
+				WindDeg:   metservice.Float64(0),
+				Weather:   []OWMCondition{{802, "Clouds", "scattered clouds", "03d"}},
+			},
+			{
+				Dt:        1136196000,
+				Temp:      9,
+				FeelsLike: 9,
+				Weather:   []OWMCondition{{501, "Rain", "moderate rain", "10n"}},
+				Rain:      &OWMRain{OneHour: metservice.Float64(1.5)},
+			},
+		},
+		Daily: []OWMDay{
+			{
+				Dt:      1136156400,
+				Sunrise: 1136134740,
+				Sunset:  1136190600,
+				Summary: "Cloudy periods.",
+				Temp:    &OWMDayTemp{Day: 16, Min: 6, Max: 16, Night: 6, Eve: 11, Morn: 11},
+				Weather: []OWMCondition{{802, "Clouds", "scattered clouds", "03d"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("onecall mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestServer_OWMMissing(t *testing.T) {
+	s, mux, teardown := setup()
+	defer teardown()
+	mux.HandleFunc("/localObs_Nelson", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"threeHour": {"dateTimeISO": "2006-01-02T15:00:00+13:00", "temp": "11"}}`)
+	})
+	mux.HandleFunc("/localObs_Motueka", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"threeHour": {"dateTimeISO": "2006-01-02T15:00:00+13:00", "windSpeed": "20"}}`)
+	})
+
+	// A missing wind speed is left out rather than made calm.
+	rec := get(s, "/data/2.5/weather?q=Nelson")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got["wind"]) != "{}" {
+		t.Errorf("got wind %s, want {}", got["wind"])
+	}
+	// Nelson has no forecast or rise and set times, so the response is
+	// partial and not cached.
+	if got := rec.Header().Get(PartialHeader); got != "forecast,riseset" {
+		t.Errorf("got %s %q, want forecast,riseset", PartialHeader, got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("got Cache-Control %q, want no-store", got)
+	}
+	if _, ok := s.cachedBody("weather?Nelson&standard&"); ok {
+		t.Error("partial response was cached")
+	}
+
+	// There's no current weather without a temperature.
+	rec = get(s, "/data/2.5/weather?q=Motueka")
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("got status %d, want 502: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServer_OWMErrors(t *testing.T) {
+	s, teardown := owmSetup()
+	defer teardown()
+
+	testCases := []struct {
+		path   string
+		status int
+		msg    string
+	}{
+		{"/data/2.5/weather?q=Atlantis", http.StatusNotFound, "city not found"},
+		// The middle of the Pacific is too far from any town.
+		{"/data/2.5/weather?lat=-30&lon=-150", http.StatusNotFound, "city not found"},
+		{"/data/2.5/weather", http.StatusBadRequest, "Nothing to geocode"},
+		{"/data/2.5/weather?q=Dunedin&units=kelvin", http.StatusBadRequest, "units is not valid"},
+		// Known town, but the API has no data for it.
+		{"/data/2.5/weather?q=Nelson", http.StatusNotFound, "city not found"},
+		{"/data/2.5/forecast?q=Dunedin", http.StatusNotFound, "Internal error: 404"},
+	}
+	for _, tc := range testCases {
+		rec := get(s, tc.path)
+		var got struct {
+			Cod     string `json:"cod"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+		if rec.Code != tc.status || got.Cod != fmt.Sprint(tc.status) || got.Message != tc.msg {
+			t.Errorf("%s: got %d %+v, want %d %q", tc.path, rec.Code, got, tc.status, tc.msg)
+		}
+	}
+}
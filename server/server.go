@@ -0,0 +1,491 @@
+// server serves a cleaned up, cached JSON API in front of metservice for
+// browsers and other clients that can't call it directly.
+//
+// The routes are
+//
+//	GET /v1/{location}/forecast
+//	GET /v1/{location}/observation
+//	GET /v1/{location}/hourly
+//	GET /v1/{location}/pollen
+//	GET /v1/{location}/riseset
+//
+// Responses are cached for TTL and carry an ETag so clients can revalidate
+// with If-None-Match. Errors are JSON of the form
+//
+//	{"error": {"status": 404, "code": "location_not_found", "message": "..."}}
+//
+// The same data is also served in the shape of the OpenWeatherMap API so
+// tools written for it can be pointed at a Server unmodified. Those routes
+// are
+//
+//	GET /data/2.5/weather?q={town}|lat={lat}&lon={lon}[&units=...]
+//	GET /data/2.5/onecall?...[&exclude=...]
+//	GET /data/3.0/onecall?...
+//
+// A lat and lon are mapped to the nearest town in metservice.Towns within
+// MaxTownDistance. Units are standard (Kelvin, m/s), metric (°C, m/s) or
+// imperial (°F, mph), as with OpenWeatherMap. The appid and lang parameters
+// are ignored. Values metservice doesn't provide, such as the air pressure,
+// are left out. If the forecast, rise and set times or hourly data can't be
+// fetched the response is still served without them, but isn't cached, and
+// PartialHeader lists what's missing.
+//
+// GraphQL queries are answered at /graphql when GraphQL is set, with the same
+// CORS headers as the other routes.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// PartialHeader is set on an OpenWeatherMap response that is missing
+// data, to the comma separated endpoints that failed.
+const PartialHeader = "X-Partial"
+
+// DefaultTTL is how long a Server caches responses unless told otherwise.
+const DefaultTTL = 5 * time.Minute
+
+// DefaultTimeout is how long a Server waits for the API unless told
+// otherwise.
+const DefaultTimeout = 30 * time.Second
+
+// Error codes used in error responses.
+const (
+	CodeNotFound         = "not_found"            // no such route
+	CodeBadLocation      = "bad_location"         // see ValidLocation
+	CodeUnknownEndpoint  = "unknown_endpoint"     // see metservice.UnknownEndpointError
+	CodeLocationNotFound = "location_not_found"   // the API returned 404
+	CodeUpstreamStatus   = "upstream_status"      // see metservice.StatusError
+	CodeUpstreamFailed   = "upstream_unavailable" // the API couldn't be reached
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeInternal         = "internal"
+)
+
+// routes maps the last path element to the endpoint it serves.
+var routes = map[string]metservice.Endpoint{
+	"forecast":    metservice.EndpointForecast,
+	"observation": metservice.EndpointObservation,
+	"hourly":      metservice.EndpointObservationForecastHours,
+	"pollen":      metservice.EndpointPollen,
+	"riseset":     metservice.EndpointRiseSet,
+}
+
+// locationPattern matches the names the API uses for locations. Anything
+// else, such as a decoded "/" or "?", would change the upstream URL.
+var locationPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z '-]{0,63}$`)
+
+// ValidLocation reports whether location looks like a town name and is safe
+// to pass on to the API.
+func ValidLocation(location string) bool {
+	return locationPattern.MatchString(location)
+}
+
+// Server is an http.Handler serving the API. Create one with New.
+type Server struct {
+	Client *metservice.Client
+	// TTL is how long responses are cached.
+	TTL time.Duration
+	// Timeout limits each fetch from the API. A fetch isn't tied to the
+	// request that started it, as other requests may be waiting on it too.
+	Timeout time.Duration
+	// AllowOrigin is sent in the Access-Control-Allow-Origin header. Empty
+	// disables CORS.
+	AllowOrigin string
+	// GraphQL, if set, serves /graphql. Give it the Server as its
+	// graphql.Fetcher so queries share the cache.
+	GraphQL http.Handler
+
+	mu     sync.Mutex
+	cache  map[cacheKey]*cacheEntry
+	bodies map[string]*body
+	now    func() time.Time
+}
+
+type cacheKey struct {
+	endpoint metservice.Endpoint
+	location string
+}
+
+// cacheEntry is a cached API response. ready is closed once the fetch that
+// fills it is done, so concurrent requests share one upstream request.
+type cacheEntry struct {
+	ready   chan struct{}
+	value   interface{}
+	expires time.Time
+	err     error
+}
+
+// New returns a Server using client that caches for DefaultTTL and allows
+// requests from any origin.
+func New(client *metservice.Client) *Server {
+	return &Server{
+		Client:      client,
+		TTL:         DefaultTTL,
+		Timeout:     DefaultTimeout,
+		AllowOrigin: "*",
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.AllowOrigin != "" {
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", s.AllowOrigin)
+		h.Set("Access-Control-Expose-Headers", "ETag, "+PartialHeader)
+		if s.AllowOrigin != "*" {
+			h.Add("Vary", "Origin")
+		}
+	}
+	graphql := r.URL.Path == "/graphql" && s.GraphQL != nil
+	if r.Method == http.MethodOptions {
+		h := w.Header()
+		if graphql {
+			h.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Content-Type")
+		} else {
+			h.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "If-None-Match")
+		}
+		h.Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if graphql {
+		s.GraphQL.ServeHTTP(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/data/") {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			owmError(w, http.StatusMethodNotAllowed, r.Method+" not allowed")
+			return
+		}
+		s.serveOWM(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "v1" || parts[1] == "" {
+		writeError(w, http.StatusNotFound, CodeNotFound, "no route for "+r.URL.Path)
+		return
+	}
+	location, name := parts[1], parts[2]
+	if !ValidLocation(location) {
+		writeError(w, http.StatusBadRequest, CodeBadLocation, fmt.Sprintf("bad location %q", location))
+		return
+	}
+	endpoint, ok := routes[name]
+	if !ok {
+		err := metservice.UnknownEndpointError{Endpoint: metservice.Endpoint(name)}
+		writeError(w, http.StatusNotFound, CodeUnknownEndpoint, err.Error())
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" not allowed")
+		return
+	}
+
+	key := "/v1/" + location + "/" + name
+	if b, ok := s.cachedBody(key); ok {
+		s.writeBody(w, r, b)
+		return
+	}
+	e := s.get(r.Context(), cacheKey{endpoint, location})
+	if e.err != nil {
+		writeUpstreamError(w, e.err)
+		return
+	}
+	var v interface{}
+	switch x := e.value.(type) {
+	case *metservice.Forecast:
+		v = NewForecast(location, x)
+	case *metservice.Observation:
+		v = NewObservation(location, x)
+	case *metservice.ObservationForecastHours:
+		v = NewHourly(location, x)
+	case *metservice.Pollen:
+		v = NewPollen(location, x)
+	case *metservice.RiseSet:
+		v = NewRiseSet(location, x)
+	}
+	s.writeJSON(w, r, key, v, e.expires)
+}
+
+// body is an encoded response. Bodies are cached by the URL they answer,
+// so repeat requests don't marshal and hash the same data again.
+type body struct {
+	data    []byte
+	etag    string
+	expires time.Time
+}
+
+// cachedBody returns the body cached for key if it hasn't expired.
+func (s *Server) cachedBody(key string) (*body, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bodies[key]
+	if !ok || !s.clock().Before(b.expires) {
+		return nil, false
+	}
+	return b, true
+}
+
+// encode marshals v and caches it for key until expires, which should be
+// when the data it was made from expires. An empty key isn't cached.
+func (s *Server) encode(key string, v interface{}, expires time.Time) (*body, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	sum := sha256.Sum256(data)
+	b := &body{
+		data:    data,
+		etag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+		expires: expires,
+	}
+	if key == "" {
+		return b, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bodies == nil {
+		s.bodies = make(map[string]*body)
+	}
+	now := s.clock()
+	for k, old := range s.bodies {
+		if !now.Before(old.expires) {
+			delete(s.bodies, k)
+		}
+	}
+	s.bodies[key] = b
+	return b, nil
+}
+
+// writeJSON writes v, caching it for key as in encode.
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, key string, v interface{}, expires time.Time) {
+	b, err := s.encode(key, v, expires)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	s.writeBody(w, r, b)
+}
+
+// writeBody writes b with an ETag and a Cache-Control header that expires
+// with the cached data, or just a 304 if the client already has it. A body
+// with no expiry isn't to be stored.
+func (s *Server) writeBody(w http.ResponseWriter, r *http.Request, b *body) {
+	h := w.Header()
+	h.Set("ETag", b.etag)
+	if b.expires.IsZero() {
+		h.Set("Cache-Control", "no-store")
+	} else {
+		maxAge := int(b.expires.Sub(s.clock()).Seconds())
+		if maxAge < 0 {
+			maxAge = 0
+		}
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatch(match, b.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(b.data)))
+	if r.Method != http.MethodHead {
+		w.Write(b.data)
+	}
+}
+
+func (s *Server) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// Fetch returns endpoint for location from the cache, fetching it if it's
+// missing or expired. It makes the Server a graphql.Fetcher. A location that
+// fails ValidLocation is an Error.
+func (s *Server) Fetch(ctx context.Context, endpoint metservice.Endpoint, location string) (interface{}, error) {
+	if !ValidLocation(location) {
+		return nil, Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeBadLocation,
+			Message: fmt.Sprintf("bad location %q", location),
+		}
+	}
+	e := s.get(ctx, cacheKey{endpoint, location})
+	return e.value, e.err
+}
+
+// get returns the cached API response for key, fetching it if it's missing
+// or expired. Failed fetches aren't cached. ctx only limits how long this
+// caller waits; the fetch itself carries on for anyone else waiting on it.
+func (s *Server) get(ctx context.Context, key cacheKey) *cacheEntry {
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = make(map[cacheKey]*cacheEntry)
+	}
+	e, ok := s.cache[key]
+	if ok {
+		select {
+		case <-e.ready:
+			if e.err == nil && s.clock().Before(e.expires) {
+				s.mu.Unlock()
+				return e
+			}
+			ok = false
+		default:
+			// Another request is fetching it.
+		}
+	}
+	if !ok {
+		e = &cacheEntry{ready: make(chan struct{})}
+		s.cache[key] = e
+		go s.fill(key, e)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-e.ready:
+	case <-ctx.Done():
+		return &cacheEntry{err: ctx.Err()}
+	}
+	return e
+}
+
+func (s *Server) fill(key cacheKey, e *cacheEntry) {
+	defer close(e.ready)
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	v, err := s.fetch(ctx, key)
+	if err != nil {
+		e.err = err
+		s.mu.Lock()
+		if s.cache[key] == e {
+			delete(s.cache, key)
+		}
+		s.mu.Unlock()
+		return
+	}
+	e.value = v
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	e.expires = s.clock().Add(ttl)
+}
+
+// fetch gets a single endpoint.
+func (s *Server) fetch(ctx context.Context, key cacheKey) (interface{}, error) {
+	c, loc := s.Client, key.location
+	var (
+		v   interface{}
+		err error
+	)
+	switch key.endpoint {
+	case metservice.EndpointForecast:
+		v, _, err = c.GetForecast(ctx, loc)
+	case metservice.EndpointObservation:
+		v, _, err = c.GetObservation(ctx, loc)
+	case metservice.EndpointObservationForecastHours:
+		v, _, err = c.GetObservationForecastHours(ctx, loc)
+	case metservice.EndpointPollen:
+		v, _, err = c.GetPollen(ctx, loc)
+	case metservice.EndpointRiseSet:
+		v, _, err = c.GetRiseSet(ctx, loc)
+	default:
+		err = metservice.UnknownEndpointError{Endpoint: key.endpoint}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func etagMatch(header, etag string) bool {
+	for _, m := range strings.Split(header, ",") {
+		m = strings.TrimPrefix(strings.TrimSpace(m), "W/")
+		if m == "*" || m == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Error is the body of an error response.
+type Error struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// UpstreamStatus is the status returned by the API for an
+	// upstream_status or location_not_found error.
+	UpstreamStatus int `json:"upstream_status,omitempty"`
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeErrorBody(w, Error{Status: status, Code: code, Message: msg})
+}
+
+func writeErrorBody(w http.ResponseWriter, e Error) {
+	var b bytes.Buffer
+	json.NewEncoder(&b).Encode(struct {
+		Error Error `json:"error"`
+	}{e})
+	h := w.Header()
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(e.Status)
+	b.WriteTo(w)
+}
+
+// writeUpstreamError writes the response for an error from the library.
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	switch e := err.(type) {
+	case metservice.StatusError:
+		if e.Code == http.StatusNotFound {
+			writeErrorBody(w, Error{
+				Status:         http.StatusNotFound,
+				Code:           CodeLocationNotFound,
+				Message:        "location not found",
+				UpstreamStatus: e.Code,
+			})
+			return
+		}
+		writeErrorBody(w, Error{
+			Status:         http.StatusBadGateway,
+			Code:           CodeUpstreamStatus,
+			Message:        e.Error(),
+			UpstreamStatus: e.Code,
+		})
+	case metservice.UnknownEndpointError:
+		writeError(w, http.StatusNotFound, CodeUnknownEndpoint, e.Error())
+	default:
+		writeError(w, http.StatusBadGateway, CodeUpstreamFailed, err.Error())
+	}
+}
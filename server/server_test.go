@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"git.sr.ht/~kota/metservice-go/graphql"
+	"github.com/google/go-cmp/cmp"
+)
+
+func setup() (s *Server, mux *http.ServeMux, teardown func()) {
+	mux = http.NewServeMux()
+	upstream := httptest.NewServer(mux)
+	client := metservice.NewClient()
+	client.BaseURL = upstream.URL + "/"
+	return New(client), mux, upstream.Close
+}
+
+func get(s *Server, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Routes(t *testing.T) {
+	s, mux, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"days": [{"dateISO": "2006-01-02T00:00:00+13:00", "forecastWord": "Fine", "max": "14",
+			"partDayData": {"morning": {"forecastWord": "Fine", "iconType": "fine"}}}]}`)
+	})
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"threeHour": {"dateTimeISO": "2006-01-02T15:00:00+13:00", "temp": "11", "windDirection": "NW"},
+			"twentyFourHour": {"rainfall": "5.5", "maxTemp": 15}}`)
+	})
+	mux.HandleFunc("/hourlyObsAndForecast_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"actualData": [{"dateISO": "2006-01-02T15:00:00+13:00", "temperature": "10.5"}],
+			"forecastData": [{"dateISO": "2006-01-02T16:00:00+13:00", "temperature": "12", "humidity": "80"}]}`)
+	})
+	mux.HandleFunc("/pollen_town_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"pollen": [{"dayDescriptor": "Today", "level": "High", "type": "Grass"}]}`)
+	})
+	mux.HandleFunc("/riseSet_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"dayISO": "2006-01-02T00:00:00+13:00", "sunRiseISO": "2006-01-02T05:59:00+13:00"}`)
+	})
+
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{
+			"/v1/Dunedin/forecast",
+			`{"location":"Dunedin","days":[{"date":"2006-01-02","word":"Fine","max":14,"parts":{"morning":{"word":"Fine","icon":"fine"}}}]}`,
+		},
+		{
+			"/v1/Dunedin/observation",
+			`{"location":"Dunedin","observed_at":"2006-01-02T15:00:00+13:00","temp":11,"wind_direction":"NW","rainfall_24h":5.5,"max_24h":15}`,
+		},
+		{
+			"/v1/Dunedin/hourly",
+			`{"location":"Dunedin","hours":[{"time":"2006-01-02T15:00:00+13:00","source":"observed","temp":10.5},` +
+				`{"time":"2006-01-02T16:00:00+13:00","source":"forecast","temp":12,"humidity":80}]}`,
+		},
+		{
+			"/v1/Dunedin/pollen",
+			`{"location":"Dunedin","days":[{"day":"Today","type":"Grass","level":"High","level_index":3}]}`,
+		},
+		{
+			"/v1/Dunedin/riseset",
+			`{"location":"Dunedin","date":"2006-01-02","sunrise":"2006-01-02T05:59:00+13:00"}`,
+		},
+	}
+	for _, tc := range testCases {
+		rec := get(s, tc.path)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: got status %d: %s", tc.path, rec.Code, rec.Body.String())
+			continue
+		}
+		if diff := cmp.Diff(tc.want+"\n", rec.Body.String()); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", tc.path, diff)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("%s: got Access-Control-Allow-Origin %q", tc.path, got)
+		}
+	}
+}
+
+func TestServer_Cache(t *testing.T) {
+	s, mux, teardown := setup()
+	defer teardown()
+	now := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	var hits int32
+	mux.HandleFunc("/riseSet_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		fmt.Fprintf(w, `{"id": "%d"}`, n)
+	})
+
+	first := get(s, "/v1/Dunedin/riseset")
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if got := first.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Errorf("got Cache-Control %q", got)
+	}
+
+	now = now.Add(time.Minute)
+	second := get(s, "/v1/Dunedin/riseset", "If-None-Match", etag)
+	if second.Code != http.StatusNotModified || second.Body.Len() != 0 {
+		t.Errorf("revalidation got status %d with body %q, want 304", second.Code, second.Body.String())
+	}
+	if got := second.Header().Get("Cache-Control"); got != "public, max-age=240" {
+		t.Errorf("got Cache-Control %q", got)
+	}
+	if hits != 1 {
+		t.Errorf("upstream hit %d times within the TTL, want 1", hits)
+	}
+
+	now = now.Add(5 * time.Minute)
+	get(s, "/v1/Dunedin/riseset")
+	if hits != 2 {
+		t.Errorf("upstream hit %d times after the TTL, want 2", hits)
+	}
+}
+
+func TestServer_CacheCanceled(t *testing.T) {
+	s, mux, teardown := setup()
+	defer teardown()
+
+	var hits int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux.HandleFunc("/riseSet_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			close(started)
+		}
+		<-release
+		fmt.Fprint(w, `{"dayISO": "2006-01-02T00:00:00+13:00"}`)
+	})
+
+	// The first client gives up while the fetch is in flight, which mustn't
+	// fail it for the next.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/Dunedin/riseset", nil).WithContext(ctx))
+		done <- rec
+	}()
+	<-started
+	cancel()
+	<-done
+	close(release)
+
+	rec := get(s, "/v1/Dunedin/riseset")
+	if rec.Code != http.StatusOK {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	if hits != 1 {
+		t.Errorf("upstream hit %d times, want 1", hits)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	s, mux, teardown := setup()
+	defer teardown()
+	mux.HandleFunc("/localForecastBroken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	testCases := []struct {
+		method string
+		path   string
+		want   Error
+	}{
+		{"GET", "/v1/Nowhere/forecast", Error{Status: 404, Code: CodeLocationNotFound, Message: "location not found", UpstreamStatus: 404}},
+		{"GET", "/v1/Broken/forecast", Error{Status: 502, Code: CodeUpstreamStatus, Message: "bad responce status code: 503", UpstreamStatus: 503}},
+		{"GET", "/v1/Dun%3Fedin/forecast", Error{Status: 400, Code: CodeBadLocation, Message: `bad location "Dun?edin"`}},
+		{"GET", "/v1/Dunedin/tides", Error{Status: 404, Code: CodeUnknownEndpoint, Message: "unknown endpoint: tides"}},
+		{"GET", "/v2/Dunedin/forecast", Error{Status: 404, Code: CodeNotFound, Message: "no route for /v2/Dunedin/forecast"}},
+		{"POST", "/v1/Dunedin/forecast", Error{Status: 405, Code: CodeMethodNotAllowed, Message: "POST not allowed"}},
+	}
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		var body struct {
+			Error Error `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s %s: bad body %q: %v", tc.method, tc.path, rec.Body.String(), err)
+			continue
+		}
+		if rec.Code != tc.want.Status {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, rec.Code, tc.want.Status)
+		}
+		if diff := cmp.Diff(tc.want, body.Error); diff != "" {
+			t.Errorf("%s %s: mismatch (-want +got):\n%s", tc.method, tc.path, diff)
+		}
+	}
+}
+
+func TestServer_Preflight(t *testing.T) {
+	s := New(metservice.NewClient())
+	s.AllowOrigin = "https://example.com"
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("OPTIONS", "/v1/Dunedin/forecast", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("got status %d, want 204", rec.Code)
+	}
+	h := rec.Header()
+	if h.Get("Access-Control-Allow-Origin") != "https://example.com" || h.Get("Vary") != "Origin" ||
+		h.Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("got headers %v", h)
+	}
+}
+
+func TestServer_GraphQL(t *testing.T) {
+	s, mux, teardown := setup()
+	defer teardown()
+	s.GraphQL = &graphql.Handler{Fetcher: s}
+
+	var hits int32
+	mux.HandleFunc("/localObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		fmt.Fprint(w, `{"threeHour": {"temp": "11"}}`)
+	})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("OPTIONS", "/graphql", nil))
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, HEAD, POST, OPTIONS" {
+		t.Errorf("preflight got Access-Control-Allow-Methods %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Errorf("preflight got Access-Control-Allow-Headers %q", got)
+	}
+
+	// The query shares the cache with the other routes.
+	get(s, "/v1/Dunedin/observation")
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(
+		`{"query": "{ observation(location: \"Dunedin\") { threeHour { temp } } bad: observation(location: \"Dun?edin\") { location } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	want := `{"data":{"observation":{"threeHour":{"temp":11}},"bad":null},` +
+		`"errors":[{"message":"400 bad_location: bad location \"Dun?edin\"","locations":[{"line":1,"column":59}],"path":["bad"]}]}` + "\n"
+	if diff := cmp.Diff(want, rec.Body.String()); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("got Access-Control-Allow-Origin %q", got)
+	}
+	if hits != 1 {
+		t.Errorf("upstream hit %d times, want 1", hits)
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// The types below are the cleaned up JSON served by a Server. Field names
+// are snake case, units are °C, km/h and mm, times are RFC 3339 and dates
+// are YYYY-MM-DD. Values the API didn't give are left out.
+
+// Forecast is served at /v1/{location}/forecast.
+type Forecast struct {
+	Location string        `json:"location"`
+	Days     []ForecastDay `json:"days"`
+}
+
+// ForecastDay is a single day of a Forecast.
+type ForecastDay struct {
+	Date     string                  `json:"date,omitempty"`
+	IssuedAt *time.Time              `json:"issued_at,omitempty"`
+	Word     string                  `json:"word,omitempty"`
+	Text     string                  `json:"text,omitempty"`
+	Max      *int                    `json:"max,omitempty"`
+	Min      *int                    `json:"min,omitempty"`
+	Parts    map[string]ForecastPart `json:"parts,omitempty"`
+}
+
+// ForecastPart is the forecast for part of a day, keyed in
+// ForecastDay.Parts by morning, afternoon, evening or overnight.
+type ForecastPart struct {
+	Word string `json:"word,omitempty"`
+	Icon string `json:"icon,omitempty"`
+}
+
+// Observation is served at /v1/{location}/observation.
+type Observation struct {
+	Location      string     `json:"location"`
+	ObservedAt    *time.Time `json:"observed_at,omitempty"`
+	Temp          *int       `json:"temp,omitempty"`
+	Humidity      *int       `json:"humidity,omitempty"`
+	Rainfall      *float64   `json:"rainfall,omitempty"`
+	WindSpeed     *int       `json:"wind_speed,omitempty"`
+	WindDirection string     `json:"wind_direction,omitempty"`
+	WindChill     *int       `json:"wind_chill,omitempty"`
+	Pressure      string     `json:"pressure,omitempty"`
+	Rainfall24h   *float64   `json:"rainfall_24h,omitempty"`
+	Max24h        *int       `json:"max_24h,omitempty"`
+	Min24h        *int       `json:"min_24h,omitempty"`
+}
+
+// Hourly is served at /v1/{location}/hourly.
+type Hourly struct {
+	Location              string   `json:"location"`
+	RainfallTotalObserved *float64 `json:"rainfall_total_observed,omitempty"`
+	RainfallTotalForecast *float64 `json:"rainfall_total_forecast,omitempty"`
+	Hours                 []Hour   `json:"hours"`
+}
+
+// Hour is a single observed or forecast hour of Hourly.
+type Hour struct {
+	Time *time.Time `json:"time,omitempty"`
+	// Source is "observed" or "forecast".
+	Source        string   `json:"source"`
+	Temp          *float64 `json:"temp,omitempty"`
+	Rainfall      *float64 `json:"rainfall,omitempty"`
+	Humidity      *int     `json:"humidity,omitempty"`
+	WindSpeed     *int     `json:"wind_speed,omitempty"`
+	WindDirection string   `json:"wind_direction,omitempty"`
+}
+
+// Pollen is served at /v1/{location}/pollen.
+type Pollen struct {
+	Location string      `json:"location"`
+	Days     []PollenDay `json:"days"`
+}
+
+// PollenDay is a single day of Pollen. LevelIndex is the level from 1 (low)
+// to 4 (very high), as given by metservice.PollenLevel.
+type PollenDay struct {
+	Day        string     `json:"day,omitempty"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidTo    *time.Time `json:"valid_to,omitempty"`
+	Type       string     `json:"type,omitempty"`
+	Level      string     `json:"level,omitempty"`
+	LevelIndex int        `json:"level_index,omitempty"`
+}
+
+// RiseSet is served at /v1/{location}/riseset.
+type RiseSet struct {
+	Location   string     `json:"location"`
+	Date       string     `json:"date,omitempty"`
+	FirstLight *time.Time `json:"first_light,omitempty"`
+	SunRise    *time.Time `json:"sunrise,omitempty"`
+	SunSet     *time.Time `json:"sunset,omitempty"`
+	LastLight  *time.Time `json:"last_light,omitempty"`
+	MoonRise   *time.Time `json:"moonrise,omitempty"`
+	MoonSet    *time.Time `json:"moonset,omitempty"`
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func tm(t *metservice.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
+
+func date(t *metservice.Timestamp) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// NewForecast converts a Forecast.
+func NewForecast(location string, f *metservice.Forecast) Forecast {
+	out := Forecast{Location: location, Days: []ForecastDay{}}
+	for _, d := range f.Days {
+		day := ForecastDay{
+			Date:     date(d.Date),
+			IssuedAt: tm(d.IssuedAt),
+			Word:     str(d.ForecastWord),
+			Text:     str(d.Forecast),
+			Max:      d.Max,
+			Min:      d.Min,
+		}
+		if p := d.Part; p != nil {
+			parts := map[string]*metservice.DayPartTime{
+				"morning":   p.Morning,
+				"afternoon": p.Afternoon,
+				"evening":   p.Evening,
+				"overnight": p.Overnight,
+			}
+			for name, part := range parts {
+				if part == nil {
+					continue
+				}
+				if day.Parts == nil {
+					day.Parts = make(map[string]ForecastPart)
+				}
+				day.Parts[name] = ForecastPart{Word: str(part.ForecastWord), Icon: str(part.IconType)}
+			}
+		}
+		out.Days = append(out.Days, day)
+	}
+	return out
+}
+
+// NewObservation converts an Observation.
+func NewObservation(location string, o *metservice.Observation) Observation {
+	out := Observation{Location: location}
+	if h := o.ThreeHour; h != nil {
+		out.ObservedAt = tm(h.Date)
+		out.Temp = h.Temp
+		out.Humidity = h.Humidity
+		out.Rainfall = h.Rainfall
+		out.WindSpeed = h.WindSpeed
+		out.WindDirection = str(h.WindDirection)
+		out.WindChill = h.WindChill
+		out.Pressure = str(h.Pressure)
+	}
+	if d := o.TwentyFourHour; d != nil {
+		out.Rainfall24h = d.Rainfall
+		out.Max24h = d.Max
+		out.Min24h = d.Min
+	}
+	return out
+}
+
+// NewHourly converts an ObservationForecastHours.
+func NewHourly(location string, ofh *metservice.ObservationForecastHours) Hourly {
+	out := Hourly{
+		Location:              location,
+		RainfallTotalObserved: ofh.RainfallTotalObserved,
+		RainfallTotalForecast: ofh.RainfallTotalForecast,
+		Hours:                 []Hour{},
+	}
+	for _, h := range ofh.Observations {
+		out.Hours = append(out.Hours, Hour{
+			Time:          tm(h.Date),
+			Source:        "observed",
+			Temp:          h.Temp,
+			Rainfall:      h.Rainfall,
+			WindSpeed:     h.WindSpeed,
+			WindDirection: str(h.WindDirection),
+		})
+	}
+	for _, h := range ofh.Forecasts {
+		hour := Hour{
+			Time:          tm(h.Date),
+			Source:        "forecast",
+			Rainfall:      h.Rainfall,
+			Humidity:      h.Humidity,
+			WindSpeed:     h.WindSpeed,
+			WindDirection: str(h.WindDirection),
+		}
+		if h.Temp != nil {
+			hour.Temp = metservice.Float64(float64(*h.Temp))
+		}
+		out.Hours = append(out.Hours, hour)
+	}
+	return out
+}
+
+// NewPollen converts a Pollen.
+func NewPollen(location string, p *metservice.Pollen) Pollen {
+	out := Pollen{Location: location, Days: []PollenDay{}}
+	for _, d := range p.PollenDays {
+		day := PollenDay{
+			Day:       str(d.DayDescriptor),
+			ValidFrom: tm(d.ValidFrom),
+			ValidTo:   tm(d.ValidTo),
+			Type:      str(d.Type),
+			Level:     str(d.Level),
+		}
+		day.LevelIndex, _ = metservice.PollenLevel(day.Level)
+		out.Days = append(out.Days, day)
+	}
+	return out
+}
+
+// NewRiseSet converts a RiseSet.
+func NewRiseSet(location string, r *metservice.RiseSet) RiseSet {
+	return RiseSet{
+		Location:   location,
+		Date:       date(r.Date),
+		FirstLight: tm(r.FirstLight),
+		SunRise:    tm(r.SunRise),
+		SunSet:     tm(r.SunSet),
+		LastLight:  tm(r.LastLight),
+		MoonRise:   tm(r.MoonRise),
+		MoonSet:    tm(r.MoonSet),
+	}
+}
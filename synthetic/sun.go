@@ -0,0 +1,90 @@
+package synthetic
+
+import (
+	"math"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Sun altitudes, in degrees, for the events in a RiseSet.
+const (
+	sunriseAltitude  = -0.833 // upper limb on the horizon, with refraction
+	twilightAltitude = -6.0   // civil twilight
+)
+
+// synodicMonth is the average time from one new moon to the next, in days.
+const synodicMonth = 29.530588853
+
+// newMoon is a known new moon.
+var newMoon = time.Date(2000, time.January, 6, 18, 14, 0, 0, time.UTC)
+
+// sunTimes returns when the sun crosses altitude, in degrees, going up and
+// coming down on the day starting at date, using the NOAA solar equations.
+// ok is false if it doesn't cross that day.
+func sunTimes(date time.Time, lat, lon, altitude float64) (up, down time.Time, ok bool) {
+	g := 2 * math.Pi / 365 * (float64(date.YearDay()) - 0.5)
+	eqTime := 229.18 * (0.000075 + 0.001868*math.Cos(g) - 0.032077*math.Sin(g) -
+		0.014615*math.Cos(2*g) - 0.040849*math.Sin(2*g))
+	decl := 0.006918 - 0.399912*math.Cos(g) + 0.070257*math.Sin(g) -
+		0.006758*math.Cos(2*g) + 0.000907*math.Sin(2*g) -
+		0.002697*math.Cos(3*g) + 0.00148*math.Sin(3*g)
+
+	phi := lat * math.Pi / 180
+	cosHA := (math.Sin(altitude*math.Pi/180) - math.Sin(phi)*math.Sin(decl)) /
+		(math.Cos(phi) * math.Cos(decl))
+	if cosHA < -1 || cosHA > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	ha := math.Acos(cosHA) * 180 / math.Pi
+
+	// Minutes from midnight UTC on the same calendar date, which may be
+	// negative as New Zealand is ahead of UTC.
+	noon := 720 - 4*lon - eqTime
+	y, m, d := date.Date()
+	utc := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	minutes := func(v float64) time.Time {
+		return utc.Add(time.Duration(v * float64(time.Minute))).In(date.Location()).Truncate(time.Minute)
+	}
+	return minutes(noon - 4*ha), minutes(noon + 4*ha), true
+}
+
+// sunrise returns the hour of the day, such as 6.5 for 6:30, that the sun
+// rises on the day starting at date. It's 6 if the sun doesn't rise.
+func (g *Generator) sunrise(date time.Time) float64 {
+	up, _, ok := sunTimes(date, g.latitude(), g.longitude(), sunriseAltitude)
+	if !ok {
+		return 6
+	}
+	return up.Sub(date).Hours()
+}
+
+// RiseSet returns the rise and set times for the day containing t. The sun
+// and first and last light are worked out from the town's position. The
+// moon rises about 50 minutes later each day through its cycle; its times
+// are only rough.
+func (g *Generator) RiseSet(t time.Time) *metservice.RiseSet {
+	date := g.date(t)
+	lat, lon := g.latitude(), g.longitude()
+	r := &metservice.RiseSet{
+		Date:     timestamp(date),
+		Location: metservice.String(g.Town.Name),
+	}
+	if up, down, ok := sunTimes(date, lat, lon, sunriseAltitude); ok {
+		r.SunRise, r.SunSet = timestamp(up), timestamp(down)
+	}
+	if up, down, ok := sunTimes(date, lat, lon, twilightAltitude); ok {
+		r.FirstLight, r.LastLight = timestamp(up), timestamp(down)
+	}
+
+	// At new moon it rises with the sun, and later each day after.
+	age := math.Mod(date.Sub(newMoon).Hours()/24, synodicMonth)
+	if age < 0 {
+		age += synodicMonth
+	}
+	rise := math.Mod(g.sunrise(date)+age/synodicMonth*24.84, 24)
+	moonRise := date.Add(time.Duration(rise * float64(time.Hour))).Truncate(time.Minute)
+	r.MoonRise = timestamp(moonRise)
+	r.MoonSet = timestamp(moonRise.Add(12*time.Hour + 25*time.Minute))
+	return r
+}
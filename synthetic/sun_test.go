@@ -0,0 +1,60 @@
+package synthetic
+
+import (
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+var (
+	nzst = time.FixedZone("NZST", 12*60*60)
+	nzdt = time.FixedZone("NZDT", 13*60*60)
+)
+
+func TestGenerator_RiseSet(t *testing.T) {
+	testCases := []struct {
+		town    string
+		date    time.Time
+		sunRise string
+		sunSet  string
+	}{
+		// Published times for the solstices.
+		{"Dunedin", time.Date(2024, time.June, 21, 12, 0, 0, 0, nzst), "08:19", "16:59"},
+		{"Auckland", time.Date(2024, time.December, 21, 12, 0, 0, 0, nzdt), "05:59", "20:40"},
+	}
+	for _, tc := range testCases {
+		town, ok := metservice.LookupTown(tc.town)
+		if !ok {
+			t.Fatalf("unknown town %s", tc.town)
+		}
+		g := New(town, 0)
+		g.Location = tc.date.Location()
+		r := g.RiseSet(tc.date)
+
+		check := func(name string, got *metservice.Timestamp, want string) {
+			w, err := time.ParseInLocation("2006-01-02 15:04", tc.date.Format("2006-01-02 ")+want, tc.date.Location())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == nil {
+				t.Errorf("%s %s: got nil, want %s", tc.town, name, want)
+				return
+			}
+			if d := got.Sub(w); d < -5*time.Minute || d > 5*time.Minute {
+				t.Errorf("%s %s: got %s, want %s", tc.town, name, got.Format("15:04"), want)
+			}
+		}
+		check("sun rise", r.SunRise, tc.sunRise)
+		check("sun set", r.SunSet, tc.sunSet)
+
+		if !r.FirstLight.Before(r.SunRise.Time) || !r.LastLight.After(r.SunSet.Time) {
+			t.Errorf("%s: twilight %s to %s isn't around the day %s to %s", tc.town,
+				r.FirstLight.Format("15:04"), r.LastLight.Format("15:04"),
+				r.SunRise.Format("15:04"), r.SunSet.Format("15:04"))
+		}
+		if r.MoonRise == nil || r.MoonSet == nil || !r.MoonSet.After(r.MoonRise.Time) {
+			t.Errorf("%s: moon rise %v and set %v are out of order", tc.town, r.MoonRise, r.MoonSet)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+// synthetic generates plausible fake metservice data for load tests and
+// demos.
+//
+// The weather of each day is worked out from the seed, town and date alone,
+// so a Generator returns the same values however it's called, and the
+// forecasts, observations, pollen levels and rise and set times it returns
+// agree with each other. Temperatures follow the season and the time of
+// day, humidity rises as the temperature drops and when it rains, and rain
+// falls in a spell on some days.
+package synthetic
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Counts of the values returned, matching the API.
+const (
+	ForecastDays  = 10
+	ObservedHours = 24
+	ForecastHours = 48
+)
+
+// peakHour is when the temperature peaks each day.
+const peakHour = 15.0
+
+// Generator makes up data for a town. The zero value generates data for
+// central New Zealand with seed zero.
+type Generator struct {
+	// Town decides the climate and the sun and moon times.
+	Town metservice.Town
+	Seed int64
+	// Location is the zone dates and times are in. Nil uses New Zealand
+	// time.
+	Location *time.Location
+}
+
+// New returns a Generator for town with seed.
+func New(town metservice.Town, seed int64) *Generator {
+	return &Generator{Town: town, Seed: seed}
+}
+
+var (
+	nzOnce sync.Once
+	nz     *time.Location
+)
+
+// newZealand returns New Zealand time, or standard time if the time zone
+// database isn't available.
+func newZealand() *time.Location {
+	nzOnce.Do(func() {
+		var err error
+		nz, err = time.LoadLocation("Pacific/Auckland")
+		if err != nil {
+			nz = time.FixedZone("NZST", 12*60*60)
+		}
+	})
+	return nz
+}
+
+func (g *Generator) location() *time.Location {
+	if g.Location != nil {
+		return g.Location
+	}
+	return newZealand()
+}
+
+func (g *Generator) latitude() float64 {
+	if g.Town.Latitude == 0 && g.Town.Longitude == 0 {
+		return -41.29
+	}
+	return g.Town.Latitude
+}
+
+func (g *Generator) longitude() float64 {
+	if g.Town.Latitude == 0 && g.Town.Longitude == 0 {
+		return 174.78
+	}
+	return g.Town.Longitude
+}
+
+// date returns midnight at the start of the day containing t.
+func (g *Generator) date(t time.Time) time.Time {
+	y, m, d := t.In(g.location()).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, g.location())
+}
+
+// day is the weather of a single day.
+type day struct {
+	date     time.Time
+	min, max float64
+	cloud    float64 // 0 for clear to 1 for overcast
+	// rain falls for rainHours hours from rainStart.
+	rain      float64
+	rainStart int
+	rainHours int
+	wind      float64
+	windDir   string
+	word      string
+}
+
+var windDirections = []string{"N", "NE", "E", "SE", "S", "SW", "SW", "W", "W", "W", "NW", "NW"}
+
+// day returns the weather of the day starting at date.
+func (g *Generator) day(date time.Time) *day {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%s", g.Seed, g.Town.Name, date.Format("2006-01-02"))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	// Further south is colder, with a bigger difference between summer
+	// and winter, which peak at the end of January and July.
+	lat := math.Abs(g.latitude())
+	mean := 16.5 - 0.45*(lat-35)
+	amplitude := 3.5 + 0.12*(lat-35)
+	season := math.Cos(2 * math.Pi * float64(date.YearDay()-30) / 365.25)
+	mean += amplitude*season + 2*r.NormFloat64()
+
+	d := &day{date: date, cloud: r.Float64()}
+	spread := math.Max(3, 11-6*d.cloud+r.NormFloat64())
+	d.min, d.max = round1(mean-spread/2), round1(mean+spread/2)
+
+	d.wind = 5 + 30*r.Float64()
+	d.windDir = windDirections[r.Intn(len(windDirections))]
+	if r.Float64() < 0.15+0.5*d.cloud {
+		d.rainHours = 1 + r.Intn(10)
+		d.rainStart = r.Intn(24 - d.rainHours + 1)
+		d.rain = math.Max(r.ExpFloat64()*5, 0.2*float64(d.rainHours))
+		d.wind += 10
+	}
+
+	switch {
+	case d.rain >= 10:
+		d.word = "Rain"
+	case d.rain > 0 && d.rainHours <= 3:
+		d.word = "Few showers"
+	case d.rain > 0:
+		d.word = "Showers"
+	case d.wind >= 35:
+		d.word = "Windy"
+	case d.cloud > 0.75:
+		d.word = "Cloudy"
+	case d.cloud > 0.4:
+		d.word = "Partly cloudy"
+	default:
+		d.word = "Fine"
+	}
+	return d
+}
+
+// rainIn returns the rain in mm falling in the hour starting at hour. Rain
+// is heaviest in the middle of a spell.
+func (d *day) rainIn(hour int) float64 {
+	i := hour - d.rainStart
+	if d.rain == 0 || i < 0 || i >= d.rainHours {
+		return 0
+	}
+	var total float64
+	for j := 0; j < d.rainHours; j++ {
+		total += weight(j, d.rainHours)
+	}
+	return round1(d.rain * weight(i, d.rainHours) / total)
+}
+
+func weight(i, n int) float64 {
+	return math.Min(float64(i+1), float64(n-i))
+}
+
+// hour is the weather at a moment.
+type hour struct {
+	temp     float64
+	humidity int
+	wind     int
+	windDir  string
+	// rain fell in the hour before.
+	rain float64
+}
+
+// at returns the weather at t, which is usually on the hour.
+func (g *Generator) at(t time.Time) hour {
+	t = t.In(g.location())
+	date := g.date(t)
+	d := g.day(date)
+	h := t.Sub(date).Hours()
+	sunrise := g.sunrise(date)
+
+	// The temperature falls from the peak of one day to a low at sunrise
+	// the next, then rises to the next peak. The curve is kept within the
+	// day's range, so the forecast's max and min hold.
+	var temp float64
+	switch {
+	case h < sunrise:
+		prev := g.day(date.AddDate(0, 0, -1))
+		temp = ease(prev.max, d.min, (h-(peakHour-24))/(sunrise-(peakHour-24)))
+	case h <= peakHour:
+		temp = ease(d.min, d.max, (h-sunrise)/(peakHour-sunrise))
+	default:
+		next := g.day(date.AddDate(0, 0, 1))
+		end := 24 + g.sunrise(date.AddDate(0, 0, 1))
+		temp = ease(d.max, next.min, (h-peakHour)/(end-peakHour))
+	}
+	temp = math.Min(math.Max(temp, d.min), d.max)
+
+	// The rain in the hour before t.
+	before := t.Add(-time.Hour)
+	bd := d
+	if g.date(before) != date {
+		bd = g.day(g.date(before))
+	}
+	rain := bd.rainIn(before.Hour())
+
+	// The dew point sits near the overnight low, closer under cloud, and
+	// reaches the temperature when it rains.
+	dew := d.min - 3 + 3*d.cloud
+	if rain > 0 {
+		dew = temp - 0.5
+	}
+	humidity := 100 * math.Exp(17.625*dew/(243.04+dew)-17.625*temp/(243.04+temp))
+
+	// Wind picks up through the afternoon.
+	wind := d.wind * (0.75 + 0.35*math.Max(0, math.Sin(math.Pi*(h-8)/14)))
+
+	return hour{
+		temp:     round1(temp),
+		humidity: int(math.Round(math.Min(math.Max(humidity, 25), 100))),
+		wind:     int(math.Round(wind)),
+		windDir:  d.windDir,
+		rain:     rain,
+	}
+}
+
+// ease goes from a to b as f goes from 0 to 1, changing slowest at the
+// ends.
+func ease(a, b, f float64) float64 {
+	f = math.Min(math.Max(f, 0), 1)
+	return a + (b-a)*(1-math.Cos(math.Pi*f))/2
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func timestamp(t time.Time) *metservice.Timestamp {
+	return &metservice.Timestamp{Time: t}
+}
+
+// Forecast returns the forecast issued at issued, for ForecastDays days
+// from the day it was issued.
+func (g *Generator) Forecast(issued time.Time) *metservice.Forecast {
+	f := &metservice.Forecast{}
+	first := g.date(issued)
+	for i := 0; i < ForecastDays; i++ {
+		date := first.AddDate(0, 0, i)
+		d := g.day(date)
+		f.Days = append(f.Days, metservice.ForecastDay{
+			Date:         timestamp(date),
+			Forecast:     metservice.String(d.text()),
+			ForecastWord: metservice.String(d.word),
+			IssuedAt:     timestamp(issued.In(g.location())),
+			Max:          metservice.Int(int(math.Round(d.max))),
+			Min:          metservice.Int(int(math.Round(d.min))),
+			Part: &metservice.DayPart{
+				Overnight: d.part(0),
+				Morning:   d.part(6),
+				Afternoon: d.part(12),
+				Evening:   d.part(18),
+			},
+			RiseSet: g.RiseSet(date),
+		})
+	}
+	return f
+}
+
+// text describes the day, such as "Showers, mainly in the afternoon.
+// Westerlies."
+func (d *day) text() string {
+	s := d.word
+	if d.rain > 0 {
+		switch mid := d.rainStart + d.rainHours/2; {
+		case d.rainHours >= 8:
+		case mid < 6:
+			s += " overnight"
+		case mid < 12:
+			s += ", mainly in the morning"
+		case mid < 18:
+			s += ", mainly in the afternoon"
+		default:
+			s += " in the evening"
+		}
+	}
+	names := map[string]string{
+		"N": "Northerlies", "NE": "Northeasterlies", "E": "Easterlies", "SE": "Southeasterlies",
+		"S": "Southerlies", "SW": "Southwesterlies", "W": "Westerlies", "NW": "Northwesterlies",
+	}
+	s += ". " + names[d.windDir]
+	if d.wind >= 30 {
+		s += ", strong at times"
+	}
+	return s + "."
+}
+
+// part describes the six hours from start.
+func (d *day) part(start int) *metservice.DayPartTime {
+	var rain float64
+	for h := start; h < start+6; h++ {
+		rain += d.rainIn(h)
+	}
+	word := "Fine"
+	switch {
+	case rain >= 5:
+		word = "Rain"
+	case rain > 0:
+		word = "Showers"
+	case d.cloud > 0.75:
+		word = "Cloudy"
+	case d.cloud > 0.4:
+		word = "Partly cloudy"
+	}
+	return &metservice.DayPartTime{
+		ForecastWord: metservice.String(word),
+		IconType:     metservice.String(word),
+	}
+}
+
+// Observation returns the latest observation at t, taken on the hour.
+func (g *Generator) Observation(t time.Time) *metservice.Observation {
+	now := t.In(g.location()).Truncate(time.Hour)
+	cur := g.at(now)
+
+	three := &metservice.ObservationThreeHour{
+		Date:          timestamp(now),
+		Temp:          metservice.Int(int(math.Round(cur.temp))),
+		WindChill:     metservice.Int(int(math.Round(windChill(cur.temp, cur.wind)))),
+		Humidity:      metservice.Int(cur.humidity),
+		WindSpeed:     metservice.Int(cur.wind),
+		WindDirection: metservice.String(cur.windDir),
+		Pressure:      metservice.String(g.pressure(now)),
+	}
+	var rain3, rain24 float64
+	min, max := math.Inf(1), math.Inf(-1)
+	for i := 0; i < 24; i++ {
+		h := g.at(now.Add(time.Duration(-i) * time.Hour))
+		if i < 3 {
+			rain3 += h.rain
+		}
+		rain24 += h.rain
+		min, max = math.Min(min, h.temp), math.Max(max, h.temp)
+	}
+	three.Rainfall = metservice.Float64(round1(rain3))
+
+	return &metservice.Observation{
+		Location:  metservice.String(g.Town.Name),
+		ThreeHour: three,
+		TwentyFourHour: &metservice.ObservationTwentyFourHour{
+			Min:      metservice.Int(int(math.Round(min))),
+			Max:      metservice.Int(int(math.Round(max))),
+			Rainfall: metservice.Float64(round1(rain24)),
+		},
+	}
+}
+
+// windChill returns how cold temp in °C feels in a wind in km/h, using the
+// formula from Environment Canada.
+func windChill(temp float64, wind int) float64 {
+	if temp > 10 || wind < 5 {
+		return temp
+	}
+	v := math.Pow(float64(wind), 0.16)
+	return 13.12 + 0.6215*temp - 11.37*v + 0.3965*temp*v
+}
+
+// pressure returns the pressure trend at t: falling ahead of rain and
+// rising once it has passed.
+func (g *Generator) pressure(t time.Time) string {
+	var past, future bool
+	for i := 0; i < 6; i++ {
+		past = past || g.at(t.Add(time.Duration(-i)*time.Hour)).rain > 0
+		future = future || g.at(t.Add(time.Duration(i+1)*time.Hour)).rain > 0
+	}
+	switch {
+	case future && !past:
+		return "Falling"
+	case past && !future:
+		return "Rising"
+	}
+	return "Steady"
+}
+
+// ObservationForecastHours returns the ObservedHours hours of observations
+// up to t and the ForecastHours hours of forecasts after it, all on the
+// hour.
+func (g *Generator) ObservationForecastHours(t time.Time) *metservice.ObservationForecastHours {
+	now := t.In(g.location()).Truncate(time.Hour)
+	o := &metservice.ObservationForecastHours{
+		Location:     metservice.String(g.Town.Name),
+		LocationName: metservice.String(g.Town.Name),
+	}
+	var observed, forecast float64
+	for i := ObservedHours - 1; i >= 0; i-- {
+		at := now.Add(time.Duration(-i) * time.Hour)
+		h := g.at(at)
+		o.Observations = append(o.Observations, metservice.ObservationHour{
+			Date:          timestamp(at),
+			Temp:          metservice.Float64(h.temp),
+			Rainfall:      metservice.Float64(h.rain),
+			WindSpeed:     metservice.Int(h.wind),
+			WindDirection: metservice.String(h.windDir),
+		})
+		observed += h.rain
+	}
+	for i := 1; i <= ForecastHours; i++ {
+		at := now.Add(time.Duration(i) * time.Hour)
+		h := g.at(at)
+		o.Forecasts = append(o.Forecasts, metservice.ForecastHour{
+			Date:          timestamp(at),
+			Temp:          metservice.Int(int(math.Round(h.temp))),
+			Humidity:      metservice.Int(h.humidity),
+			Rainfall:      metservice.Float64(h.rain),
+			WindSpeed:     metservice.Int(h.wind),
+			WindDirection: metservice.String(h.windDir),
+		})
+		forecast += h.rain
+	}
+	o.Count = metservice.Int(len(o.Observations) + len(o.Forecasts))
+	o.RainfallTotalObserved = metservice.Float64(round1(observed))
+	o.RainfallTotalForecast = metservice.Float64(round1(forecast))
+	o.WindSpeed = o.Observations[len(o.Observations)-1].WindSpeed
+	return o
+}
+
+// pollenLevels are the usual levels through the year, from January, from 1
+// for low to 4 for very high.
+var pollenLevels = [12]int{3, 2, 2, 1, 1, 1, 1, 2, 3, 3, 4, 4}
+
+var levelNames = [5]string{"", "Low", "Moderate", "High", "Very High"}
+
+// pollenType returns the main pollen type in month.
+func pollenType(month time.Month) string {
+	switch {
+	case month >= time.August && month <= time.October:
+		return "Pine"
+	case month >= time.November || month <= time.February:
+		return "Grass"
+	case month <= time.April:
+		return "Weeds"
+	}
+	return "Fungal spores"
+}
+
+// Pollen returns the pollen forecast for the day containing t and the day
+// after. Rain lowers the level and dry wind raises it.
+func (g *Generator) Pollen(t time.Time) *metservice.Pollen {
+	p := &metservice.Pollen{
+		Location: metservice.String(g.Town.Name),
+		Enabled:  metservice.Bool(true),
+	}
+	first := g.date(t)
+	for i, descriptor := range []string{"Today", "Tomorrow"} {
+		date := first.AddDate(0, 0, i)
+		d := g.day(date)
+		level := pollenLevels[date.Month()-1]
+		switch {
+		case d.rain >= 1:
+			level--
+		case d.wind >= 30:
+			level++
+		}
+		if level < 1 {
+			level = 1
+		} else if level > 4 {
+			level = 4
+		}
+		p.PollenDays = append(p.PollenDays, metservice.PollenDay{
+			DayDescriptor: metservice.String(descriptor),
+			Level:         metservice.String(levelNames[level]),
+			Type:          metservice.String(pollenType(date.Month())),
+			ValidFrom:     timestamp(date),
+			ValidTo:       timestamp(date.AddDate(0, 0, 1)),
+		})
+	}
+	return p
+}
@@ -0,0 +1,130 @@
+package synthetic
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+var referenceTime = time.Date(2006, time.January, 02, 14, 30, 0, 0, nzdt)
+
+func generator(t *testing.T, seed int64) *Generator {
+	town, ok := metservice.LookupTown("Dunedin")
+	if !ok {
+		t.Fatal("unknown town Dunedin")
+	}
+	g := New(town, seed)
+	g.Location = nzdt
+	return g
+}
+
+func TestGenerator_deterministic(t *testing.T) {
+	a, b := generator(t, 1), generator(t, 1)
+
+	// Calls in a different order give the same values.
+	wantHours := a.ObservationForecastHours(referenceTime)
+	wantForecast := a.Forecast(referenceTime)
+	gotForecast := b.Forecast(referenceTime)
+	gotHours := b.ObservationForecastHours(referenceTime)
+	if diff := cmp.Diff(wantForecast, gotForecast); diff != "" {
+		t.Errorf("Forecast mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantHours, gotHours); diff != "" {
+		t.Errorf("ObservationForecastHours mismatch (-want +got):\n%s", diff)
+	}
+
+	other := generator(t, 2).Forecast(referenceTime)
+	if cmp.Equal(wantForecast, other) {
+		t.Error("different seeds gave the same forecast")
+	}
+}
+
+func TestGenerator_consistent(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		g := generator(t, seed)
+		f := g.Forecast(referenceTime)
+		hours := g.ObservationForecastHours(referenceTime)
+		obs := g.Observation(referenceTime)
+
+		if len(f.Days) != ForecastDays || len(hours.Observations) != ObservedHours || len(hours.Forecasts) != ForecastHours {
+			t.Fatalf("seed %d: got %d days, %d observed and %d forecast hours", seed,
+				len(f.Days), len(hours.Observations), len(hours.Forecasts))
+		}
+
+		// Hourly temperatures stay within the forecast range of their day.
+		days := make(map[string]metservice.ForecastDay)
+		for _, d := range f.Days {
+			days[d.Date.Format("2006-01-02")] = d
+			if *d.Min > *d.Max {
+				t.Errorf("seed %d: %s min %d above max %d", seed, d.Date, *d.Min, *d.Max)
+			}
+		}
+		for _, h := range hours.Forecasts {
+			d, ok := days[h.Date.Format("2006-01-02")]
+			if !ok {
+				t.Fatalf("seed %d: no forecast day for %s", seed, h.Date)
+			}
+			if *h.Temp < *d.Min || *h.Temp > *d.Max {
+				t.Errorf("seed %d: %s temperature %d outside %d to %d", seed, h.Date, *h.Temp, *d.Min, *d.Max)
+			}
+			if *h.Humidity < 0 || *h.Humidity > 100 {
+				t.Errorf("seed %d: %s humidity %d", seed, h.Date, *h.Humidity)
+			}
+			if *h.Rainfall > 0 && *h.Humidity < 90 {
+				t.Errorf("seed %d: %s is raining at %d%% humidity", seed, h.Date, *h.Humidity)
+			}
+		}
+
+		// The observation matches the latest hour and adds up the rain.
+		latest := hours.Observations[len(hours.Observations)-1]
+		if !obs.ThreeHour.Date.Equal(*latest.Date) {
+			t.Errorf("seed %d: observation at %s, latest hour %s", seed, obs.ThreeHour.Date, latest.Date)
+		}
+		if want := int(math.Round(*latest.Temp)); *obs.ThreeHour.Temp != want {
+			t.Errorf("seed %d: observed temperature %d, latest hour %d", seed, *obs.ThreeHour.Temp, want)
+		}
+		var rain float64
+		for _, h := range hours.Observations {
+			rain += *h.Rainfall
+		}
+		if math.Abs(rain-*obs.TwentyFourHour.Rainfall) > 0.05 || math.Abs(rain-*hours.RainfallTotalObserved) > 0.05 {
+			t.Errorf("seed %d: hours add up to %.1f mm, observation has %.1f and total %.1f", seed,
+				rain, *obs.TwentyFourHour.Rainfall, *hours.RainfallTotalObserved)
+		}
+		if *obs.TwentyFourHour.Min > *obs.ThreeHour.Temp || *obs.TwentyFourHour.Max < *obs.ThreeHour.Temp {
+			t.Errorf("seed %d: temperature %d outside the last 24 hours' %d to %d", seed,
+				*obs.ThreeHour.Temp, *obs.TwentyFourHour.Min, *obs.TwentyFourHour.Max)
+		}
+	}
+}
+
+func TestGenerator_Pollen(t *testing.T) {
+	g := generator(t, 1)
+	p := g.Pollen(referenceTime)
+	if len(p.PollenDays) != 2 {
+		t.Fatalf("got %d pollen days, want 2", len(p.PollenDays))
+	}
+	for i, d := range p.PollenDays {
+		if _, ok := metservice.PollenLevel(*d.Level); !ok {
+			t.Errorf("day %d has level %q", i, *d.Level)
+		}
+		if *d.Type != "Grass" {
+			t.Errorf("day %d has type %q in January, want Grass", i, *d.Type)
+		}
+		want := time.Date(2006, time.January, 2+i, 0, 0, 0, 0, nzdt)
+		if !d.ValidFrom.Time.Equal(want) || !d.ValidTo.Time.Equal(want.AddDate(0, 0, 1)) {
+			t.Errorf("day %d valid from %s to %s", i, d.ValidFrom, d.ValidTo)
+		}
+	}
+}
+
+func TestGenerator_zero(t *testing.T) {
+	var g Generator
+	f := g.Forecast(referenceTime)
+	if len(f.Days) != ForecastDays || f.Days[0].RiseSet.SunRise == nil {
+		t.Errorf("zero Generator gave %+v", f)
+	}
+}
@@ -0,0 +1,394 @@
+// tabular writes metservice responses as CSV or TSV for spreadsheets and
+// reads them back again.
+//
+// Columns are written in a fixed order. Columns holding a measurement have
+// their unit in the header, such as temp_c or rainfall_in, so readers convert
+// back to the API's units (°C, km/h and mm) whatever units a file was
+// written in, and don't depend on column order. Nil values are written as
+// empty cells and read back as nil.
+//
+// Values round trip exactly in the API's units. Converted values come back
+// within floating point error, with integer values rounded.
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// TempUnit is a temperature unit.
+type TempUnit string
+
+// Temperature units.
+const (
+	Celsius    TempUnit = "c"
+	Fahrenheit TempUnit = "f"
+)
+
+// WindUnit is a wind speed unit.
+type WindUnit string
+
+// Wind speed units.
+const (
+	KMH   WindUnit = "kmh"
+	MPH   WindUnit = "mph"
+	MS    WindUnit = "ms"
+	Knots WindUnit = "kt"
+)
+
+// RainUnit is a rainfall unit.
+type RainUnit string
+
+// Rainfall units.
+const (
+	Millimetres RainUnit = "mm"
+	Inches      RainUnit = "in"
+)
+
+// Options controls how values are written and read. The zero value writes
+// CSV in the API's units with RFC 3339 times.
+type Options struct {
+	// Comma is the field separator. Use '\t' for TSV.
+	Comma rune
+	// Units for written values. Readers take the units from the header.
+	Temp TempUnit
+	Wind WindUnit
+	Rain RainUnit
+	// TimeFormat is the time.Format layout for times, defaulting to
+	// time.RFC3339.
+	TimeFormat string
+	// Location is the zone times are written in, and is used to read times
+	// written without a zone. Without one times are written in their own
+	// zone and read in UTC.
+	Location *time.Location
+}
+
+func (o Options) withDefaults() (Options, error) {
+	if o.Comma == 0 {
+		o.Comma = ','
+	}
+	if o.Temp == "" {
+		o.Temp = Celsius
+	}
+	if o.Wind == "" {
+		o.Wind = KMH
+	}
+	if o.Rain == "" {
+		o.Rain = Millimetres
+	}
+	if o.TimeFormat == "" {
+		o.TimeFormat = time.RFC3339
+	}
+	switch o.Temp {
+	case Celsius, Fahrenheit:
+	default:
+		return o, fmt.Errorf("unknown temperature unit %q", o.Temp)
+	}
+	switch o.Wind {
+	case KMH, MPH, MS, Knots:
+	default:
+		return o, fmt.Errorf("unknown wind unit %q", o.Wind)
+	}
+	switch o.Rain {
+	case Millimetres, Inches:
+	default:
+		return o, fmt.Errorf("unknown rain unit %q", o.Rain)
+	}
+	return o, nil
+}
+
+// toBase converts a value in each unit to the API's unit.
+var toBase = map[string]func(float64) float64{
+	"c":   func(v float64) float64 { return v },
+	"f":   func(v float64) float64 { return (v - 32) * 5 / 9 },
+	"kmh": func(v float64) float64 { return v },
+	"mph": func(v float64) float64 { return v * 1.609344 },
+	"ms":  func(v float64) float64 { return v * 3.6 },
+	"kt":  func(v float64) float64 { return v * 1.852 },
+	"mm":  func(v float64) float64 { return v },
+	"in":  func(v float64) float64 { return v * 25.4 },
+}
+
+// fromBase converts a value in the API's unit to each unit.
+var fromBase = map[string]func(float64) float64{
+	"c":   func(v float64) float64 { return v },
+	"f":   func(v float64) float64 { return v*9/5 + 32 },
+	"kmh": func(v float64) float64 { return v },
+	"mph": func(v float64) float64 { return v / 1.609344 },
+	"ms":  func(v float64) float64 { return v / 3.6 },
+	"kt":  func(v float64) float64 { return v / 1.852 },
+	"mm":  func(v float64) float64 { return v },
+	"in":  func(v float64) float64 { return v / 25.4 },
+}
+
+// writer builds rows of cells.
+type writer struct {
+	opts Options
+	cw   *csv.Writer
+	row  []string
+}
+
+func newWriter(w io.Writer, opts Options) (*writer, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.Comma
+	return &writer{opts: opts, cw: cw}, nil
+}
+
+func (w *writer) header(cols ...string) {
+	w.cw.Write(cols)
+}
+
+func (w *writer) end() {
+	w.cw.Write(w.row)
+	w.row = w.row[:0]
+}
+
+func (w *writer) flush() error {
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+func (w *writer) str(s *string) {
+	if s == nil {
+		w.row = append(w.row, "")
+		return
+	}
+	w.row = append(w.row, *s)
+}
+
+func (w *writer) integer(i *int) {
+	if i == nil {
+		w.row = append(w.row, "")
+		return
+	}
+	w.row = append(w.row, strconv.Itoa(*i))
+}
+
+func (w *writer) time(t *metservice.Timestamp) {
+	if t == nil {
+		w.row = append(w.row, "")
+		return
+	}
+	tt := t.Time
+	if w.opts.Location != nil {
+		tt = tt.In(w.opts.Location)
+	}
+	w.row = append(w.row, tt.Format(w.opts.TimeFormat))
+}
+
+func (w *writer) boolean(b *bool) {
+	if b == nil {
+		w.row = append(w.row, "")
+		return
+	}
+	w.row = append(w.row, strconv.FormatBool(*b))
+}
+
+func (w *writer) float(v *float64, unit string) {
+	if v == nil {
+		w.row = append(w.row, "")
+		return
+	}
+	w.row = append(w.row, strconv.FormatFloat(fromBase[unit](*v), 'f', -1, 64))
+}
+
+func (w *writer) intUnit(v *int, unit string) {
+	if v == nil {
+		w.row = append(w.row, "")
+		return
+	}
+	f := float64(*v)
+	w.float(&f, unit)
+}
+
+// unit returns the header for a column holding a measurement.
+func unit(name string, u interface{}) string {
+	return fmt.Sprintf("%s_%s", name, u)
+}
+
+// reader reads rows of cells, looking columns up by header.
+type reader struct {
+	opts Options
+	cr   *csv.Reader
+	cols map[string]int
+	// units holds the unit of each measurement column by its name without
+	// the unit.
+	units map[string]string
+	rec   []string
+	row   int
+	err   error
+}
+
+func newReader(r io.Reader, opts Options) (*reader, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(r)
+	cr.Comma = opts.Comma
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("missing header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	rd := &reader{
+		opts:  opts,
+		cr:    cr,
+		cols:  make(map[string]int),
+		units: make(map[string]string),
+		row:   1,
+	}
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		rd.cols[h] = i
+		if j := strings.LastIndex(h, "_"); j > 0 {
+			if _, ok := toBase[h[j+1:]]; ok {
+				rd.units[h[:j]] = h[j+1:]
+			}
+		}
+	}
+	return rd, nil
+}
+
+// next reads the next row, returning false at the end of the input or on
+// an error.
+func (r *reader) next() bool {
+	if r.err != nil {
+		return false
+	}
+	rec, err := r.cr.Read()
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		r.err = err
+		return false
+	}
+	r.rec = rec
+	r.row++
+	return true
+}
+
+func (r *reader) cell(name string) string {
+	i, ok := r.cols[name]
+	if !ok || i >= len(r.rec) {
+		return ""
+	}
+	return strings.TrimSpace(r.rec[i])
+}
+
+func (r *reader) fail(name, msg string) {
+	if r.err == nil {
+		r.err = ParseError{Row: r.row, Column: name, Msg: msg}
+	}
+}
+
+// str reads a text column. Unlike other cells it isn't trimmed.
+func (r *reader) str(name string) *string {
+	i, ok := r.cols[name]
+	if !ok || i >= len(r.rec) || r.rec[i] == "" {
+		return nil
+	}
+	s := r.rec[i]
+	return &s
+}
+
+func (r *reader) integer(name string) *int {
+	s := r.cell(name)
+	if s == "" {
+		return nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		r.fail(name, "bad integer "+strconv.Quote(s))
+		return nil
+	}
+	return &i
+}
+
+func (r *reader) time(name string) *metservice.Timestamp {
+	s := r.cell(name)
+	if s == "" {
+		return nil
+	}
+	loc := r.opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(r.opts.TimeFormat, s, loc)
+	if err != nil {
+		r.fail(name, "bad time "+strconv.Quote(s))
+		return nil
+	}
+	return &metservice.Timestamp{Time: t}
+}
+
+func (r *reader) boolean(name string) *bool {
+	s := r.cell(name)
+	if s == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		r.fail(name, "bad boolean "+strconv.Quote(s))
+		return nil
+	}
+	return &b
+}
+
+// float reads the measurement column name, in whatever unit its header
+// gives, converted to the API's unit.
+func (r *reader) float(name string) *float64 {
+	u, ok := r.units[name]
+	if !ok {
+		return nil
+	}
+	s := r.cell(unit(name, u))
+	if s == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		r.fail(unit(name, u), "bad number "+strconv.Quote(s))
+		return nil
+	}
+	v = toBase[u](v)
+	return &v
+}
+
+func (r *reader) intUnit(name string) *int {
+	v := r.float(name)
+	if v == nil {
+		return nil
+	}
+	i := int(math.Round(*v))
+	return &i
+}
+
+// ParseError is returned by the readers for a cell that can't be parsed.
+// Row counts records from 1 for the header.
+type ParseError struct {
+	Row    int
+	Column string
+	Msg    string
+}
+
+var _ error = ParseError{}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("row %d, column %s: %s", e.Row, e.Column, e.Msg)
+}
@@ -0,0 +1,42 @@
+package tabular
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOptions_BadUnit(t *testing.T) {
+	for _, opts := range []Options{{Temp: "k"}, {Wind: "bft"}, {Rain: "cm"}} {
+		var b bytes.Buffer
+		if err := WritePollenDays(&b, nil, opts); err == nil {
+			t.Errorf("WritePollenDays with %+v returned nil error", opts)
+		}
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	testCases := []struct {
+		desc string
+		in   string
+		want string
+	}{
+		{"Empty", "", "missing header"},
+		{"Bad time", "day,valid_from\nToday,tuesday\n", `row 2, column valid_from: bad time "tuesday"`},
+	}
+	for _, tc := range testCases {
+		_, err := ReadPollenDays(strings.NewReader(tc.in), Options{})
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%s: got error %v, want %s", tc.desc, err, tc.want)
+		}
+	}
+
+	_, err := ReadHours(strings.NewReader("time,source,temp_c\n,observed,1\n,forecast,x\n"), Options{})
+	if err == nil || err.Error() != `row 3, column temp_c: bad number "x"` {
+		t.Errorf("ReadHours got error %v", err)
+	}
+	_, err = ReadHours(strings.NewReader("source\nmodel\n"), Options{})
+	if _, ok := err.(ParseError); !ok {
+		t.Errorf("ReadHours with a bad source got error %v, want ParseError", err)
+	}
+}
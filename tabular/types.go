@@ -0,0 +1,395 @@
+package tabular
+
+import (
+	"fmt"
+	"io"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Values of the source column written by WriteHours.
+const (
+	SourceObserved = "observed"
+	SourceForecast = "forecast"
+)
+
+// WriteForecastDays writes one row per day. The days' RiseSet isn't written;
+// use WriteRiseSet for that.
+func WriteForecastDays(w io.Writer, days []metservice.ForecastDay, opts Options) error {
+	tw, err := newWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	t := string(tw.opts.Temp)
+	tw.header("date", "issued_at", "forecast_word", "forecast", unit("min", t), unit("max", t),
+		"morning_word", "morning_icon", "afternoon_word", "afternoon_icon",
+		"evening_word", "evening_icon", "overnight_word", "overnight_icon",
+		"source", "source_temps")
+	for _, d := range days {
+		tw.time(d.Date)
+		tw.time(d.IssuedAt)
+		tw.str(d.ForecastWord)
+		tw.str(d.Forecast)
+		tw.intUnit(d.Min, t)
+		tw.intUnit(d.Max, t)
+		var parts [4]*metservice.DayPartTime
+		if p := d.Part; p != nil {
+			parts = [4]*metservice.DayPartTime{p.Morning, p.Afternoon, p.Evening, p.Overnight}
+		}
+		for _, p := range parts {
+			if p == nil {
+				p = &metservice.DayPartTime{}
+			}
+			tw.str(p.ForecastWord)
+			tw.str(p.IconType)
+		}
+		tw.str(d.Source)
+		tw.str(d.SourceTemps)
+		tw.end()
+	}
+	return tw.flush()
+}
+
+// ReadForecastDays reads days written by WriteForecastDays.
+func ReadForecastDays(r io.Reader, opts Options) ([]metservice.ForecastDay, error) {
+	tr, err := newReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	var days []metservice.ForecastDay
+	for tr.next() {
+		d := metservice.ForecastDay{
+			Date:         tr.time("date"),
+			IssuedAt:     tr.time("issued_at"),
+			ForecastWord: tr.str("forecast_word"),
+			Forecast:     tr.str("forecast"),
+			Min:          tr.intUnit("min"),
+			Max:          tr.intUnit("max"),
+			Source:       tr.str("source"),
+			SourceTemps:  tr.str("source_temps"),
+		}
+		part := func(name string) *metservice.DayPartTime {
+			p := &metservice.DayPartTime{
+				ForecastWord: tr.str(name + "_word"),
+				IconType:     tr.str(name + "_icon"),
+			}
+			if p.ForecastWord == nil && p.IconType == nil {
+				return nil
+			}
+			return p
+		}
+		p := &metservice.DayPart{
+			Morning:   part("morning"),
+			Afternoon: part("afternoon"),
+			Evening:   part("evening"),
+			Overnight: part("overnight"),
+		}
+		if p.Morning != nil || p.Afternoon != nil || p.Evening != nil || p.Overnight != nil {
+			d.Part = p
+		}
+		days = append(days, d)
+	}
+	return days, tr.err
+}
+
+// WriteHours writes one row per observation followed by one per forecast,
+// told apart by the source column.
+func WriteHours(w io.Writer, ofh *metservice.ObservationForecastHours, opts Options) error {
+	tw, err := newWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	t, ws, r := string(tw.opts.Temp), string(tw.opts.Wind), string(tw.opts.Rain)
+	tw.header("time", "source", unit("temp", t), unit("rainfall", r),
+		"wind_direction", unit("wind_speed", ws), "humidity", "offset")
+	for _, h := range ofh.Observations {
+		tw.time(h.Date)
+		tw.str(metservice.String(SourceObserved))
+		tw.float(h.Temp, t)
+		tw.float(h.Rainfall, r)
+		tw.str(h.WindDirection)
+		tw.intUnit(h.WindSpeed, ws)
+		tw.integer(nil)
+		tw.integer(h.Offset)
+		tw.end()
+	}
+	for _, h := range ofh.Forecasts {
+		tw.time(h.Date)
+		tw.str(metservice.String(SourceForecast))
+		tw.intUnit(h.Temp, t)
+		tw.float(h.Rainfall, r)
+		tw.str(h.WindDirection)
+		tw.intUnit(h.WindSpeed, ws)
+		tw.integer(h.Humidity)
+		tw.integer(h.Offset)
+		tw.end()
+	}
+	return tw.flush()
+}
+
+// ReadHours reads hours written by WriteHours. Only the observations and
+// forecasts are filled in.
+func ReadHours(r io.Reader, opts Options) (*metservice.ObservationForecastHours, error) {
+	tr, err := newReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	ofh := new(metservice.ObservationForecastHours)
+	for tr.next() {
+		switch source := tr.cell("source"); source {
+		case SourceObserved:
+			ofh.Observations = append(ofh.Observations, metservice.ObservationHour{
+				Date:          tr.time("time"),
+				Offset:        tr.integer("offset"),
+				Rainfall:      tr.float("rainfall"),
+				Temp:          tr.float("temp"),
+				WindDirection: tr.str("wind_direction"),
+				WindSpeed:     tr.intUnit("wind_speed"),
+			})
+		case SourceForecast:
+			ofh.Forecasts = append(ofh.Forecasts, metservice.ForecastHour{
+				Date:          tr.time("time"),
+				Humidity:      tr.integer("humidity"),
+				Offset:        tr.integer("offset"),
+				Rainfall:      tr.float("rainfall"),
+				Temp:          tr.intUnit("temp"),
+				WindDirection: tr.str("wind_direction"),
+				WindSpeed:     tr.intUnit("wind_speed"),
+			})
+		default:
+			tr.fail("source", fmt.Sprintf("want %s or %s, got %q", SourceObserved, SourceForecast, source))
+		}
+	}
+	return ofh, tr.err
+}
+
+// WritePollenDays writes one row per day.
+func WritePollenDays(w io.Writer, days []metservice.PollenDay, opts Options) error {
+	tw, err := newWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	tw.header("day", "valid_from", "valid_to", "type", "level")
+	for _, d := range days {
+		tw.str(d.DayDescriptor)
+		tw.time(d.ValidFrom)
+		tw.time(d.ValidTo)
+		tw.str(d.Type)
+		tw.str(d.Level)
+		tw.end()
+	}
+	return tw.flush()
+}
+
+// ReadPollenDays reads days written by WritePollenDays.
+func ReadPollenDays(r io.Reader, opts Options) ([]metservice.PollenDay, error) {
+	tr, err := newReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	var days []metservice.PollenDay
+	for tr.next() {
+		days = append(days, metservice.PollenDay{
+			DayDescriptor: tr.str("day"),
+			ValidFrom:     tr.time("valid_from"),
+			ValidTo:       tr.time("valid_to"),
+			Type:          tr.str("type"),
+			Level:         tr.str("level"),
+		})
+	}
+	return days, tr.err
+}
+
+// WriteRiseSet writes the rise and set times for each of sets, one row each.
+// A nil set is an error.
+func WriteRiseSet(w io.Writer, opts Options, sets ...*metservice.RiseSet) error {
+	for i, s := range sets {
+		if s == nil {
+			return fmt.Errorf("rise and set times %d are nil", i)
+		}
+	}
+	tw, err := newWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	tw.header("date", "location", "first_light", "sunrise", "sunset", "last_light",
+		"moonrise", "moonset", "id")
+	for _, s := range sets {
+		tw.time(s.Date)
+		tw.str(s.Location)
+		tw.time(s.FirstLight)
+		tw.time(s.SunRise)
+		tw.time(s.SunSet)
+		tw.time(s.LastLight)
+		tw.time(s.MoonRise)
+		tw.time(s.MoonSet)
+		tw.str(s.ID)
+		tw.end()
+	}
+	return tw.flush()
+}
+
+// ReadRiseSet reads the rows written by WriteRiseSet.
+func ReadRiseSet(r io.Reader, opts Options) ([]*metservice.RiseSet, error) {
+	tr, err := newReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	var sets []*metservice.RiseSet
+	for tr.next() {
+		sets = append(sets, &metservice.RiseSet{
+			Date:       tr.time("date"),
+			Location:   tr.str("location"),
+			FirstLight: tr.time("first_light"),
+			SunRise:    tr.time("sunrise"),
+			SunSet:     tr.time("sunset"),
+			LastLight:  tr.time("last_light"),
+			MoonRise:   tr.time("moonrise"),
+			MoonSet:    tr.time("moonset"),
+			ID:         tr.str("id"),
+		})
+	}
+	return sets, tr.err
+}
+
+// WriteObservations writes each of obs, one row each, with both its three
+// hourly and daily readings. A nil observation is an error.
+func WriteObservations(w io.Writer, opts Options, obs ...*metservice.Observation) error {
+	for i, o := range obs {
+		if o == nil {
+			return fmt.Errorf("observation %d is nil", i)
+		}
+	}
+	tw, err := newWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	t, ws, r := string(tw.opts.Temp), string(tw.opts.Wind), string(tw.opts.Rain)
+	tw.header("time", "location", "location_id", "id", unit("temp", t), unit("wind_chill", t),
+		"humidity", "pressure", unit("rainfall", r), "wind_direction", unit("wind_speed", ws),
+		"clothing_layers", "wind_proof_layers",
+		"date_24h", unit("min_24h", t), unit("max_24h", t), unit("rainfall_24h", r))
+	for _, o := range obs {
+		h := o.ThreeHour
+		if h == nil {
+			h = &metservice.ObservationThreeHour{}
+		}
+		d := o.TwentyFourHour
+		if d == nil {
+			d = &metservice.ObservationTwentyFourHour{}
+		}
+		tw.time(h.Date)
+		tw.str(o.Location)
+		tw.integer(o.LocationID)
+		tw.str(o.ID)
+		tw.intUnit(h.Temp, t)
+		tw.intUnit(h.WindChill, t)
+		tw.integer(h.Humidity)
+		tw.str(h.Pressure)
+		tw.float(h.Rainfall, r)
+		tw.str(h.WindDirection)
+		tw.intUnit(h.WindSpeed, ws)
+		tw.str(h.ClothingLayers)
+		tw.integer(h.WindProofLayers)
+		tw.str(d.DatePretty)
+		tw.intUnit(d.Min, t)
+		tw.intUnit(d.Max, t)
+		tw.float(d.Rainfall, r)
+		tw.end()
+	}
+	return tw.flush()
+}
+
+// ReadObservations reads the rows written by WriteObservations. The three
+// hourly or daily readings are nil if none of their cells are set.
+func ReadObservations(r io.Reader, opts Options) ([]*metservice.Observation, error) {
+	tr, err := newReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	var obs []*metservice.Observation
+	for tr.next() {
+		o := &metservice.Observation{
+			ID:         tr.str("id"),
+			Location:   tr.str("location"),
+			LocationID: tr.integer("location_id"),
+		}
+		h := metservice.ObservationThreeHour{
+			ClothingLayers:  tr.str("clothing_layers"),
+			Date:            tr.time("time"),
+			Humidity:        tr.integer("humidity"),
+			Pressure:        tr.str("pressure"),
+			Rainfall:        tr.float("rainfall"),
+			Temp:            tr.intUnit("temp"),
+			WindChill:       tr.intUnit("wind_chill"),
+			WindDirection:   tr.str("wind_direction"),
+			WindProofLayers: tr.integer("wind_proof_layers"),
+			WindSpeed:       tr.intUnit("wind_speed"),
+		}
+		if h != (metservice.ObservationThreeHour{}) {
+			o.ThreeHour = &h
+		}
+		d := metservice.ObservationTwentyFourHour{
+			DatePretty: tr.str("date_24h"),
+			Max:        tr.intUnit("max_24h"),
+			Min:        tr.intUnit("min_24h"),
+			Rainfall:   tr.float("rainfall_24h"),
+		}
+		if d != (metservice.ObservationTwentyFourHour{}) {
+			o.TwentyFourHour = &d
+		}
+		obs = append(obs, o)
+	}
+	return obs, tr.err
+}
+
+// WriteObservationOneMin writes each of obs, one row each. A nil
+// observation is an error.
+func WriteObservationOneMin(w io.Writer, opts Options, obs ...*metservice.ObservationOneMin) error {
+	for i, o := range obs {
+		if o == nil {
+			return fmt.Errorf("observation %d is nil", i)
+		}
+	}
+	tw, err := newWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	r := string(tw.opts.Rain)
+	tw.header("time", "current", "status", "past", unit("rainfall", r),
+		"relative_humidity", "clothing_layers", "wind_proof_layers")
+	for _, o := range obs {
+		tw.time(o.Date)
+		tw.boolean(o.Current)
+		tw.str(o.Status)
+		tw.str(o.Past)
+		tw.float(o.Rainfall, r)
+		tw.integer(o.RelativeHumidity)
+		tw.str(o.ClothingLayers)
+		tw.integer(o.WindProofLayers)
+		tw.end()
+	}
+	return tw.flush()
+}
+
+// ReadObservationOneMin reads the rows written by WriteObservationOneMin.
+func ReadObservationOneMin(r io.Reader, opts Options) ([]*metservice.ObservationOneMin, error) {
+	tr, err := newReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	var obs []*metservice.ObservationOneMin
+	for tr.next() {
+		obs = append(obs, &metservice.ObservationOneMin{
+			ClothingLayers:   tr.str("clothing_layers"),
+			Current:          tr.boolean("current"),
+			Past:             tr.str("past"),
+			Rainfall:         tr.float("rainfall"),
+			RelativeHumidity: tr.integer("relative_humidity"),
+			Status:           tr.str("status"),
+			Date:             tr.time("time"),
+			WindProofLayers:  tr.integer("wind_proof_layers"),
+		})
+	}
+	return obs, tr.err
+}
@@ -0,0 +1,253 @@
+package tabular
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var nz = time.FixedZone("", 13*60*60)
+
+func ts(hour int) *metservice.Timestamp {
+	return &metservice.Timestamp{Time: time.Date(2006, time.January, 2, hour, 0, 0, 0, nz)}
+}
+
+func TestForecastDays_RoundTrip(t *testing.T) {
+	days := []metservice.ForecastDay{
+		{
+			Date:         ts(0),
+			IssuedAt:     ts(5),
+			ForecastWord: metservice.String("Showers"),
+			Forecast:     metservice.String("Showers, some heavy, \"mostly\" in the west."),
+			Max:          metservice.Int(14),
+			Min:          metservice.Int(-2),
+			Part: &metservice.DayPart{
+				Morning:   &metservice.DayPartTime{ForecastWord: metservice.String("Fine"), IconType: metservice.String("fine")},
+				Overnight: &metservice.DayPartTime{IconType: metservice.String("showers")},
+			},
+			Source: metservice.String("ips"),
+		},
+		{Date: ts(24)},
+	}
+
+	var b bytes.Buffer
+	if err := WriteForecastDays(&b, days, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "date,issued_at,forecast_word,forecast,min_c,max_c,morning_word,morning_icon,afternoon_word,afternoon_icon,evening_word,evening_icon,overnight_word,overnight_icon,source,source_temps\n" +
+		`2006-01-02T00:00:00+13:00,2006-01-02T05:00:00+13:00,Showers,"Showers, some heavy, ""mostly"" in the west.",-2,14,Fine,fine,,,,,,showers,ips,` + "\n" +
+		"2006-01-03T00:00:00+13:00,,,,,,,,,,,,,,,\n"
+	if got := b.String(); got != wantCSV {
+		t.Errorf("WriteForecastDays wrote\n%s\nwant\n%s", got, wantCSV)
+	}
+
+	got, err := ReadForecastDays(&b, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(days, got); diff != "" {
+		t.Errorf("ReadForecastDays mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHours_RoundTrip(t *testing.T) {
+	ofh := &metservice.ObservationForecastHours{
+		Observations: []metservice.ObservationHour{
+			{Date: ts(15), Temp: metservice.Float64(10.5), Rainfall: metservice.Float64(12.7), WindSpeed: metservice.Int(36), WindDirection: metservice.String("NW"), Offset: metservice.Int(-1)},
+		},
+		Forecasts: []metservice.ForecastHour{
+			{Date: ts(16), Temp: metservice.Int(12), Rainfall: metservice.Float64(25.4), Humidity: metservice.Int(80)},
+		},
+	}
+
+	testCases := []struct {
+		desc string
+		opts Options
+		want string
+	}{
+		{
+			"CSV",
+			Options{},
+			"time,source,temp_c,rainfall_mm,wind_direction,wind_speed_kmh,humidity,offset\n" +
+				"2006-01-02T15:00:00+13:00,observed,10.5,12.7,NW,36,,-1\n" +
+				"2006-01-02T16:00:00+13:00,forecast,12,25.4,,,80,\n",
+		},
+		{
+			"TSV with units",
+			Options{Comma: '\t', Temp: Fahrenheit, Wind: MS, Rain: Inches, TimeFormat: "2006-01-02 15:04", Location: nz},
+			"time\tsource\ttemp_f\trainfall_in\twind_direction\twind_speed_ms\thumidity\toffset\n" +
+				"2006-01-02 15:00\tobserved\t50.9\t0.5\tNW\t10\t\t-1\n" +
+				"2006-01-02 16:00\tforecast\t53.6\t1\t\t\t80\t\n",
+		},
+	}
+	for _, tc := range testCases {
+		var b bytes.Buffer
+		if err := WriteHours(&b, ofh, tc.opts); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if got := b.String(); got != tc.want {
+			t.Errorf("%s: WriteHours wrote\n%s\nwant\n%s", tc.desc, got, tc.want)
+		}
+		got, err := ReadHours(&b, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if diff := cmp.Diff(ofh, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+			t.Errorf("%s: ReadHours mismatch (-want +got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestReadHours_Reordered(t *testing.T) {
+	// Columns may be in any order and units are taken from the header.
+	in := "wind_speed_kt,source,temp_f,time\n10,forecast,32,2006-01-02T16:00:00+13:00\n"
+	got, err := ReadHours(strings.NewReader(in), Options{Temp: Celsius})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &metservice.ObservationForecastHours{
+		Forecasts: []metservice.ForecastHour{{Date: ts(16), Temp: metservice.Int(0), WindSpeed: metservice.Int(19)}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadHours mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPollenDays_RoundTrip(t *testing.T) {
+	days := []metservice.PollenDay{
+		{DayDescriptor: metservice.String("Today"), Level: metservice.String("High"), Type: metservice.String("Grass"), ValidFrom: ts(0), ValidTo: ts(24)},
+		{DayDescriptor: metservice.String("Tomorrow")},
+	}
+	var b bytes.Buffer
+	if err := WritePollenDays(&b, days, Options{Comma: '\t'}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadPollenDays(&b, Options{Comma: '\t'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(days, got); diff != "" {
+		t.Errorf("ReadPollenDays mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRiseSet_RoundTrip(t *testing.T) {
+	sets := []*metservice.RiseSet{
+		{Date: ts(0), Location: metservice.String("Dunedin"), FirstLight: ts(5), SunRise: ts(6), SunSet: ts(21), LastLight: ts(22), MoonRise: ts(23)},
+		{Date: ts(24), Location: metservice.String("Christchurch")},
+	}
+	var b bytes.Buffer
+	if err := WriteRiseSet(&b, Options{}, sets...); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadRiseSet(&b, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(sets, got); diff != "" {
+		t.Errorf("ReadRiseSet mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRiseSet_Nil(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteRiseSet(&b, Options{}, &metservice.RiseSet{}, nil); err == nil {
+		t.Error("WriteRiseSet with a nil set succeeded")
+	}
+}
+
+func TestLocation_RoundTrip(t *testing.T) {
+	// Times are written in Location, so a zone-less layout reads back the
+	// same instant.
+	utc := []metservice.PollenDay{{ValidFrom: &metservice.Timestamp{Time: ts(15).UTC()}}}
+	opts := Options{TimeFormat: "2006-01-02 15:04", Location: nz}
+	var b bytes.Buffer
+	if err := WritePollenDays(&b, utc, opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := "day,valid_from,valid_to,type,level\n,2006-01-02 15:00,,,\n"; b.String() != want {
+		t.Errorf("WritePollenDays wrote\n%s\nwant\n%s", b.String(), want)
+	}
+	got, err := ReadPollenDays(&b, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(utc, got); diff != "" {
+		t.Errorf("ReadPollenDays mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestObservations_RoundTrip(t *testing.T) {
+	obs := []*metservice.Observation{
+		{
+			ID:         metservice.String("93110"),
+			Location:   metservice.String("Dunedin"),
+			LocationID: metservice.Int(93110),
+			ThreeHour: &metservice.ObservationThreeHour{
+				ClothingLayers:  metservice.String("2"),
+				Date:            ts(15),
+				Humidity:        metservice.Int(70),
+				Pressure:        metservice.String("Rising"),
+				Rainfall:        metservice.Float64(1.2),
+				Temp:            metservice.Int(12),
+				WindChill:       metservice.Int(9),
+				WindDirection:   metservice.String("SW"),
+				WindProofLayers: metservice.Int(1),
+				WindSpeed:       metservice.Int(20),
+			},
+			TwentyFourHour: &metservice.ObservationTwentyFourHour{
+				DatePretty: metservice.String("Monday 2 Jan"),
+				Max:        metservice.Int(14),
+				Min:        metservice.Int(3),
+				Rainfall:   metservice.Float64(4.6),
+			},
+		},
+		{Location: metservice.String("Christchurch")},
+	}
+	var b bytes.Buffer
+	if err := WriteObservations(&b, Options{}, obs...); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadObservations(&b, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(obs, got); diff != "" {
+		t.Errorf("ReadObservations mismatch (-want +got):\n%s", diff)
+	}
+	if err := WriteObservations(&b, Options{}, nil); err == nil {
+		t.Error("WriteObservations with a nil observation succeeded")
+	}
+}
+
+func TestObservationOneMin_RoundTrip(t *testing.T) {
+	obs := []*metservice.ObservationOneMin{
+		{
+			ClothingLayers:   metservice.String("3"),
+			Current:          metservice.Bool(true),
+			Past:             metservice.String("Cloudy"),
+			Rainfall:         metservice.Float64(0.2),
+			RelativeHumidity: metservice.Int(88),
+			Status:           metservice.String("ok"),
+			Date:             ts(15),
+			WindProofLayers:  metservice.Int(2),
+		},
+		{Date: ts(16)},
+	}
+	var b bytes.Buffer
+	if err := WriteObservationOneMin(&b, Options{Rain: Inches}, obs...); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadObservationOneMin(&b, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(obs, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("ReadObservationOneMin mismatch (-want +got):\n%s", diff)
+	}
+}
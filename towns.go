@@ -0,0 +1,103 @@
+package metservice
+
+import (
+	"math"
+	"strings"
+)
+
+// Town is a location known to the metservice API along with its
+// approximate coordinates.
+type Town struct {
+	// Name is the location as passed to the Get methods.
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+// Towns lists the main towns and cities, north to south. It isn't every
+// location the API knows about.
+var Towns = []Town{
+	{"Kaitaia", -35.11, 173.26},
+	{"Kerikeri", -35.23, 173.95},
+	{"Whangarei", -35.73, 174.32},
+	{"Auckland", -36.85, 174.76},
+	{"Thames", -37.14, 175.54},
+	{"Tauranga", -37.69, 176.17},
+	{"Hamilton", -37.79, 175.28},
+	{"Whakatane", -37.95, 176.99},
+	{"Rotorua", -38.14, 176.25},
+	{"Gisborne", -38.66, 178.02},
+	{"Taupo", -38.69, 176.07},
+	{"Taumarunui", -38.88, 175.26},
+	{"New Plymouth", -39.06, 174.08},
+	{"Napier", -39.49, 176.91},
+	{"Hastings", -39.64, 176.84},
+	{"Whanganui", -39.93, 175.05},
+	{"Palmerston North", -40.36, 175.61},
+	{"Masterton", -40.95, 175.66},
+	{"Motueka", -41.11, 173.01},
+	{"Nelson", -41.27, 173.28},
+	{"Wellington", -41.29, 174.78},
+	{"Blenheim", -41.51, 173.96},
+	{"Westport", -41.75, 171.60},
+	{"Kaikoura", -42.40, 173.68},
+	{"Greymouth", -42.45, 171.21},
+	{"Hokitika", -42.72, 170.97},
+	{"Christchurch", -43.53, 172.64},
+	{"Mount Cook", -43.73, 170.10},
+	{"Ashburton", -43.90, 171.75},
+	{"Lake Tekapo", -44.00, 170.48},
+	{"Timaru", -44.40, 171.25},
+	{"Milford Sound", -44.67, 167.93},
+	{"Wanaka", -44.70, 169.13},
+	{"Queenstown", -45.03, 168.66},
+	{"Oamaru", -45.10, 170.97},
+	{"Alexandra", -45.25, 169.38},
+	{"Dunedin", -45.87, 170.50},
+	{"Gore", -46.10, 168.94},
+	{"Invercargill", -46.41, 168.35},
+}
+
+// LookupTown finds a town in Towns by name, ignoring case, spaces and
+// hyphens.
+func LookupTown(name string) (Town, bool) {
+	key := townKey(name)
+	for _, t := range Towns {
+		if townKey(t.Name) == key {
+			return t, true
+		}
+	}
+	return Town{}, false
+}
+
+func townKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '_' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// NearestTown returns the town in Towns closest to the given coordinates and
+// its distance in kilometres.
+func NearestTown(latitude, longitude float64) (Town, float64) {
+	best, dist := Town{}, math.Inf(1)
+	for _, t := range Towns {
+		if d := Distance(latitude, longitude, t.Latitude, t.Longitude); d < dist {
+			best, dist = t, d
+		}
+	}
+	return best, dist
+}
+
+// Distance returns the great circle distance in kilometres between two
+// points.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371.0
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	dlat, dlon := rad(lat2-lat1), rad(lon2-lon1)
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,41 @@
+package metservice
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLookupTown(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"Dunedin", "Dunedin", true},
+		{"palmerston-north", "Palmerston North", true},
+		{"PalmerstonNorth", "Palmerston North", true},
+		{"Atlantis", "", false},
+	}
+	for _, tc := range testCases {
+		got, ok := LookupTown(tc.name)
+		if got.Name != tc.want || ok != tc.ok {
+			t.Errorf("LookupTown(%q) returned %q, %v, want %q, %v", tc.name, got.Name, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestNearestTown(t *testing.T) {
+	// Mosgiel is about 12km west of Dunedin.
+	got, dist := NearestTown(-45.88, 170.35)
+	if got.Name != "Dunedin" || dist < 5 || dist > 20 {
+		t.Errorf("NearestTown returned %s at %.1fkm, want Dunedin about 12km away", got.Name, dist)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// Auckland to Wellington is about 494km as the crow flies.
+	got := Distance(-36.85, 174.76, -41.29, 174.78)
+	if math.Abs(got-494) > 5 {
+		t.Errorf("Distance returned %.1fkm, want about 494km", got)
+	}
+}
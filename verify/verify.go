@@ -0,0 +1,363 @@
+// verify compares archived metservice forecasts with the observations that
+// followed them and reports how accurate they were.
+package verify
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+)
+
+// Kind is the forecast quantity being verified.
+type Kind string
+
+const (
+	// KindMax is the daily maximum temperature from a ForecastDay.
+	KindMax Kind = "max"
+	// KindMin is the daily minimum temperature from a ForecastDay.
+	KindMin Kind = "min"
+	// KindTemp is the hourly temperature from a ForecastHour.
+	KindTemp Kind = "temp"
+	// KindRain is the hourly rainfall from a ForecastHour.
+	KindRain Kind = "rain"
+)
+
+// DefaultRainThreshold is the rainfall in millimetres at or above which an
+// hour counts as wet.
+const DefaultRainThreshold = 0.2
+
+// DefaultMinDailyHours is the number of hourly observations a day needs
+// before its observed maximum and minimum are trusted.
+const DefaultMinDailyHours = 20
+
+// Key identifies a group of verified forecasts.
+type Key struct {
+	Location string
+	Kind     Kind
+	// Lead is how far ahead of the target time the forecast was issued.
+	// Daily forecasts use whole days and hourly forecasts whole hours.
+	Lead time.Duration
+}
+
+// Stats holds the verification scores for a Key. Errors are forecast minus
+// observed, so a positive Bias means the forecasts ran high.
+type Stats struct {
+	Key
+	Count int
+	Bias  float64
+	MAE   float64
+	RMSE  float64
+
+	// Rain/no-rain contingency table, only filled in for KindRain.
+	Hits             int
+	Misses           int
+	FalseAlarms      int
+	CorrectNegatives int
+}
+
+// HitRate returns the fraction of observed wet hours that were forecast as
+// wet, or NaN if no wet hours were observed.
+func (s Stats) HitRate() float64 {
+	return ratio(s.Hits, s.Hits+s.Misses)
+}
+
+// FalseAlarmRatio returns the fraction of wet forecasts that turned out dry,
+// or NaN if no wet hours were forecast.
+func (s Stats) FalseAlarmRatio() float64 {
+	return ratio(s.FalseAlarms, s.Hits+s.FalseAlarms)
+}
+
+// Accuracy returns the fraction of hours where rain or no rain was forecast
+// correctly, or NaN if there were none.
+func (s Stats) Accuracy() float64 {
+	return ratio(s.Hits+s.CorrectNegatives,
+		s.Hits+s.Misses+s.FalseAlarms+s.CorrectNegatives)
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return math.NaN()
+	}
+	return float64(a) / float64(b)
+}
+
+// Verifier collects forecasts and observations and pairs them up. The zero
+// value is not ready for use; create one with New.
+type Verifier struct {
+	// RainThreshold is the rainfall in millimetres at or above which an
+	// hour counts as wet.
+	RainThreshold float64
+	// MinDailyHours is the number of hourly observations a day needs before
+	// it is used to verify a daily maximum or minimum.
+	MinDailyHours int
+
+	days  map[forecastKey]metservice.ForecastDay
+	hours map[forecastKey]metservice.ForecastHour
+	obs   map[string]map[int64]observed
+}
+
+// forecastKey identifies a single forecast by where, when it is for and
+// when it was issued, all as unix seconds.
+type forecastKey struct {
+	location string
+	date     int64
+	issued   int64
+}
+
+type observed struct {
+	date time.Time
+	temp *float64
+	rain *float64
+}
+
+// New returns a Verifier using the default thresholds.
+func New() *Verifier {
+	return &Verifier{
+		RainThreshold: DefaultRainThreshold,
+		MinDailyHours: DefaultMinDailyHours,
+		days:          make(map[forecastKey]metservice.ForecastDay),
+		hours:         make(map[forecastKey]metservice.ForecastHour),
+		obs:           make(map[string]map[int64]observed),
+	}
+}
+
+// AddForecast adds the days of a Forecast for location. Days missing a date
+// or issue time are ignored. Adding the same day and issue time again
+// replaces the earlier copy.
+func (v *Verifier) AddForecast(location string, f *metservice.Forecast) {
+	if f == nil {
+		return
+	}
+	for _, day := range f.Days {
+		if day.Date == nil || day.IssuedAt == nil {
+			continue
+		}
+		k := forecastKey{location, day.Date.Unix(), day.IssuedAt.Unix()}
+		v.days[k] = day
+	}
+}
+
+// AddForecastHours adds both the hourly forecasts and observations from an
+// ObservationForecastHours for location. Since the hourly data has no issue
+// time, fetched should be the time the data was retrieved and is used to
+// work out the lead time of each forecast hour.
+func (v *Verifier) AddForecastHours(location string, fetched time.Time, ofh *metservice.ObservationForecastHours) {
+	if ofh == nil {
+		return
+	}
+	for _, hour := range ofh.Forecasts {
+		if hour.Date == nil {
+			continue
+		}
+		k := forecastKey{location, hour.Date.Unix(), fetched.Unix()}
+		v.hours[k] = hour
+	}
+	v.AddObservationHours(location, ofh.Observations)
+}
+
+// AddObservationHours adds hourly observations for location.
+func (v *Verifier) AddObservationHours(location string, hours []metservice.ObservationHour) {
+	for _, hour := range hours {
+		if hour.Date == nil {
+			continue
+		}
+		v.observe(location, observed{
+			date: hour.Date.Time,
+			temp: hour.Temp,
+			rain: hour.Rainfall,
+		})
+	}
+}
+
+// AddObservation adds the temperature of the three hourly reading from an
+// Observation for location. Its rainfall is left out as it covers three
+// hours rather than one, so can't be compared with hourly forecasts.
+func (v *Verifier) AddObservation(location string, o *metservice.Observation) {
+	if o == nil || o.ThreeHour == nil || o.ThreeHour.Date == nil || o.ThreeHour.Temp == nil {
+		return
+	}
+	v.observe(location, observed{
+		date: o.ThreeHour.Date.Time,
+		temp: metservice.Float64(float64(*o.ThreeHour.Temp)),
+	})
+}
+
+func (v *Verifier) observe(location string, o observed) {
+	m, ok := v.obs[location]
+	if !ok {
+		m = make(map[int64]observed)
+		v.obs[location] = m
+	}
+	prev, ok := m[o.date.Unix()]
+	if ok {
+		// Keep any values the earlier copy had that this one lacks.
+		if o.temp == nil {
+			o.temp = prev.temp
+		}
+		if o.rain == nil {
+			o.rain = prev.rain
+		}
+	}
+	m[o.date.Unix()] = o
+}
+
+// Verify pairs every forecast with its observation and returns the scores
+// grouped by location, kind and lead time. The result is sorted by location,
+// then kind, then lead time. Forecasts without a matching observation are
+// left out.
+func (v *Verifier) Verify() []Stats {
+	acc := make(map[Key]*accumulator)
+	get := func(k Key) *accumulator {
+		a, ok := acc[k]
+		if !ok {
+			a = &accumulator{stats: Stats{Key: k}}
+			acc[k] = a
+		}
+		return a
+	}
+
+	temps := v.temps()
+	for k, day := range v.days {
+		hi, lo, ok := v.dailyExtremes(temps[k.location], day.Date.Time)
+		if !ok {
+			continue
+		}
+		lead := leadDays(day.IssuedAt.Time, day.Date.Time)
+		if day.Max != nil {
+			get(Key{k.location, KindMax, lead}).add(float64(*day.Max), hi)
+		}
+		if day.Min != nil {
+			get(Key{k.location, KindMin, lead}).add(float64(*day.Min), lo)
+		}
+	}
+
+	for k, hour := range v.hours {
+		o, ok := v.obs[k.location][k.date]
+		if !ok {
+			continue
+		}
+		lead := time.Unix(k.date, 0).Sub(time.Unix(k.issued, 0)).Truncate(time.Hour)
+		if lead < 0 {
+			// The hour had already passed when it was fetched.
+			continue
+		}
+		if hour.Temp != nil && o.temp != nil {
+			get(Key{k.location, KindTemp, lead}).add(float64(*hour.Temp), *o.temp)
+		}
+		if hour.Rainfall != nil && o.rain != nil {
+			a := get(Key{k.location, KindRain, lead})
+			a.add(*hour.Rainfall, *o.rain)
+			a.contingency(*hour.Rainfall >= v.RainThreshold, *o.rain >= v.RainThreshold)
+		}
+	}
+
+	stats := make([]Stats, 0, len(acc))
+	for _, a := range acc {
+		stats = append(stats, a.result())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Lead < b.Lead
+	})
+	return stats
+}
+
+// temps returns the observations with a temperature for each location,
+// sorted by date.
+func (v *Verifier) temps() map[string][]observed {
+	temps := make(map[string][]observed, len(v.obs))
+	for location, m := range v.obs {
+		var obs []observed
+		for _, o := range m {
+			if o.temp != nil {
+				obs = append(obs, o)
+			}
+		}
+		sort.Slice(obs, func(i, j int) bool {
+			return obs[i].date.Before(obs[j].date)
+		})
+		temps[location] = obs
+	}
+	return temps
+}
+
+// dailyExtremes returns the highest and lowest temperature in obs, which is
+// sorted by date, on the calendar day containing date, in date's time zone.
+func (v *Verifier) dailyExtremes(obs []observed, date time.Time) (hi, lo float64, ok bool) {
+	y, m, d := date.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	end := start.AddDate(0, 0, 1)
+
+	i := sort.Search(len(obs), func(i int) bool {
+		return !obs[i].date.Before(start)
+	})
+	n := 0
+	for ; i < len(obs) && obs[i].date.Before(end); i++ {
+		temp := *obs[i].temp
+		if n == 0 || temp > hi {
+			hi = temp
+		}
+		if n == 0 || temp < lo {
+			lo = temp
+		}
+		n++
+	}
+	return hi, lo, n > 0 && n >= v.MinDailyHours
+}
+
+// leadDays returns the number of calendar days between issued and target as
+// a Duration, measured in target's time zone.
+func leadDays(issued, target time.Time) time.Duration {
+	iy, im, id := issued.In(target.Location()).Date()
+	ty, tm, td := target.Date()
+	from := time.Date(iy, im, id, 0, 0, 0, 0, time.UTC)
+	to := time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC)
+	return to.Sub(from)
+}
+
+type accumulator struct {
+	stats  Stats
+	sum    float64
+	sumAbs float64
+	sumSq  float64
+}
+
+func (a *accumulator) add(forecast, observed float64) {
+	e := forecast - observed
+	a.stats.Count++
+	a.sum += e
+	a.sumAbs += math.Abs(e)
+	a.sumSq += e * e
+}
+
+func (a *accumulator) contingency(forecastWet, observedWet bool) {
+	switch {
+	case forecastWet && observedWet:
+		a.stats.Hits++
+	case !forecastWet && observedWet:
+		a.stats.Misses++
+	case forecastWet && !observedWet:
+		a.stats.FalseAlarms++
+	default:
+		a.stats.CorrectNegatives++
+	}
+}
+
+func (a *accumulator) result() Stats {
+	s := a.stats
+	if s.Count > 0 {
+		n := float64(s.Count)
+		s.Bias = a.sum / n
+		s.MAE = a.sumAbs / n
+		s.RMSE = math.Sqrt(a.sumSq / n)
+	}
+	return s
+}
@@ -0,0 +1,189 @@
+package verify
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	metservice "git.sr.ht/~kota/metservice-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var referenceTime = time.Date(2006, time.January, 02, 0, 0, 0, 0, time.UTC)
+
+func ts(t time.Time) *metservice.Timestamp {
+	return &metservice.Timestamp{Time: t}
+}
+
+func TestVerify_Daily(t *testing.T) {
+	v := New()
+	v.MinDailyHours = 24
+
+	// Observed temperatures run from 10 to 33 over the day.
+	var obs []metservice.ObservationHour
+	for h := 0; h < 24; h++ {
+		obs = append(obs, metservice.ObservationHour{
+			Date: ts(referenceTime.Add(time.Duration(h) * time.Hour)),
+			Temp: metservice.Float64(float64(10 + h)),
+		})
+	}
+	v.AddObservationHours("Dunedin", obs)
+
+	v.AddForecast("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{
+			{
+				Date:     ts(referenceTime),
+				IssuedAt: ts(referenceTime.Add(-36 * time.Hour)),
+				Max:      metservice.Int(35),
+				Min:      metservice.Int(9),
+			},
+			{
+				// No observations for this day.
+				Date:     ts(referenceTime.AddDate(0, 0, 1)),
+				IssuedAt: ts(referenceTime.Add(-36 * time.Hour)),
+				Max:      metservice.Int(35),
+			},
+		},
+	})
+	v.AddForecast("Dunedin", &metservice.Forecast{
+		Days: []metservice.ForecastDay{
+			{
+				Date:     ts(referenceTime),
+				IssuedAt: ts(referenceTime.Add(6 * time.Hour)),
+				Max:      metservice.Int(32),
+				Min:      metservice.Int(10),
+			},
+		},
+	})
+
+	got := v.Verify()
+	want := []Stats{
+		{Key: Key{"Dunedin", KindMax, 0}, Count: 1, Bias: -1, MAE: 1, RMSE: 1},
+		{Key: Key{"Dunedin", KindMax, 48 * time.Hour}, Count: 1, Bias: 2, MAE: 2, RMSE: 2},
+		{Key: Key{"Dunedin", KindMin, 0}, Count: 1},
+		{Key: Key{"Dunedin", KindMin, 48 * time.Hour}, Count: 1, Bias: -1, MAE: 1, RMSE: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Verifier.Verify mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestVerify_Hourly(t *testing.T) {
+	v := New()
+	fetched := referenceTime
+
+	hour := func(h int, temp int, rain float64) metservice.ForecastHour {
+		return metservice.ForecastHour{
+			Date:     ts(referenceTime.Add(time.Duration(h) * time.Hour)),
+			Temp:     metservice.Int(temp),
+			Rainfall: metservice.Float64(rain),
+		}
+	}
+	v.AddForecastHours("Dunedin", fetched, &metservice.ObservationForecastHours{
+		Forecasts: []metservice.ForecastHour{
+			hour(1, 12, 1.0), // hit
+			hour(2, 14, 0.0), // miss
+			hour(3, 10, 0.5), // false alarm
+			hour(4, 10, 0.0), // correct negative
+			hour(5, 10, 0.0), // never observed
+		},
+	})
+	v.AddForecastHours("Dunedin", fetched.Add(4*time.Hour), &metservice.ObservationForecastHours{
+		Observations: []metservice.ObservationHour{
+			{Date: ts(referenceTime.Add(1 * time.Hour)), Temp: metservice.Float64(10), Rainfall: metservice.Float64(2)},
+			{Date: ts(referenceTime.Add(2 * time.Hour)), Temp: metservice.Float64(10), Rainfall: metservice.Float64(3)},
+			{Date: ts(referenceTime.Add(3 * time.Hour)), Temp: metservice.Float64(10), Rainfall: metservice.Float64(0)},
+			{Date: ts(referenceTime.Add(4 * time.Hour)), Temp: metservice.Float64(10), Rainfall: metservice.Float64(0)},
+		},
+	})
+
+	var temp, rain Stats
+	for _, s := range v.Verify() {
+		switch s.Kind {
+		case KindTemp:
+			temp.Count += s.Count
+			temp.Bias += s.Bias * float64(s.Count)
+		case KindRain:
+			rain.Hits += s.Hits
+			rain.Misses += s.Misses
+			rain.FalseAlarms += s.FalseAlarms
+			rain.CorrectNegatives += s.CorrectNegatives
+		}
+	}
+	if temp.Count != 4 {
+		t.Errorf("temp count = %v, want 4", temp.Count)
+	}
+	if temp.Bias != 6 {
+		t.Errorf("summed temp bias = %v, want 6", temp.Bias)
+	}
+	want := Stats{Hits: 1, Misses: 1, FalseAlarms: 1, CorrectNegatives: 1}
+	if !cmp.Equal(rain, want) {
+		t.Errorf("rain contingency = %+v, want %+v", rain, want)
+	}
+	if got := rain.HitRate(); got != 0.5 {
+		t.Errorf("HitRate = %v, want 0.5", got)
+	}
+	if got := rain.FalseAlarmRatio(); got != 0.5 {
+		t.Errorf("FalseAlarmRatio = %v, want 0.5", got)
+	}
+	if got := rain.Accuracy(); got != 0.5 {
+		t.Errorf("Accuracy = %v, want 0.5", got)
+	}
+}
+
+func TestVerify_Observation(t *testing.T) {
+	v := New()
+	v.AddForecastHours("Dunedin", referenceTime, &metservice.ObservationForecastHours{
+		Forecasts: []metservice.ForecastHour{
+			{Date: ts(referenceTime.Add(3 * time.Hour)), Temp: metservice.Int(12), Rainfall: metservice.Float64(0)},
+		},
+	})
+	// The three hour rainfall isn't an hourly amount, so isn't verified.
+	v.AddObservation("Dunedin", &metservice.Observation{
+		ThreeHour: &metservice.ObservationThreeHour{
+			Date:     ts(referenceTime.Add(3 * time.Hour)),
+			Temp:     metservice.Int(10),
+			Rainfall: metservice.Float64(6),
+		},
+	})
+	got := v.Verify()
+	want := []Stats{
+		{Key: Key{"Dunedin", KindTemp, 3 * time.Hour}, Count: 1, Bias: 2, MAE: 2, RMSE: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Verifier.Verify mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestVerify_RMSE(t *testing.T) {
+	v := New()
+	v.AddForecastHours("Dunedin", referenceTime, &metservice.ObservationForecastHours{
+		Forecasts: []metservice.ForecastHour{
+			{Date: ts(referenceTime.Add(time.Hour)), Temp: metservice.Int(13)},
+			{Date: ts(referenceTime.Add(time.Hour + 30*time.Minute)), Temp: metservice.Int(6)},
+		},
+		Observations: []metservice.ObservationHour{
+			{Date: ts(referenceTime.Add(time.Hour)), Temp: metservice.Float64(10)},
+			{Date: ts(referenceTime.Add(time.Hour + 30*time.Minute)), Temp: metservice.Float64(10)},
+		},
+	})
+	got := v.Verify()
+	want := []Stats{{
+		Key:   Key{"Dunedin", KindTemp, time.Hour},
+		Count: 2,
+		Bias:  -0.5,
+		MAE:   3.5,
+		RMSE:  math.Sqrt(12.5),
+	}}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Verifier.Verify mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestStats_Empty(t *testing.T) {
+	var s Stats
+	if !math.IsNaN(s.HitRate()) {
+		t.Errorf("HitRate of empty Stats = %v, want NaN", s.HitRate())
+	}
+}
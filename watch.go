@@ -0,0 +1,290 @@
+package metservice
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Endpoint names one of the API endpoints a Watcher can poll.
+type Endpoint string
+
+// Endpoints that can be polled by a Watcher.
+const (
+	EndpointForecast                 Endpoint = "forecast"
+	EndpointObservation              Endpoint = "observation"
+	EndpointObservationOneMin        Endpoint = "observation-one-min"
+	EndpointObservationForecastHours Endpoint = "observation-forecast-hours"
+	EndpointPollen                   Endpoint = "pollen"
+	EndpointRiseSet                  Endpoint = "riseset"
+)
+
+// Detect selects how a Watcher decides that a fetch has changed.
+type Detect int
+
+const (
+	// DetectContent reports a change whenever any field differs, subject to
+	// the Watcher's DiffOptions.
+	DetectContent Detect = iota
+	// DetectTimestamp reports a change only when the response's own
+	// timestamp moves, such as ObservationOneMin.Date or the IssuedAt of the
+	// first forecast day.
+	DetectTimestamp
+)
+
+// DefaultWatchInterval is used by a Watch with no Interval set.
+const DefaultWatchInterval = time.Minute
+
+// MaxWatchBackoff is the longest a Watcher waits between polls of a location
+// the API doesn't know, unless the Watch's Interval is longer still.
+const MaxWatchBackoff = time.Hour
+
+// Watch describes a single endpoint and location for a Watcher to poll.
+type Watch struct {
+	Endpoint Endpoint
+	Location string
+	Interval time.Duration
+	Detect   Detect
+}
+
+// Event is sent by a Watcher when a polled value changes or a fetch fails.
+// Exactly one of the typed value fields is set for a successful fetch,
+// matching Endpoint. They are all nil for a failed fetch.
+type Event struct {
+	Endpoint Endpoint
+	Location string
+	Time     time.Time
+
+	// Changes holds the differences from the previous value. It is nil for
+	// the first fetch of a Watch.
+	Changes []Change
+	Err     error
+
+	Forecast                 *Forecast
+	Observation              *Observation
+	ObservationOneMin        *ObservationOneMin
+	ObservationForecastHours *ObservationForecastHours
+	Pollen                   *Pollen
+	RiseSet                  *RiseSet
+}
+
+// Watcher polls a set of endpoints and sends an Event for each change.
+type Watcher struct {
+	client  *Client
+	watches []Watch
+
+	// Jitter is the largest random delay added to each poll interval, to
+	// avoid every Watch hitting the API at once.
+	Jitter time.Duration
+	// DiffOptions is used when comparing fetches with DetectContent.
+	DiffOptions *DiffOptions
+}
+
+// NewWatcher returns a Watcher that polls watches using c. It returns an
+// UnknownEndpointError if a Watch names an endpoint it can't poll.
+func (c *Client) NewWatcher(watches ...Watch) (*Watcher, error) {
+	for _, watch := range watches {
+		switch watch.Endpoint {
+		case EndpointForecast, EndpointObservation, EndpointObservationOneMin,
+			EndpointObservationForecastHours, EndpointPollen, EndpointRiseSet:
+		default:
+			return nil, UnknownEndpointError{Endpoint: watch.Endpoint}
+		}
+	}
+	return &Watcher{
+		client:  c,
+		watches: watches,
+	}, nil
+}
+
+// Run starts polling and returns the channel events are delivered on. Each
+// Watch is fetched straight away and then every Interval plus jitter. An
+// Event is sent for the first fetch, for every fetch that changed and for
+// every failed fetch. The channel is closed once ctx is cancelled and all
+// polling has stopped.
+//
+// A fetch failing with a 404 StatusError usually means the location is
+// unknown, so each one in a row doubles the wait before the next poll, up to
+// MaxWatchBackoff.
+func (w *Watcher) Run(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	var wg sync.WaitGroup
+	for i, watch := range w.watches {
+		wg.Add(1)
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))
+		go func(watch Watch) {
+			defer wg.Done()
+			w.poll(ctx, watch, rnd, events)
+		}(watch)
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events
+}
+
+func (w *Watcher) poll(ctx context.Context, watch Watch, rnd *rand.Rand, events chan<- Event) {
+	var prev *Event
+	var notFound uint
+	for {
+		ev := w.fetch(ctx, watch)
+		if ctx.Err() != nil {
+			return
+		}
+		if err, ok := ev.Err.(StatusError); ok && err.Code == http.StatusNotFound {
+			notFound++
+		} else {
+			notFound = 0
+		}
+		send := true
+		if ev.Err == nil && prev != nil {
+			send = w.compare(watch, prev, &ev)
+		}
+		if ev.Err == nil {
+			prev = &ev
+		}
+		if send {
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		wait := watch.Interval
+		if wait <= 0 {
+			wait = DefaultWatchInterval
+		}
+		if notFound > 0 {
+			wait = backoff(wait, notFound)
+		}
+		if w.Jitter > 0 {
+			wait += time.Duration(rnd.Int63n(int64(w.Jitter)))
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
+
+// backoff returns interval doubled for each of n failures after the first,
+// capped at MaxWatchBackoff or interval if that's longer.
+func backoff(interval time.Duration, n uint) time.Duration {
+	max := MaxWatchBackoff
+	if interval > max {
+		return interval
+	}
+	for i := uint(1); i < n && interval < max; i++ {
+		interval *= 2
+	}
+	if interval > max {
+		return max
+	}
+	return interval
+}
+
+// fetch calls the endpoint for watch and wraps the result in an Event.
+func (w *Watcher) fetch(ctx context.Context, watch Watch) Event {
+	ev := Event{
+		Endpoint: watch.Endpoint,
+		Location: watch.Location,
+		Time:     time.Now(),
+	}
+	c := w.client
+	switch watch.Endpoint {
+	case EndpointForecast:
+		ev.Forecast, _, ev.Err = c.GetForecast(ctx, watch.Location)
+	case EndpointObservation:
+		ev.Observation, _, ev.Err = c.GetObservation(ctx, watch.Location)
+	case EndpointObservationOneMin:
+		ev.ObservationOneMin, _, ev.Err = c.GetObservationOneMin(ctx, watch.Location)
+	case EndpointObservationForecastHours:
+		ev.ObservationForecastHours, _, ev.Err = c.GetObservationForecastHours(ctx, watch.Location)
+	case EndpointPollen:
+		ev.Pollen, _, ev.Err = c.GetPollen(ctx, watch.Location)
+	case EndpointRiseSet:
+		ev.RiseSet, _, ev.Err = c.GetRiseSet(ctx, watch.Location)
+	default:
+		ev.Err = UnknownEndpointError{Endpoint: watch.Endpoint}
+	}
+	if ev.Err != nil {
+		// The Get methods return an empty value with their error.
+		return Event{Endpoint: ev.Endpoint, Location: ev.Location, Time: ev.Time, Err: ev.Err}
+	}
+	return ev
+}
+
+// compare fills in cur.Changes from prev and reports whether cur should be
+// sent.
+func (w *Watcher) compare(watch Watch, prev, cur *Event) bool {
+	switch watch.Endpoint {
+	case EndpointForecast:
+		cur.Changes = DiffForecast(prev.Forecast, cur.Forecast, w.DiffOptions)
+	case EndpointObservation:
+		cur.Changes = DiffObservation(prev.Observation, cur.Observation, w.DiffOptions)
+	case EndpointObservationOneMin:
+		cur.Changes = DiffObservationOneMin(prev.ObservationOneMin, cur.ObservationOneMin, w.DiffOptions)
+	case EndpointObservationForecastHours:
+		cur.Changes = DiffObservationForecastHours(prev.ObservationForecastHours, cur.ObservationForecastHours, w.DiffOptions)
+	case EndpointPollen:
+		cur.Changes = DiffPollen(prev.Pollen, cur.Pollen, w.DiffOptions)
+	case EndpointRiseSet:
+		cur.Changes = DiffRiseSet(prev.RiseSet, cur.RiseSet, w.DiffOptions)
+	}
+	if watch.Detect == DetectTimestamp {
+		a, b := prev.timestamp(), cur.timestamp()
+		if a == nil || b == nil {
+			return a != b
+		}
+		return !a.Equal(*b)
+	}
+	return len(cur.Changes) > 0
+}
+
+// timestamp returns the time the API reports for the value in ev, or nil if
+// it has none.
+func (ev *Event) timestamp() *Timestamp {
+	switch {
+	case ev.Forecast != nil:
+		if len(ev.Forecast.Days) > 0 {
+			return ev.Forecast.Days[0].IssuedAt
+		}
+	case ev.Observation != nil:
+		if ev.Observation.ThreeHour != nil {
+			return ev.Observation.ThreeHour.Date
+		}
+	case ev.ObservationOneMin != nil:
+		return ev.ObservationOneMin.Date
+	case ev.ObservationForecastHours != nil:
+		obs := ev.ObservationForecastHours.Observations
+		if len(obs) > 0 {
+			return obs[len(obs)-1].Date
+		}
+	case ev.Pollen != nil:
+		if len(ev.Pollen.PollenDays) > 0 {
+			return ev.Pollen.PollenDays[0].ValidFrom
+		}
+	case ev.RiseSet != nil:
+		return ev.RiseSet.Date
+	}
+	return nil
+}
+
+// UnknownEndpointError is returned by NewWatcher when a Watch names an
+// endpoint the Watcher does not know how to poll.
+type UnknownEndpointError struct {
+	Endpoint Endpoint
+}
+
+var _ error = UnknownEndpointError{}
+
+func (e UnknownEndpointError) Error() string {
+	return "unknown endpoint: " + string(e.Endpoint)
+}
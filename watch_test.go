@@ -0,0 +1,204 @@
+package metservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatcher_Run(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	// The observation time only moves on every second request, while the
+	// status text changes every time.
+	var mu sync.Mutex
+	var n int
+	mux.HandleFunc("/oneMinObs_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		date := referenceTime.Add(time.Duration(n/2) * time.Minute)
+		fmt.Fprintf(w, `{"timeISO": %q, "status": "%d"}`, date.Format(time.RFC3339), n)
+		n++
+	})
+	mux.HandleFunc("/localForecastDunedin", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := client.NewWatcher(
+		Watch{
+			Endpoint: EndpointObservationOneMin,
+			Location: "Dunedin",
+			Interval: time.Millisecond,
+			Detect:   DetectTimestamp,
+		},
+		Watch{
+			Endpoint: EndpointForecast,
+			Location: "Dunedin",
+			Interval: time.Hour,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := w.Run(ctx)
+
+	var dates []time.Time
+	var errs int
+	for ev := range events {
+		switch ev.Endpoint {
+		case EndpointObservationOneMin:
+			if ev.Err != nil {
+				t.Fatalf("unexpected error: %v", ev.Err)
+			}
+			if len(dates) > 0 && len(ev.Changes) == 0 {
+				t.Errorf("event %d has no changes", len(dates))
+			}
+			if len(dates) < 3 {
+				dates = append(dates, ev.ObservationOneMin.Date.Time)
+			}
+		case EndpointForecast:
+			if _, ok := ev.Err.(StatusError); !ok {
+				t.Errorf("forecast event error = %v, want StatusError", ev.Err)
+			}
+			if ev.Forecast != nil {
+				t.Errorf("failed forecast event has forecast %+v", ev.Forecast)
+			}
+			errs++
+		}
+		if len(dates) == 3 && errs > 0 {
+			cancel()
+		}
+	}
+
+	for i, d := range dates {
+		want := referenceTime.Add(time.Duration(i) * time.Minute)
+		if !d.Equal(want) {
+			t.Errorf("event %d date = %v, want %v", i, d, want)
+		}
+	}
+	if errs != 1 {
+		t.Errorf("got %d forecast error events, want 1", errs)
+	}
+}
+
+func TestWatcher_Content(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var mu sync.Mutex
+	var n int
+	mux.HandleFunc("/riseSet_Dunedin", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Only the third request returns something different.
+		loc := "Dunedin"
+		if n >= 2 {
+			loc = "Mosgiel"
+		}
+		fmt.Fprintf(w, `{"location": %q}`, loc)
+		n++
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := client.NewWatcher(Watch{
+		Endpoint: EndpointRiseSet,
+		Location: "Dunedin",
+		Interval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := w.Run(ctx)
+
+	first := <-events
+	if first.Changes != nil || *first.RiseSet.Location != "Dunedin" {
+		t.Errorf("first event = %+v", first)
+	}
+	second := <-events
+	want := []Change{{Path: "Location", Old: "Dunedin", New: "Mosgiel"}}
+	if fmt.Sprint(second.Changes) != fmt.Sprint(want) {
+		t.Errorf("second event changes = %v, want %v", second.Changes, want)
+	}
+	cancel()
+	for range events {
+	}
+}
+
+func TestNewWatcher_UnknownEndpoint(t *testing.T) {
+	client, _, teardown := setup()
+	defer teardown()
+
+	_, err := client.NewWatcher(
+		Watch{Endpoint: EndpointPollen, Location: "Dunedin"},
+		Watch{Endpoint: "tides", Location: "Dunedin"},
+	)
+	if err != (UnknownEndpointError{Endpoint: "tides"}) {
+		t.Errorf("NewWatcher error = %v, want UnknownEndpointError", err)
+	}
+}
+
+func TestWatcher_NotFound(t *testing.T) {
+	client, mux, teardown := setup()
+	defer teardown()
+
+	var mu sync.Mutex
+	var n int
+	mux.HandleFunc("/pollen_town_Atlantis", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		http.NotFound(w, r)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := client.NewWatcher(Watch{
+		Endpoint: EndpointPollen,
+		Location: "Atlantis",
+		Interval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := w.Run(ctx)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		cancel()
+	}()
+	for range events {
+	}
+
+	// Without backing off there would be around 15 requests, with it the
+	// waits go 20, 40, 80 and 160ms.
+	mu.Lock()
+	defer mu.Unlock()
+	if n < 2 || n > 6 {
+		t.Errorf("got %d requests for an unknown location, want 2 to 6", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	testCases := []struct {
+		interval time.Duration
+		n        uint
+		want     time.Duration
+	}{
+		{time.Minute, 1, time.Minute},
+		{time.Minute, 2, 2 * time.Minute},
+		{time.Minute, 4, 8 * time.Minute},
+		{time.Minute, 100, MaxWatchBackoff},
+		{2 * time.Hour, 3, 2 * time.Hour},
+	}
+	for _, tc := range testCases {
+		if got := backoff(tc.interval, tc.n); got != tc.want {
+			t.Errorf("backoff(%v, %d) = %v, want %v", tc.interval, tc.n, got, tc.want)
+		}
+	}
+}